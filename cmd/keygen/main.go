@@ -0,0 +1,72 @@
+// Command keygen writes a new JWT signing key into the keys directory. Run it
+// on a schedule (e.g. monthly cron) to rotate keys: the API picks the new key
+// up on its next reload, publishes it in the JWKS, and starts signing with it
+// once JWT_KEY_ACTIVATION_DELAY has passed.
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+func main() {
+	dir := flag.String("dir", os.Getenv("JWT_KEYS_DIR"), "keys directory")
+	alg := flag.String("alg", "EdDSA", "key algorithm: EdDSA or RS256")
+	kid := flag.String("kid", time.Now().UTC().Format("2006-01-02T150405"), "key id (file name)")
+	flag.Parse()
+
+	if *dir == "" {
+		log.Fatal("keys directory required (-dir or JWT_KEYS_DIR)")
+	}
+
+	var (
+		der []byte
+		err error
+	)
+
+	switch *alg {
+	case "EdDSA":
+		var priv ed25519.PrivateKey
+		_, priv, err = ed25519.GenerateKey(rand.Reader)
+		if err == nil {
+			der, err = x509.MarshalPKCS8PrivateKey(priv)
+		}
+	case "RS256":
+		var priv *rsa.PrivateKey
+		priv, err = rsa.GenerateKey(rand.Reader, 2048)
+		if err == nil {
+			der, err = x509.MarshalPKCS8PrivateKey(priv)
+		}
+	default:
+		log.Fatalf("unsupported algorithm %q", *alg)
+	}
+	if err != nil {
+		log.Fatal("Failed to generate key:", err)
+	}
+
+	if err := os.MkdirAll(*dir, 0o700); err != nil {
+		log.Fatal("Failed to create keys directory:", err)
+	}
+
+	path := filepath.Join(*dir, *kid+".pem")
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		log.Fatal("Failed to create key file:", err)
+	}
+	defer file.Close()
+
+	if err := pem.Encode(file, &pem.Block{Type: "PRIVATE KEY", Bytes: der}); err != nil {
+		log.Fatal("Failed to write key file:", err)
+	}
+
+	fmt.Println(path)
+}
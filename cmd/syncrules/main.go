@@ -0,0 +1,18 @@
+// Command syncrules prints the PowerSync sync rules YAML generated from
+// internal/syncrules, e.g. `go run ./cmd/syncrules > sync-rules.yaml`.
+package main
+
+import (
+	"fmt"
+	"log"
+
+	"pwa-backend/internal/syncrules"
+)
+
+func main() {
+	if err := syncrules.Default.Validate(); err != nil {
+		log.Fatal("Invalid sync rules:", err)
+	}
+
+	fmt.Print(syncrules.Default.YAML())
+}
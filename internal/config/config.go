@@ -2,31 +2,93 @@ package config
 
 import (
 	"os"
+	"strconv"
+	"strings"
 	"time"
 )
 
 type Config struct {
     DatabaseURL        string
-    JWTSecret          string
     Port               string
+    Currency           string
     DBConnectTimeout   time.Duration
     DBMaxRetries       int
+    // TrustedProxies lists the proxy IPs or CIDRs whose X-Forwarded-For
+    // header is used for the client IP. Empty trusts no proxy.
+    TrustedProxies     []string
 }
 
+// JWTConfig configures token signing. Access tokens for the API carry
+// Audience; tokens for PowerSync carry PowerSyncAudience, so neither is
+// accepted in place of the other. AllowEphemeralKeys permits running without
+// KeysDir, for local development only.
 type JWTConfig struct {
-    Secret   string
-    KeyID    string
-    Issuer   string
-    Audience string
+    KeysDir              string
+    SigningKeyID         string
+    KeyActivationDelay   time.Duration
+    KeysReloadInterval   time.Duration
+    AllowEphemeralKeys   bool
+    Issuer               string
+    Audience             string
+    PowerSyncAudience    string
+}
+
+// IdempotencyConfig decides how long an Idempotency-Key replays its response
+// and how often expired keys are deleted. Keys are kept for the whole
+// OfflineWindow, the longest a client may queue a checkout before uploading
+// it, so a late retry is never taken for a new sale.
+type IdempotencyConfig struct {
+    OfflineWindow time.Duration
+    PruneInterval time.Duration
+}
+
+// LoginLimitConfig configures brute-force protection on login. Store is
+// "memory" for a single instance or "postgres" to share counters.
+type LoginLimitConfig struct {
+    Store            string
+    MaxFailures      int
+    MaxFailuresPerIP int
+    LockoutDuration  time.Duration
+    FailureWindow    time.Duration
+    BaseDelay        time.Duration
+    MaxDelay         time.Duration
+}
+
+// PINConfig configures cashier PIN login. PIN sessions end after
+// SessionDuration and are never extended by refreshing.
+type PINConfig struct {
+    SessionDuration time.Duration
+    MaxFailures     int
+    LockoutDuration time.Duration
+}
+
+// RegistrationConfig decides who may use /auth/register: "open" (anyone,
+// as staff), "invite" (only with an invite token) or "closed".
+type RegistrationConfig struct {
+    Mode           string
+    InviteDuration time.Duration
+}
+
+// ReconcileConfig schedules the stock reconciliation inside the API. An
+// Interval of zero disables it; Rebuild also corrects the drift it finds.
+type ReconcileConfig struct {
+    Interval time.Duration
+    Rebuild  bool
+}
+
+type PasswordConfig struct {
+    MinLength   int
+    HistorySize int
 }
 
 func Load() *Config {
     return &Config{
         DatabaseURL:      getEnv("DATABASE_URL", ""),
-        JWTSecret:        getEnv("JWT_SECRET", "biskuat"),
         Port:             getEnv("PORT", "8080"),
+        Currency:         getEnv("CURRENCY", "IDR"),
         DBConnectTimeout: 30 * time.Second,
         DBMaxRetries:     5,
+        TrustedProxies:   getEnvList("TRUSTED_PROXIES"),
     }
 }
 
@@ -37,11 +99,93 @@ func getEnv(key, defaultValue string) string {
     return defaultValue
 }
 
-func NewJWTConfig(secret string) *JWTConfig {
+// getEnvList splits a comma-separated variable, dropping empty items. It
+// returns nil when the variable is unset.
+func getEnvList(key string) []string {
+    var items []string
+    for _, item := range strings.Split(os.Getenv(key), ",") {
+        if item = strings.TrimSpace(item); item != "" {
+            items = append(items, item)
+        }
+    }
+    return items
+}
+
+func getEnvInt(key string, defaultValue int) int {
+    if value := os.Getenv(key); value != "" {
+        if n, err := strconv.Atoi(value); err == nil {
+            return n
+        }
+    }
+    return defaultValue
+}
+
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+    if value := os.Getenv(key); value != "" {
+        if d, err := time.ParseDuration(value); err == nil {
+            return d
+        }
+    }
+    return defaultValue
+}
+
+func NewJWTConfig() *JWTConfig {
     return &JWTConfig{
-        Secret:   secret,
-        KeyID:    "powersync-key",
-        Issuer:   "pwa-backend",
-        Audience: "https://6940ebf14011d65924582a54.powersync.journeyapps.com",
+        KeysDir:            getEnv("JWT_KEYS_DIR", ""),
+        SigningKeyID:       getEnv("JWT_SIGNING_KID", ""),
+        KeyActivationDelay: getEnvDuration("JWT_KEY_ACTIVATION_DELAY", 10*time.Minute),
+        KeysReloadInterval: getEnvDuration("JWT_KEYS_RELOAD_INTERVAL", time.Minute),
+        AllowEphemeralKeys: getEnv("JWT_ALLOW_EPHEMERAL_KEYS", "false") == "true",
+        Issuer:             getEnv("JWT_ISSUER", "pwa-backend"),
+        Audience:           getEnv("JWT_AUDIENCE", "pwa-backend-api"),
+        PowerSyncAudience:  getEnv("POWERSYNC_URL", "https://6940ebf14011d65924582a54.powersync.journeyapps.com"),
+    }
+}
+
+func NewIdempotencyConfig() *IdempotencyConfig {
+    return &IdempotencyConfig{
+        OfflineWindow: getEnvDuration("OFFLINE_WINDOW", 30*24*time.Hour),
+        PruneInterval: getEnvDuration("IDEMPOTENCY_PRUNE_INTERVAL", time.Hour),
+    }
+}
+
+func NewLoginLimitConfig() *LoginLimitConfig {
+    return &LoginLimitConfig{
+        Store:            getEnv("LOGIN_LIMIT_STORE", "memory"),
+        MaxFailures:      getEnvInt("LOGIN_MAX_FAILURES", 5),
+        MaxFailuresPerIP: getEnvInt("LOGIN_MAX_FAILURES_PER_IP", 20),
+        LockoutDuration:  getEnvDuration("LOGIN_LOCKOUT_DURATION", 15*time.Minute),
+        FailureWindow:    getEnvDuration("LOGIN_FAILURE_WINDOW", 15*time.Minute),
+        BaseDelay:        getEnvDuration("LOGIN_DELAY_BASE", time.Second),
+        MaxDelay:         getEnvDuration("LOGIN_DELAY_MAX", 30*time.Second),
+    }
+}
+
+func NewPINConfig() *PINConfig {
+    return &PINConfig{
+        SessionDuration: getEnvDuration("PIN_SESSION_DURATION", 12*time.Hour),
+        MaxFailures:     getEnvInt("PIN_MAX_FAILURES", 5),
+        LockoutDuration: getEnvDuration("PIN_LOCKOUT_DURATION", 15*time.Minute),
+    }
+}
+
+func NewRegistrationConfig() *RegistrationConfig {
+    return &RegistrationConfig{
+        Mode:           getEnv("REGISTRATION_MODE", "invite"),
+        InviteDuration: getEnvDuration("INVITE_DURATION", 72*time.Hour),
     }
-}
\ No newline at end of file
+}
+
+func NewPasswordConfig() *PasswordConfig {
+    return &PasswordConfig{
+        MinLength:   getEnvInt("PASSWORD_MIN_LENGTH", 8),
+        HistorySize: getEnvInt("PASSWORD_HISTORY_SIZE", 5),
+    }
+}
+
+func NewReconcileConfig() *ReconcileConfig {
+    return &ReconcileConfig{
+        Interval: getEnvDuration("RECONCILE_INTERVAL", 24*time.Hour),
+        Rebuild:  getEnv("RECONCILE_REBUILD", "false") == "true",
+    }
+}
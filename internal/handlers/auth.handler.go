@@ -1,178 +1,272 @@
 package handlers
 
 import (
-	"encoding/base64"
-	"fmt"
 	"log"
+	"math"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
-	"github.com/google/uuid"
 	"golang.org/x/crypto/bcrypt"
 
 	"pwa-backend/internal/config"
+	"pwa-backend/internal/ids"
+	"pwa-backend/internal/jwtkeys"
+	"pwa-backend/internal/loginlimit"
 	"pwa-backend/internal/models"
+	"pwa-backend/internal/password"
+	"pwa-backend/internal/rbac"
 	"pwa-backend/internal/repositories"
+	"pwa-backend/internal/syncrules"
+)
+
+const (
+	registrationOpen   = "open"
+	registrationClosed = "closed"
 )
 
 type AuthHandlerStruct struct {
-	userRepo          *repositories.UserRepository
-	sessionRepo       *repositories.UserSessionRepository
-    jwtConfig            *config.JWTConfig
+	userRepo             *repositories.UserRepository
+	sessionRepo          *repositories.UserSessionRepository
+	deviceRepo           *repositories.DeviceRepository
+	loginLimiter         *loginlimit.Limiter
+	pinLimiter           *loginlimit.Limiter
+	passwordPolicy       *password.Policy
+	twoFactorRepo        *repositories.TwoFactorRepository
+	inviteRepo           *repositories.InviteRepository
+	registrationMode     string
+	jwtConfig            *config.JWTConfig
+	keys                 *jwtkeys.KeySet
 	accessTokenDuration  time.Duration
 	refreshTokenDuration time.Duration
-}
-
-type JWKSResponse struct {
-	Keys []JWK `json:"keys"`
-}
-
-type JWK struct {
-	Kty string `json:"kty"`
-	Use string `json:"use"`
-	Kid string `json:"kid"`
-	Alg string `json:"alg"`
-	K   string `json:"k"`
+	pinSessionDuration   time.Duration
 }
 
 func AuthHandler(
 	userRepo *repositories.UserRepository,
 	sessionRepo *repositories.UserSessionRepository,
-    jwtConfig *config.JWTConfig,
+	deviceRepo *repositories.DeviceRepository,
+	loginLimiter *loginlimit.Limiter,
+	pinLimiter *loginlimit.Limiter,
+	passwordPolicy *password.Policy,
+	twoFactorRepo *repositories.TwoFactorRepository,
+	inviteRepo *repositories.InviteRepository,
+	jwtConfig *config.JWTConfig,
+	pinConfig *config.PINConfig,
+	registrationConfig *config.RegistrationConfig,
+	keys *jwtkeys.KeySet,
 ) *AuthHandlerStruct {
 	return &AuthHandlerStruct{
 		userRepo:             userRepo,
 		sessionRepo:          sessionRepo,
+		deviceRepo:           deviceRepo,
+		loginLimiter:         loginLimiter,
+		pinLimiter:           pinLimiter,
+		passwordPolicy:       passwordPolicy,
+		twoFactorRepo:        twoFactorRepo,
+		inviteRepo:           inviteRepo,
+		registrationMode:     registrationConfig.Mode,
 		jwtConfig:            jwtConfig,
+		keys:                 keys,
 		accessTokenDuration:  10 * time.Minute,
 		refreshTokenDuration: 7 * 24 * time.Hour,
+		pinSessionDuration:   pinConfig.SessionDuration,
 	}
 }
 
 // Register godoc
 // @Summary Register new user
-// @Description Create a new user account
+// @Description Create a new user account. Depending on REGISTRATION_MODE registration is open (staff accounts), invite-only or closed. With an invite_token the invite decides the role and store and is used up. Roles that log in on registered devices only, such as cashier, must send the device_id of an active device.
 // @Tags auth
 // @Accept json
 // @Produce json
 // @Param request body models.RegisterRequest true "Registration data"
-// @Success 201 {object} models.LoginResponse
+// @Success 201 {object} models.TokenResponse
 // @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
 // @Failure 409 {object} map[string]string
 // @Failure 500 {object} map[string]string
 // @Router /auth/register [post]
 func (h *AuthHandlerStruct) Register(c *gin.Context) {
-    var req models.RegisterRequest
-    if err := c.ShouldBindJSON(&req); err != nil {
-        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-        return
-    }
-
-    // Check if user already exists
-    existingUser, err := h.userRepo.GetByUsername(req.Username)
-    if err != nil {
-        c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
-        return
-    }
-
-    if existingUser != nil {
-        c.JSON(http.StatusConflict, gin.H{"error": "Username already exists"})
-        return
-    }
-
-    // Hash password
-    hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
-    if err != nil {
-        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash password"})
-        return
-    }
-
-    user := &models.User{
-        ID:        uuid.New().String(),
-        Username:  req.Username,
-        Password:  string(hashedPassword),
-        Name:      req.Name,
-        Role:      req.Role,
-        CreatedAt: time.Now(),
-        UpdatedAt: time.Now(),
-    }
-
-    if user.Role == "" {
-        user.Role = "staff"
-    }
-
-    if err := h.userRepo.Create(user); err != nil {
-        log.Printf("Failed to create user: %v", err)
-        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create user"})
-        return
-    }
-
-    // Create session
-    sessionID := uuid.New().String()
-    refreshToken := uuid.New().String()
-    now := time.Now()
-
-    session := &models.UserSession{
-        ID:           sessionID,
-        UserID:       user.ID,
-        RefreshToken: refreshToken,
-        ExpiresAt:    now.Add(h.refreshTokenDuration),
-        CreatedAt:    now,
-    }
-
-    if err := h.sessionRepo.Create(session); err != nil {
-        log.Printf("Failed to create session: %v", err)
-        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create session"})
-        return
-    }
-
-    // Generate access token
-    accessToken := jwt.New(jwt.SigningMethodHS256)
-    accessToken.Header["kid"] = "powersync-key"
-
-    accessToken.Claims = jwt.MapClaims{
-        "sub":      user.ID,
-        "aud":      h.jwtConfig.Audience,
-        "iss":      h.jwtConfig.Issuer,
-        "jti":      fmt.Sprintf("%s-%d", user.ID, now.Unix()),
-        "nbf":      now.Unix(),
-        "user_id":  user.ID,
-        "username": user.Username,
-        "role":     user.Role,
-        "sid":      sessionID,
-        "iat":      now.Unix(),
-        "exp":      now.Add(h.accessTokenDuration).Unix(),
-    }
-
-    accessTokenString, err := accessToken.SignedString([]byte(h.jwtConfig.Secret))
-    if err != nil {
-        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
-        return
-    }
-
-    // Return user without password
-    userResponse := *user
-    userResponse.Password = ""
-
-    c.JSON(http.StatusCreated, models.TokenResponse{
-        AccessToken:  accessTokenString,
-        RefreshToken: refreshToken,
-        User:         userResponse,
-    })
+	var req models.RegisterRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if h.registrationMode == registrationClosed {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Registration is closed"})
+		return
+	}
+
+	if req.InviteToken == "" && h.registrationMode != registrationOpen {
+		c.JSON(http.StatusForbidden, gin.H{"error": "An invite is required to register"})
+		return
+	}
+
+	// Without an invite, self-registration always creates staff accounts;
+	// elevated roles must be granted by an admin.
+	role := string(rbac.RoleStaff)
+	var invite *models.Invite
+	if req.InviteToken != "" {
+		var err error
+		invite, err = h.inviteRepo.GetByTokenHash(ids.HashSecret(req.InviteToken))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch invite"})
+			return
+		}
+
+		if invite == nil || !invite.IsUsable(time.Now()) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Invite is invalid or expired"})
+			return
+		}
+		role = invite.Role
+	}
+
+	if req.Role != "" && req.Role != role {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Cannot self-register with role: " + req.Role})
+		return
+	}
+
+	if err := h.passwordPolicy.Validate(req.Password, req.Username); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Check if user already exists
+	existingUser, err := h.userRepo.GetByUsername(req.Username)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+
+	if existingUser != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Username already exists"})
+		return
+	}
+
+	// Hash password
+	hashedPassword, err := password.Hash(req.Password)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash password"})
+		return
+	}
+
+	user := &models.User{
+		ID:        ids.New(),
+		Username:  req.Username,
+		Password:  hashedPassword,
+		Name:      req.Name,
+		Role:      role,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+	if invite != nil {
+		user.StoreID = invite.StoreID
+	}
+
+	// The new account is logged in straight away, so it needs a device on the
+	// same terms as Login.
+	deviceID, ok := h.resolveDevice(c, user, req.DeviceID)
+	if !ok {
+		return
+	}
+
+	if deviceID == nil && rbac.RequiresDevice(user.Role) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "A registered device is required to log in"})
+		return
+	}
+
+	tx, err := h.userRepo.BeginTx()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+		return
+	}
+	defer tx.Rollback()
+
+	if err := h.userRepo.CreateTx(tx, user); err != nil {
+		if repositories.IsUniqueViolation(err) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Username already exists"})
+			return
+		}
+		log.Printf("Failed to create user: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create user"})
+		return
+	}
+
+	// Consuming the invite in the same transaction keeps two registrations
+	// from sharing it.
+	if invite != nil {
+		consumed, err := h.inviteRepo.Consume(tx, invite.ID, user.ID, user.CreatedAt)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to use invite"})
+			return
+		}
+
+		if !consumed {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Invite is invalid or expired"})
+			return
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit"})
+		return
+	}
+
+	// Create session
+	sessionID := ids.New()
+	refreshToken := ids.NewSecret()
+	now := time.Now()
+
+	session := &models.UserSession{
+		ID:           sessionID,
+		UserID:       user.ID,
+		RefreshToken: refreshToken,
+		DeviceID:     deviceID,
+		UserAgent:    userAgent(c),
+		ExpiresAt:    now.Add(h.refreshTokenDuration),
+		CreatedAt:    now,
+	}
+
+	if err := h.sessionRepo.Create(session); err != nil {
+		log.Printf("Failed to create session: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create session"})
+		return
+	}
+
+	// Generate access token
+	accessTokenString, err := h.generateAccessToken(user, session, now)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+
+	// Return user without password
+	userResponse := *user
+	userResponse.Password = ""
+
+	c.JSON(http.StatusCreated, models.TokenResponse{
+		AccessToken:  accessTokenString,
+		RefreshToken: refreshToken,
+		User:         userResponse,
+	})
 }
 
 // Login godoc
 // @Summary Login user
-// @Description Authenticate user and return JWT token
+// @Description Authenticate user and return JWT token. Cashiers must send the device_id of an active registered device. Users assigned to a store can only use that store's devices. Users with two-factor authentication get a challenge token instead, to be completed at /auth/login/2fa.
 // @Tags auth
 // @Accept json
 // @Produce json
 // @Param request body models.LoginRequest true "Login credentials"
-// @Success 200 {object} models.LoginResponse
+// @Success 200 {object} models.TokenResponse "Tokens, or a models.TwoFactorChallengeResponse when two-factor authentication is enabled"
 // @Failure 400 {object} map[string]string
 // @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 429 {object} map[string]interface{}
 // @Router /auth/login [post]
 func (h *AuthHandlerStruct) Login(c *gin.Context) {
 	var req models.LoginRequest
@@ -181,35 +275,98 @@ func (h *AuthHandlerStruct) Login(c *gin.Context) {
 		return
 	}
 
+	clientIP := c.ClientIP()
+
+	attempt, wait, err := h.loginLimiter.Reserve(req.Username, clientIP)
+	if err != nil {
+		log.Printf("Failed to check login attempts: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+
+	if wait > 0 {
+		respondTooManyAttempts(c, wait)
+		return
+	}
+
 	user, err := h.userRepo.GetByUsername(req.Username)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
 		return
 	}
 
-	if user == nil {
+	// Unknown usernames count as failures too, so guessing them costs the same.
+	if user == nil || bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)) != nil {
+		if err := attempt.Fail(); err != nil {
+			log.Printf("Failed to record login failure: %v", err)
+		}
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
 		return
 	}
 
-	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)); err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+	if err := attempt.Release(); err != nil {
+		log.Printf("Failed to release login attempt: %v", err)
+	}
+
+	// Checked after the password so the response does not reveal which
+	// accounts exist.
+	if user.IsDisabled() {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Account is disabled"})
 		return
 	}
 
-	// Create session
-	sessionID := uuid.New().String()
-	refreshToken := uuid.New().String()
+	deviceID, ok := h.resolveDevice(c, user, req.DeviceID)
+	if !ok {
+		return
+	}
+
+	if deviceID == nil && rbac.RequiresDevice(user.Role) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "A registered device is required to log in"})
+		return
+	}
+
+	// The failure counter is only reset once the second factor is checked
+	// too, so knowing the password does not buy unlimited code guesses.
+	if user.TwoFactorEnabled() {
+		h.startTwoFactorChallenge(c, user, deviceID)
+		return
+	}
+
+	if err := h.loginLimiter.RecordSuccess(req.Username); err != nil {
+		log.Printf("Failed to reset login failures: %v", err)
+	}
+
+	h.startSession(c, user, deviceID, models.AuthMethodPassword)
+}
+
+// startSession creates a session for an authenticated user and responds with
+// its access and refresh tokens.
+func (h *AuthHandlerStruct) startSession(c *gin.Context, user *models.User, deviceID *string, authMethod string) {
+	if user.IsDisabled() {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Account is disabled"})
+		return
+	}
+
+	sessionID := ids.New()
+	refreshToken := ids.NewSecret()
 	now := time.Now()
-	
+
+	duration := h.refreshTokenDuration
+	if authMethod == models.AuthMethodPIN {
+		duration = h.pinSessionDuration
+	}
+
 	session := &models.UserSession{
 		ID:           sessionID,
 		UserID:       user.ID,
 		RefreshToken: refreshToken,
-		ExpiresAt:    now.Add(h.refreshTokenDuration),
+		DeviceID:     deviceID,
+		UserAgent:    userAgent(c),
+		AuthMethod:   authMethod,
+		ExpiresAt:    now.Add(duration),
 		CreatedAt:    now,
 	}
-	
+
 	if err := h.sessionRepo.Create(session); err != nil {
 		log.Printf("Failed to create session: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create session"})
@@ -217,24 +374,7 @@ func (h *AuthHandlerStruct) Login(c *gin.Context) {
 	}
 
 	// Generate access token
-	accessToken := jwt.New(jwt.SigningMethodHS256)
-	accessToken.Header["kid"] = "powersync-key"
-	
-	accessToken.Claims = jwt.MapClaims{
-		"sub":      user.ID,
-		"aud":      h.jwtConfig.Audience,
-        "iss":      h.jwtConfig.Issuer,
-		"jti":      fmt.Sprintf("%s-%d", user.ID, now.Unix()),
-		"nbf":      now.Unix(),
-		"user_id":  user.ID,
-		"username": user.Username,
-		"role":     user.Role,
-		"sid":      sessionID,
-		"iat":      now.Unix(),
-		"exp":      now.Add(h.accessTokenDuration).Unix(),
-	}
-
-    accessTokenString, err := accessToken.SignedString([]byte(h.jwtConfig.Secret))
+	accessTokenString, err := h.generateAccessToken(user, session, now)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
 		return
@@ -249,7 +389,7 @@ func (h *AuthHandlerStruct) Login(c *gin.Context) {
 
 // RefreshToken godoc
 // @Summary Refresh access token
-// @Description Use refresh token to get new access token
+// @Description Exchange a refresh token for a new access token and a new refresh token. The presented refresh token is consumed; reusing it revokes the whole session family. Fails when the session's device was deactivated or no longer belongs to the user's store.
 // @Tags auth
 // @Accept json
 // @Produce json
@@ -257,6 +397,7 @@ func (h *AuthHandlerStruct) Login(c *gin.Context) {
 // @Success 200 {object} models.TokenResponse
 // @Failure 400 {object} map[string]string
 // @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
 // @Router /auth/refresh [post]
 func (h *AuthHandlerStruct) RefreshToken(c *gin.Context) {
 	var req models.RefreshTokenRequest
@@ -277,6 +418,18 @@ func (h *AuthHandlerStruct) RefreshToken(c *gin.Context) {
 		return
 	}
 
+	if session.RevokedAt != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Refresh token revoked"})
+		return
+	}
+
+	// A refresh token that was already rotated is being replayed, so assume it
+	// leaked and kill every session that descends from the same login.
+	if session.UsedAt != nil {
+		h.revokeReusedFamily(c, session)
+		return
+	}
+
 	if time.Now().After(session.ExpiresAt) {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Refresh token expired"})
 		return
@@ -293,25 +446,70 @@ func (h *AuthHandlerStruct) RefreshToken(c *gin.Context) {
 		return
 	}
 
+	if user.IsDisabled() {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Account is disabled"})
+		return
+	}
+
+	if session.DeviceID != nil {
+		if _, ok := h.resolveDevice(c, user, *session.DeviceID); !ok {
+			return
+		}
+	} else if rbac.RequiresDevice(user.Role) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "A registered device is required to log in"})
+		return
+	}
+
 	now := time.Now()
-	token := jwt.New(jwt.SigningMethodHS256)
-	token.Header["kid"] = "powersync-key"
+	newSession := &models.UserSession{
+		ID:           ids.New(),
+		UserID:       user.ID,
+		FamilyID:     session.FamilyID,
+		RefreshToken: ids.NewSecret(),
+		DeviceID:     session.DeviceID,
+		UserAgent:    session.UserAgent,
+		AuthMethod:   session.AuthMethod,
+		ExpiresAt:    now.Add(h.refreshTokenDuration),
+		CreatedAt:    now,
+	}
 
-	token.Claims = jwt.MapClaims{
-		"sub":      user.ID,
-		"aud":      h.jwtConfig.Audience,
-        "iss":      h.jwtConfig.Issuer,
-		"jti":      fmt.Sprintf("%s-%d", user.ID, now.Unix()),
-		"nbf":      now.Unix(),
-		"user_id":  user.ID,
-		"username": user.Username,
-		"role":     user.Role,
-		"sid":      session.ID,
-		"iat":      now.Unix(),
-		"exp":      now.Add(h.accessTokenDuration).Unix(),
+	// A PIN session lasts one shift from the PIN login; refreshing only
+	// rotates the token.
+	if session.AuthMethod == models.AuthMethodPIN {
+		newSession.ExpiresAt = session.ExpiresAt
+	}
+
+	tx, err := h.sessionRepo.BeginTx()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+		return
+	}
+	defer tx.Rollback()
+
+	rotated, err := h.sessionRepo.MarkUsed(tx, session.ID, newSession.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to rotate refresh token"})
+		return
+	}
+
+	if !rotated {
+		tx.Rollback()
+		h.revokeReusedFamily(c, session)
+		return
+	}
+
+	if err := h.sessionRepo.CreateTx(tx, newSession); err != nil {
+		log.Printf("Failed to create session: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create session"})
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit"})
+		return
 	}
 
-	accessTokenString, err := token.SignedString([]byte(h.jwtConfig.Secret))
+	accessTokenString, err := h.generateAccessToken(user, newSession, now)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
 		return
@@ -319,11 +517,95 @@ func (h *AuthHandlerStruct) RefreshToken(c *gin.Context) {
 
 	c.JSON(http.StatusOK, models.TokenResponse{
 		AccessToken:  accessTokenString,
-		RefreshToken: req.RefreshToken,
+		RefreshToken: newSession.RefreshToken,
 		User:         *user,
 	})
 }
 
+// resolveDevice checks that deviceID names an active registered device user
+// may work on and records it as seen. An empty deviceID means the session is
+// not bound to a device. On failure it writes the response and returns false.
+func (h *AuthHandlerStruct) resolveDevice(c *gin.Context, user *models.User, deviceID string) (*string, bool) {
+	if deviceID == "" {
+		return nil, true
+	}
+
+	device, ok := h.getActiveDevice(c, deviceID)
+	if !ok {
+		return nil, false
+	}
+
+	if !checkDeviceStore(c, user, device) {
+		return nil, false
+	}
+
+	return &device.ID, true
+}
+
+// checkDeviceStore keeps staff assigned to a store on that store's terminals,
+// since the session would otherwise work in the device's store. On failure it
+// writes the response and returns false.
+func checkDeviceStore(c *gin.Context, user *models.User, device *models.Device) bool {
+	if user.StoreID != nil && device.StoreID != nil && *user.StoreID != *device.StoreID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Device belongs to another store"})
+		return false
+	}
+	return true
+}
+
+// getActiveDevice is resolveDevice for a device that must be given.
+func (h *AuthHandlerStruct) getActiveDevice(c *gin.Context, deviceID string) (*models.Device, bool) {
+	device, err := h.deviceRepo.GetByID(deviceID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch device"})
+		return nil, false
+	}
+
+	if device == nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Device is not registered"})
+		return nil, false
+	}
+
+	if !device.IsActive() {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Device is deactivated"})
+		return nil, false
+	}
+
+	if err := h.deviceRepo.Touch(device.ID, time.Now()); err != nil {
+		log.Printf("Failed to update device last seen: %v", err)
+	}
+
+	return device, true
+}
+
+func respondTooManyAttempts(c *gin.Context, wait time.Duration) {
+	seconds := int(math.Ceil(wait.Seconds()))
+	c.Header("Retry-After", strconv.Itoa(seconds))
+	c.JSON(http.StatusTooManyRequests, gin.H{
+		"error":       "Too many failed login attempts, try again later",
+		"retry_after": seconds,
+	})
+}
+
+func userAgent(c *gin.Context) *string {
+	ua := c.Request.UserAgent()
+	if ua == "" {
+		return nil
+	}
+	return &ua
+}
+
+func (h *AuthHandlerStruct) revokeReusedFamily(c *gin.Context, session *models.UserSession) {
+	log.Printf("Refresh token reuse detected for user %s, revoking session family %s", session.UserID, session.FamilyID)
+
+	if err := h.sessionRepo.RevokeFamily(session.FamilyID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke sessions"})
+		return
+	}
+
+	c.JSON(http.StatusUnauthorized, gin.H{"error": "Refresh token reuse detected, please login again"})
+}
+
 // Logout godoc
 // @Summary Logout user
 // @Description Revoke user session
@@ -348,80 +630,202 @@ func (h *AuthHandlerStruct) Logout(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "Logout successful"})
 }
 
-// JWKS godoc
-// @Summary Get JSON Web Key Set
-// @Description Get JWKS for PowerSync integration
+// ChangePassword godoc
+// @Summary Change password
+// @Description Change the authenticated user's password. The current password is required, the new one must satisfy the password policy, and every other session is revoked. Returns a new access token for the current session.
 // @Tags auth
+// @Accept json
 // @Produce json
-// @Success 200 {object} JWKSResponse
-// @Router /.well-known/jwks.json [get]
-func (h *AuthHandlerStruct) JWKS(c *gin.Context) {
-	keyBytes := []byte(h.jwtConfig.Secret)
-	encoded := base64.RawURLEncoding.EncodeToString(keyBytes)
+// @Security BearerAuth
+// @Param request body models.ChangePasswordRequest true "Current and new password"
+// @Success 200 {object} models.AccessTokenResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 429 {object} map[string]interface{}
+// @Router /auth/change-password [post]
+func (h *AuthHandlerStruct) ChangePassword(c *gin.Context) {
+	var req models.ChangePasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, err := h.userRepo.GetByID(c.GetString("user_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch user"})
+		return
+	}
+
+	if user == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+		return
+	}
+
+	// A stolen access token must not allow unlimited guessing of the current
+	// password, so this goes through the login limiter as well.
+	clientIP := c.ClientIP()
+	attempt, wait, err := h.loginLimiter.Reserve(user.Username, clientIP)
+	if err != nil {
+		log.Printf("Failed to check login attempts: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+
+	if wait > 0 {
+		respondTooManyAttempts(c, wait)
+		return
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.CurrentPassword)); err != nil {
+		if err := attempt.Fail(); err != nil {
+			log.Printf("Failed to record login failure: %v", err)
+		}
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Current password is incorrect"})
+		return
+	}
+
+	if err := attempt.Release(); err != nil {
+		log.Printf("Failed to release login attempt: %v", err)
+	}
+
+	if err := checkNewPassword(h.userRepo, h.passwordPolicy, user, req.NewPassword); err != nil {
+		respondError(c, err, "Failed to check password history")
+		return
+	}
+
+	if err := setPassword(h.userRepo, user, req.NewPassword, false); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to change password"})
+		return
+	}
+
+	session, err := h.sessionRepo.GetByID(c.GetString("session_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch session"})
+		return
+	}
+
+	if session == nil {
+		err = h.sessionRepo.RevokeAllByUserID(user.ID)
+	} else {
+		err = h.sessionRepo.RevokeOthersByUserID(user.ID, session.FamilyID)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke sessions"})
+		return
+	}
+
+	if session == nil {
+		c.JSON(http.StatusOK, gin.H{"message": "Password changed, please login again"})
+		return
+	}
+
+	accessTokenString, err := h.generateAccessToken(user, session, time.Now())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.AccessTokenResponse{
+		AccessToken: accessTokenString,
+		User:        *user,
+	})
+}
 
-	log.Printf("JWKS Endpoint Called - KID: %s, Secret (base64): %s", "powersync-key", encoded)
+// checkNewPassword applies the password policy to a new password for user,
+// including the rule against reusing the current or a recent password.
+func checkNewPassword(userRepo *repositories.UserRepository, policy *password.Policy, user *models.User, newPassword string) error {
+	if err := policy.Validate(newPassword, user.Username); err != nil {
+		return newRequestError(http.StatusBadRequest, err.Error())
+	}
 
-	jwks := JWKSResponse{
-		Keys: []JWK{
-			{
-				Kty: "oct",           
-				Use: "sig",           
-				Kid: "powersync-key", 
-				Alg: "HS256",         
-				K:   encoded,         
-			},
-		},
+	history, err := userRepo.GetPasswordHistory(user.ID, policy.HistorySize)
+	if err != nil {
+		return err
 	}
 
-	c.JSON(http.StatusOK, jwks)
+	if err := policy.CheckReuse(newPassword, append([]string{user.Password}, history...)); err != nil {
+		return newRequestError(http.StatusBadRequest, err.Error())
+	}
+
+	return nil
+}
+
+// setPassword hashes and stores a new password for user, keeping the old hash
+// in the password history.
+func setPassword(userRepo *repositories.UserRepository, user *models.User, newPassword string, mustChange bool) error {
+	hash, err := password.Hash(newPassword)
+	if err != nil {
+		return err
+	}
+
+	tx, err := userRepo.BeginTx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := userRepo.UpdatePassword(tx, user, hash, mustChange); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// JWKS godoc
+// @Summary Get JSON Web Key Set
+// @Description Get the public keys used to verify access tokens, for PowerSync integration
+// @Tags auth
+// @Produce json
+// @Success 200 {object} jwtkeys.JWKSResponse
+// @Router /.well-known/jwks.json [get]
+func (h *AuthHandlerStruct) JWKS(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, h.keys.JWKS())
 }
 
 // PowerSyncAuth godoc
-// @Summary Get PowerSync Auth endpoint
-// @Description Endpoint specifically for PowerSync authentication
+// @Summary Get PowerSync credentials
+// @Description Issue a token for PowerSync, for the client connector's fetchCredentials. It carries the same claims as the access token but the PowerSync audience, so it is not accepted by this API and the access token is not accepted by PowerSync.
 // @Tags auth
 // @Produce json
-// @Param Authorization header string true "Bearer token"
-// @Success 200 {object} map[string]interface{}
+// @Security BearerAuth
+// @Success 200 {object} models.PowerSyncCredentials
 // @Failure 401 {object} map[string]string
 // @Router /auth/powersync [get]
 func (h *AuthHandlerStruct) PowerSyncAuth(c *gin.Context) {
-	authHeader := c.GetHeader("Authorization")
-	if authHeader == "" {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization header required"})
+	session, err := h.sessionRepo.GetActive(c.GetString("session_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to validate session"})
 		return
 	}
-
-	tokenString := ""
-	if len(authHeader) > 7 && authHeader[:7] == "Bearer " {
-		tokenString = authHeader[7:]
-	} else {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid authorization header format"})
+	if session == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Session required"})
 		return
 	}
 
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, jwt.ErrSignatureInvalid
-		}
-		return []byte(h.jwtConfig.Secret), nil
-	})
-
+	user, err := h.userRepo.GetByID(c.GetString("user_id"))
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+		log.Printf("Error fetching user: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch user"})
+		return
+	}
+	if user == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
 		return
 	}
 
-	if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-		c.JSON(http.StatusOK, gin.H{
-			"user_id":  claims["user_id"],
-			"username": claims["username"],
-			"role":     claims["role"],
-			"valid":    true,
-		})
-	} else {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token claims"})
+	now := time.Now()
+	token, err := h.signToken(user, session, h.jwtConfig.PowerSyncAudience, now)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
 	}
+
+	c.JSON(http.StatusOK, models.PowerSyncCredentials{
+		Endpoint:  h.jwtConfig.PowerSyncAudience,
+		Token:     token,
+		ExpiresAt: now.Add(h.accessTokenDuration),
+	})
 }
 
 // Me godoc
@@ -455,4 +859,83 @@ func (h *AuthHandlerStruct) Me(c *gin.Context) {
 
 	user.Password = ""
 	c.JSON(http.StatusOK, user)
-}
\ No newline at end of file
+}
+
+// generateAccessToken signs an access token for a session, valid for this
+// API only.
+func (h *AuthHandlerStruct) generateAccessToken(user *models.User, session *models.UserSession, now time.Time) (string, error) {
+	return h.signToken(user, session, h.jwtConfig.Audience, now)
+}
+
+// signToken signs a token for a session with the given audience. Besides the
+// identity claims it carries the parameter claims PowerSync uses to pick the
+// buckets this device syncs.
+func (h *AuthHandlerStruct) signToken(user *models.User, session *models.UserSession, audience string, now time.Time) (string, error) {
+	claims := jwt.MapClaims{
+		"sub":      user.ID,
+		"aud":      audience,
+		"iss":      h.jwtConfig.Issuer,
+		"jti":      ids.New(),
+		"nbf":      now.Unix(),
+		"user_id":  user.ID,
+		"username": user.Username,
+		"role":     user.Role,
+		"sid":      session.ID,
+		"iat":      now.Unix(),
+		"exp":      now.Add(h.accessTokenDuration).Unix(),
+	}
+
+	if user.MustChangePassword {
+		claims["pwd_change"] = true
+	}
+
+	if session.AuthMethod == models.AuthMethodPIN {
+		claims["scope"] = rbac.ScopePIN
+	}
+
+	if !user.TwoFactorEnabled() {
+		required, err := h.twoFactorRepo.IsRequired(user.Role)
+		if err != nil {
+			return "", err
+		}
+		if required {
+			claims["mfa_enroll"] = true
+		}
+	}
+
+	subject := syncrules.Subject{UserID: user.ID}
+	if session.DeviceID != nil {
+		subject.DeviceID = *session.DeviceID
+	}
+
+	storeID, err := h.sessionStore(user, session)
+	if err != nil {
+		return "", err
+	}
+	subject.StoreID = storeID
+	for name, value := range syncrules.Default.Claims(subject) {
+		claims[name] = value
+	}
+
+	return h.keys.Sign(claims)
+}
+
+// sessionStore returns the store a session works in: the user's own store, or
+// for users without one the store of the session's device.
+func (h *AuthHandlerStruct) sessionStore(user *models.User, session *models.UserSession) (string, error) {
+	if user.StoreID != nil {
+		return *user.StoreID, nil
+	}
+
+	if session.DeviceID != nil {
+		device, err := h.deviceRepo.GetByID(*session.DeviceID)
+		if err != nil {
+			return "", err
+		}
+		if device != nil && device.StoreID != nil {
+			return *device.StoreID, nil
+		}
+	}
+
+	return "", nil
+}
@@ -0,0 +1,322 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"pwa-backend/internal/ids"
+	"pwa-backend/internal/models"
+	"pwa-backend/internal/repositories"
+)
+
+type DeviceHandler struct {
+	deviceRepo  *repositories.DeviceRepository
+	sessionRepo *repositories.UserSessionRepository
+}
+
+func NewDeviceHandler(deviceRepo *repositories.DeviceRepository, sessionRepo *repositories.UserSessionRepository) *DeviceHandler {
+	return &DeviceHandler{
+		deviceRepo:  deviceRepo,
+		sessionRepo: sessionRepo,
+	}
+}
+
+// RegisterDevice godoc
+// @Summary Register device
+// @Description Register a POS till, tablet or browser so users can log in on it. The returned id is sent as device_id on login. Callers with a store register devices in that store; admins without one may name any store or leave it unassigned.
+// @Tags devices
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body models.RegisterDeviceRequest true "Device data"
+// @Success 201 {object} models.Device
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /devices [post]
+func (h *DeviceHandler) RegisterDevice(c *gin.Context) {
+	var req models.RegisterDeviceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	deviceID := ids.New()
+	if req.ID != "" {
+		if err := ids.Validate(req.ID); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid device id: " + err.Error()})
+			return
+		}
+		deviceID = ids.Normalize(req.ID)
+	}
+
+	storeID, err := deviceStore(c, req.StoreID)
+	if err != nil {
+		respondError(c, err, "Failed to resolve store")
+		return
+	}
+
+	userID := c.GetString("user_id")
+	now := time.Now()
+
+	device := &models.Device{
+		ID:           deviceID,
+		Name:         req.Name,
+		StoreID:      storeID,
+		RegisteredBy: &userID,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+
+	if err := h.deviceRepo.Create(device); err != nil {
+		if repositories.IsUniqueViolation(err) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Device already registered: " + deviceID})
+			return
+		}
+		if repositories.IsForeignKeyViolation(err) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Store not found: " + *device.StoreID})
+			return
+		}
+		log.Printf("Failed to register device: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to register device"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, device)
+}
+
+// GetDevices godoc
+// @Summary Get devices
+// @Description Get registered devices of the caller's store. Admins without a store see every device unless they pass store_id. Deactivated devices are hidden unless include_inactive is set.
+// @Tags devices
+// @Produce json
+// @Security BearerAuth
+// @Param store_id query string false "Store (admins only)"
+// @Param include_inactive query bool false "Include deactivated devices" default(false)
+// @Success 200 {array} models.Device
+// @Router /devices [get]
+func (h *DeviceHandler) GetDevices(c *gin.Context) {
+	scope, err := storeScope(c)
+	if err != nil {
+		respondError(c, err, "Failed to resolve store")
+		return
+	}
+
+	includeInactive := c.Query("include_inactive") == "true"
+
+	devices, err := h.deviceRepo.GetAll(scope, includeInactive)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch devices"})
+		return
+	}
+
+	c.JSON(http.StatusOK, devices)
+}
+
+// GetDevice godoc
+// @Summary Get device by ID
+// @Tags devices
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Device ID"
+// @Success 200 {object} models.Device
+// @Failure 404 {object} map[string]string
+// @Router /devices/{id} [get]
+func (h *DeviceHandler) GetDevice(c *gin.Context) {
+	device, ok := h.getDevice(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, device)
+}
+
+// UpdateDevice godoc
+// @Summary Update device
+// @Description Rename a device or assign it to another store. An empty store_id unassigns it. Callers with a store can only keep devices in that store. Moving a device to another store revokes its sessions, since their tokens carry the old store.
+// @Tags devices
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Device ID"
+// @Param request body models.UpdateDeviceRequest true "Fields to update"
+// @Success 200 {object} models.Device
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /devices/{id} [patch]
+func (h *DeviceHandler) UpdateDevice(c *gin.Context) {
+	var req models.UpdateDeviceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	device, ok := h.getDevice(c)
+	if !ok {
+		return
+	}
+
+	if !device.IsActive() {
+		c.JSON(http.StatusConflict, gin.H{"error": "Device is deactivated"})
+		return
+	}
+
+	previousStoreID := device.StoreID
+
+	if req.Name != nil {
+		device.Name = *req.Name
+	}
+	if req.StoreID != nil {
+		storeID, err := deviceStore(c, req.StoreID)
+		if err != nil {
+			respondError(c, err, "Failed to resolve store")
+			return
+		}
+		device.StoreID = storeID
+	}
+	device.UpdatedAt = time.Now()
+
+	tx, err := h.deviceRepo.BeginTx()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+		return
+	}
+	defer tx.Rollback()
+
+	if err := h.deviceRepo.Update(tx, device); err != nil {
+		if repositories.IsForeignKeyViolation(err) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Store not found: " + *device.StoreID})
+			return
+		}
+		log.Printf("Failed to update device: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update device"})
+		return
+	}
+
+	// Sessions on the device got their store claim from the old store, so
+	// they must log in again to pick up the new one.
+	if !sameStore(previousStoreID, device.StoreID) {
+		if err := h.sessionRepo.RevokeAllByDeviceID(tx, device.ID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke device sessions"})
+			return
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit"})
+		return
+	}
+
+	c.JSON(http.StatusOK, device)
+}
+
+// DeactivateDevice godoc
+// @Summary Deactivate device
+// @Description Deactivate a lost or retired device. Every session bound to it is revoked and it can no longer be used to log in.
+// @Tags devices
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Device ID"
+// @Success 200 {object} models.Device
+// @Failure 404 {object} map[string]string
+// @Router /devices/{id} [delete]
+func (h *DeviceHandler) DeactivateDevice(c *gin.Context) {
+	device, ok := h.getDevice(c)
+	if !ok {
+		return
+	}
+
+	tx, err := h.deviceRepo.BeginTx()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+		return
+	}
+	defer tx.Rollback()
+
+	if err := h.deviceRepo.Deactivate(tx, device.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to deactivate device"})
+		return
+	}
+
+	if err := h.sessionRepo.RevokeAllByDeviceID(tx, device.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke device sessions"})
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit"})
+		return
+	}
+
+	device, ok = h.getDevice(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, device)
+}
+
+// getDevice loads the device named in the path. Devices outside the caller's
+// store are reported as not found.
+func (h *DeviceHandler) getDevice(c *gin.Context) (*models.Device, bool) {
+	scope, err := storeScope(c)
+	if err != nil {
+		respondError(c, err, "Failed to resolve store")
+		return nil, false
+	}
+
+	device, err := h.deviceRepo.GetByID(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch device"})
+		return nil, false
+	}
+
+	if device == nil || (scope != nil && !sameStore(scope, device.StoreID)) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Device not found"})
+		return nil, false
+	}
+
+	return device, true
+}
+
+// deviceStore returns the store a device is assigned to by a write. Callers
+// with a store can only assign their own; admins without one may name any
+// store, or none to leave the device unassigned.
+func deviceStore(c *gin.Context, requested *string) (*string, error) {
+	storeID := ""
+	if requested != nil {
+		storeID = *requested
+	}
+	return scopeFor(c.GetString("store_id"), c.GetString("role"), storeID)
+}
+
+// contextDeviceID returns the device of the authenticated session, or nil
+// when the session is not bound to a device.
+func contextDeviceID(c *gin.Context) *string {
+	deviceID := c.GetString("device_id")
+	if deviceID == "" {
+		return nil
+	}
+	return &deviceID
+}
+
+// stockEventSource is the source of a stock event written for a session: the
+// POS when the session is bound to a device, the dashboard otherwise.
+func stockEventSource(deviceID *string) string {
+	if deviceID != nil {
+		return "pos"
+	}
+	return "dashboard"
+}
+
+func emptyToNil(s *string) *string {
+	if s == nil || *s == "" {
+		return nil
+	}
+	return s
+}
@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// requestError is a business-rule failure raised by logic shared between the
+// REST handlers and the PowerSync upload endpoint. Any other error returned by
+// that logic is an internal failure.
+type requestError struct {
+	status  int
+	message string
+	// body replaces the default {"error": message} response when set.
+	body interface{}
+}
+
+func newRequestError(status int, message string) *requestError {
+	return &requestError{status: status, message: message}
+}
+
+func (e *requestError) Error() string {
+	return e.message
+}
+
+// respondError writes a requestError as its own status and body, and anything
+// else as a 500 with internalMessage.
+func respondError(c *gin.Context, err error, internalMessage string) {
+	var reqErr *requestError
+	if errors.As(err, &reqErr) {
+		if reqErr.body != nil {
+			c.JSON(reqErr.status, reqErr.body)
+		} else {
+			c.JSON(reqErr.status, gin.H{"error": reqErr.message})
+		}
+		return
+	}
+
+	log.Printf("%s: %v", internalMessage, err)
+	c.JSON(http.StatusInternalServerError, gin.H{"error": internalMessage})
+}
@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"pwa-backend/internal/ids"
+	"pwa-backend/internal/models"
+	"pwa-backend/internal/repositories"
+)
+
+// InviteHandler holds the admin endpoints for registration invites.
+type InviteHandler struct {
+	inviteRepo     *repositories.InviteRepository
+	inviteDuration time.Duration
+}
+
+func NewInviteHandler(inviteRepo *repositories.InviteRepository, inviteDuration time.Duration) *InviteHandler {
+	return &InviteHandler{
+		inviteRepo:     inviteRepo,
+		inviteDuration: inviteDuration,
+	}
+}
+
+// CreateInvite godoc
+// @Summary Create invite
+// @Description Admin only. Create a single-use invite that registers an account with the given role and store. The token is returned only in this response; send it as invite_token to /auth/register.
+// @Tags invites
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body models.CreateInviteRequest true "Role and store of the new account"
+// @Success 201 {object} models.InviteResponse
+// @Failure 400 {object} map[string]string
+// @Router /invites [post]
+func (h *InviteHandler) CreateInvite(c *gin.Context) {
+	var req models.CreateInviteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	duration := h.inviteDuration
+	if req.ExpiresInHours > 0 {
+		duration = time.Duration(req.ExpiresInHours) * time.Hour
+	}
+
+	userID := c.GetString("user_id")
+	now := time.Now()
+	token := ids.NewSecret()
+
+	invite := models.Invite{
+		ID:        ids.New(),
+		Role:      req.Role,
+		StoreID:   emptyToNil(req.StoreID),
+		CreatedBy: &userID,
+		ExpiresAt: now.Add(duration),
+		CreatedAt: now,
+	}
+
+	if err := h.inviteRepo.Create(&invite, ids.HashSecret(token)); err != nil {
+		if repositories.IsForeignKeyViolation(err) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Store not found: " + *invite.StoreID})
+			return
+		}
+		log.Printf("Failed to create invite: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create invite"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, models.InviteResponse{
+		Invite: invite,
+		Token:  token,
+	})
+}
+
+// GetInvites godoc
+// @Summary List invites
+// @Description Admin only. List open invites, newest first. Used, revoked and expired invites are included when include_inactive is set.
+// @Tags invites
+// @Produce json
+// @Security BearerAuth
+// @Param include_inactive query bool false "Include used, revoked and expired invites" default(false)
+// @Success 200 {array} models.Invite
+// @Router /invites [get]
+func (h *InviteHandler) GetInvites(c *gin.Context) {
+	includeInactive := c.Query("include_inactive") == "true"
+
+	invites, err := h.inviteRepo.GetAll(includeInactive)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch invites"})
+		return
+	}
+
+	c.JSON(http.StatusOK, invites)
+}
+
+// RevokeInvite godoc
+// @Summary Revoke invite
+// @Description Admin only. Cancel an invite that has not been used yet.
+// @Tags invites
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Invite ID"
+// @Success 200 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /invites/{id} [delete]
+func (h *InviteHandler) RevokeInvite(c *gin.Context) {
+	invite, err := h.inviteRepo.GetByID(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch invite"})
+		return
+	}
+
+	if invite == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Invite not found"})
+		return
+	}
+
+	revoked, err := h.inviteRepo.Revoke(invite.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke invite"})
+		return
+	}
+
+	if !revoked {
+		c.JSON(http.StatusConflict, gin.H{"error": "Invite was already used or revoked"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Invite revoked"})
+}
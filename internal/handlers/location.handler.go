@@ -0,0 +1,219 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"pwa-backend/internal/ids"
+	"pwa-backend/internal/models"
+	"pwa-backend/internal/repositories"
+)
+
+type LocationHandler struct {
+	locationRepo *repositories.LocationRepository
+}
+
+func NewLocationHandler(locationRepo *repositories.LocationRepository) *LocationHandler {
+	return &LocationHandler{locationRepo: locationRepo}
+}
+
+// CreateLocation godoc
+// @Summary Create stock location
+// @Description Add a stock location, such as a back room, to the caller's store. Admins without a store must pass store_id.
+// @Tags locations
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body models.CreateLocationRequest true "Location data"
+// @Success 201 {object} models.Location
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /locations [post]
+func (h *LocationHandler) CreateLocation(c *gin.Context) {
+	var req models.CreateLocationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	storeID, err := writeStore(c, req.StoreID)
+	if err != nil {
+		respondError(c, err, "Failed to resolve store")
+		return
+	}
+
+	locationID := ids.New()
+	if req.ID != "" {
+		if err := ids.Validate(req.ID); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid location id: " + err.Error()})
+			return
+		}
+		locationID = ids.Normalize(req.ID)
+	}
+
+	now := time.Now()
+	location := &models.Location{
+		ID:        locationID,
+		StoreID:   storeID,
+		Name:      req.Name,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	if err := h.locationRepo.Create(location); err != nil {
+		if repositories.IsUniqueViolation(err) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Location already exists: " + req.Name})
+			return
+		}
+		if repositories.IsForeignKeyViolation(err) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Store not found: " + storeID})
+			return
+		}
+		log.Printf("Failed to create location: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create location"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, location)
+}
+
+// GetLocations godoc
+// @Summary Get stock locations
+// @Description Get the stock locations of the caller's store, default first. Admins without a store see every store unless they pass store_id.
+// @Tags locations
+// @Produce json
+// @Security BearerAuth
+// @Param store_id query string false "Store (admins only)"
+// @Success 200 {array} models.Location
+// @Router /locations [get]
+func (h *LocationHandler) GetLocations(c *gin.Context) {
+	scope, err := storeScope(c)
+	if err != nil {
+		respondError(c, err, "Failed to resolve store")
+		return
+	}
+
+	locations, err := h.locationRepo.GetAll(scope)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch locations"})
+		return
+	}
+
+	c.JSON(http.StatusOK, locations)
+}
+
+// GetLocationStocks godoc
+// @Summary Get stock at a location
+// @Description Get the stock of every product held at a location
+// @Tags locations
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Location ID"
+// @Success 200 {array} models.LocationStock
+// @Failure 404 {object} map[string]string
+// @Router /locations/{id}/stocks [get]
+func (h *LocationHandler) GetLocationStocks(c *gin.Context) {
+	scope, err := storeScope(c)
+	if err != nil {
+		respondError(c, err, "Failed to resolve store")
+		return
+	}
+
+	location, ok := h.getLocation(c)
+	if !ok {
+		return
+	}
+
+	if !inScope(scope, location.StoreID) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Location not found"})
+		return
+	}
+
+	stocks, err := h.locationRepo.GetStocks(location.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch stock"})
+		return
+	}
+
+	c.JSON(http.StatusOK, stocks)
+}
+
+// UpdateLocation godoc
+// @Summary Update stock location
+// @Description Rename a location or make it the default location of its store. Sales, cancellations and returns use the default location.
+// @Tags locations
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Location ID"
+// @Param request body models.UpdateLocationRequest true "Fields to update"
+// @Success 200 {object} models.Location
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /locations/{id} [patch]
+func (h *LocationHandler) UpdateLocation(c *gin.Context) {
+	var req models.UpdateLocationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	location, ok := h.getLocation(c)
+	if !ok {
+		return
+	}
+
+	if _, err := writeStore(c, location.StoreID); err != nil {
+		respondError(c, err, "Failed to resolve store")
+		return
+	}
+
+	if req.IsDefault != nil && !*req.IsDefault && location.IsDefault {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Make another location the default instead"})
+		return
+	}
+
+	if req.Name != nil {
+		location.Name = *req.Name
+		location.UpdatedAt = time.Now()
+
+		if err := h.locationRepo.Update(location); err != nil {
+			if repositories.IsUniqueViolation(err) {
+				c.JSON(http.StatusConflict, gin.H{"error": "Location already exists: " + location.Name})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update location"})
+			return
+		}
+	}
+
+	if req.IsDefault != nil && *req.IsDefault && !location.IsDefault {
+		if err := h.locationRepo.SetDefault(location); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to set default location"})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, location)
+}
+
+func (h *LocationHandler) getLocation(c *gin.Context) (*models.Location, bool) {
+	location, err := h.locationRepo.GetByID(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch location"})
+		return nil, false
+	}
+
+	if location == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Location not found"})
+		return nil, false
+	}
+
+	return location, true
+}
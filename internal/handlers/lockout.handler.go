@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"pwa-backend/internal/loginlimit"
+)
+
+type LockoutHandler struct {
+	limiter *loginlimit.Limiter
+}
+
+func NewLockoutHandler(limiter *loginlimit.Limiter) *LockoutHandler {
+	return &LockoutHandler{limiter: limiter}
+}
+
+// GetLockouts godoc
+// @Summary List login lockouts
+// @Description Admin only. List usernames and client IPs that are locked out after too many failed logins.
+// @Tags lockouts
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {array} loginlimit.Entry
+// @Router /lockouts [get]
+func (h *LockoutHandler) GetLockouts(c *gin.Context) {
+	entries, err := h.limiter.Locked()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch lockouts"})
+		return
+	}
+
+	if entries == nil {
+		entries = []loginlimit.Entry{}
+	}
+
+	c.JSON(http.StatusOK, entries)
+}
+
+// Unlock godoc
+// @Summary Lift a login lockout
+// @Description Admin only. Clear the failed login counter of a username or client IP.
+// @Tags lockouts
+// @Produce json
+// @Security BearerAuth
+// @Param kind path string true "username, ip, pin:username or pin:ip"
+// @Param value path string true "Username or IP address"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Router /lockouts/{kind}/{value} [delete]
+func (h *LockoutHandler) Unlock(c *gin.Context) {
+	key := loginlimit.Key{Kind: c.Param("kind"), Value: c.Param("value")}
+	if !loginlimit.IsValidKind(key.Kind) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "kind must be username or ip, optionally prefixed with a scope such as pin:"})
+		return
+	}
+
+	if err := h.limiter.Unlock(key); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unlock"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Unlocked"})
+}
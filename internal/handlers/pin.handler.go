@@ -0,0 +1,203 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+
+	"pwa-backend/internal/models"
+	"pwa-backend/internal/password"
+)
+
+// PINLogin godoc
+// @Summary Login with a PIN
+// @Description Quick login for cashiers at a shared terminal. Only works from an active device registered to a store, and only for users without a pending password change or two-factor authentication. The session is bound to the device, lasts one shift and its tokens have the restricted "pin" scope: sales and stock lookups only, no administration. Failures are counted separately from password logins.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body models.PINLoginRequest true "Device, username and PIN"
+// @Success 200 {object} models.TokenResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 429 {object} map[string]interface{}
+// @Router /auth/pin-login [post]
+func (h *AuthHandlerStruct) PINLogin(c *gin.Context) {
+	var req models.PINLoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	device, ok := h.getActiveDevice(c, req.DeviceID)
+	if !ok {
+		return
+	}
+
+	if device.StoreID == nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Device is not assigned to a store"})
+		return
+	}
+
+	clientIP := c.ClientIP()
+
+	attempt, wait, err := h.pinLimiter.Reserve(req.Username, clientIP)
+	if err != nil {
+		log.Printf("Failed to check PIN login attempts: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+
+	if wait > 0 {
+		respondTooManyAttempts(c, wait)
+		return
+	}
+
+	user, err := h.userRepo.GetByUsername(req.Username)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+
+	if user == nil || user.PINHash == nil || bcrypt.CompareHashAndPassword([]byte(*user.PINHash), []byte(req.PIN)) != nil {
+		if err := attempt.Fail(); err != nil {
+			log.Printf("Failed to record PIN login failure: %v", err)
+		}
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+		return
+	}
+
+	if err := attempt.Release(); err != nil {
+		log.Printf("Failed to release login attempt: %v", err)
+	}
+
+	// A PIN never stands in for a stronger check the account owes.
+	if user.MustChangePassword || user.TwoFactorEnabled() {
+		c.JSON(http.StatusForbidden, gin.H{"error": "PIN login is not available for this account, login with password"})
+		return
+	}
+
+	required, err := h.twoFactorRepo.IsRequired(user.Role)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch two-factor policy"})
+		return
+	}
+
+	if required {
+		c.JSON(http.StatusForbidden, gin.H{"error": "PIN login is not available for this account, login with password"})
+		return
+	}
+
+	if !checkDeviceStore(c, user, device) {
+		return
+	}
+
+	if err := h.pinLimiter.RecordSuccess(req.Username); err != nil {
+		log.Printf("Failed to reset PIN login failures: %v", err)
+	}
+
+	h.startSession(c, user, &device.ID, models.AuthMethodPIN)
+}
+
+// SetPIN godoc
+// @Summary Set login PIN
+// @Description Set or replace the authenticated user's PIN for quick login on store devices. Requires the current password and a full (non-PIN) login. The PIN is 4 to 8 digits and may not be a single repeated digit or a run such as 1234.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body models.SetPINRequest true "Password and new PIN"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 429 {object} map[string]interface{}
+// @Router /auth/pin [put]
+func (h *AuthHandlerStruct) SetPIN(c *gin.Context) {
+	var req models.SetPINRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, ok := h.currentUser(c)
+	if !ok {
+		return
+	}
+
+	clientIP := c.ClientIP()
+	attempt, wait, err := h.loginLimiter.Reserve(user.Username, clientIP)
+	if err != nil {
+		log.Printf("Failed to check login attempts: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+
+	if wait > 0 {
+		respondTooManyAttempts(c, wait)
+		return
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)); err != nil {
+		if err := attempt.Fail(); err != nil {
+			log.Printf("Failed to record login failure: %v", err)
+		}
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Password is incorrect"})
+		return
+	}
+
+	if err := attempt.Release(); err != nil {
+		log.Printf("Failed to release login attempt: %v", err)
+	}
+
+	if err := password.ValidatePIN(req.PIN); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	hash, err := password.Hash(req.PIN)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash PIN"})
+		return
+	}
+
+	if err := h.userRepo.SetPIN(user.ID, &hash); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to set PIN"})
+		return
+	}
+
+	// Sessions opened with the old PIN end with it.
+	if err := h.sessionRepo.RevokeByAuthMethod(user.ID, models.AuthMethodPIN); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke PIN sessions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "PIN set"})
+}
+
+// RemovePIN godoc
+// @Summary Remove login PIN
+// @Description Remove the authenticated user's PIN and revoke every session opened with it.
+// @Tags auth
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Router /auth/pin [delete]
+func (h *AuthHandlerStruct) RemovePIN(c *gin.Context) {
+	userID := c.GetString("user_id")
+
+	if err := h.userRepo.SetPIN(userID, nil); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove PIN"})
+		return
+	}
+
+	if err := h.sessionRepo.RevokeByAuthMethod(userID, models.AuthMethodPIN); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke PIN sessions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "PIN removed"})
+}
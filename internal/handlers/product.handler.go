@@ -1,31 +1,53 @@
 package handlers
 
 import (
+	"fmt"
+	"log"
 	"net/http"
+	"pwa-backend/internal/ids"
+	"pwa-backend/internal/models"
+	"pwa-backend/internal/money"
+	"pwa-backend/internal/rbac"
 	"pwa-backend/internal/repositories"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
 type ProductHandler struct {
-	productRepo *repositories.ProductRepository
+	productRepo    *repositories.ProductRepository
+	stockEventRepo *repositories.StockEventRepository
 }
 
-func NewProductHandler(productRepo *repositories.ProductRepository) *ProductHandler {
-	return &ProductHandler{productRepo: productRepo}
+func NewProductHandler(productRepo *repositories.ProductRepository, stockEventRepo *repositories.StockEventRepository) *ProductHandler {
+	return &ProductHandler{
+		productRepo:    productRepo,
+		stockEventRepo: stockEventRepo,
+	}
 }
 
 // GetProducts godoc
 // @Summary Get all products
-// @Description Get list of all products (read-only)
+// @Description Get list of products. Archived products are hidden unless include_archived is set. Stock is the stock of the caller's store; admins without a store get the total over all stores unless they pass store_id.
 // @Tags products
 // @Produce json
 // @Security BearerAuth
+// @Param include_archived query bool false "Include archived products" default(false)
+// @Param store_id query string false "Store to report stock for (admins only)"
 // @Success 200 {array} models.Product
+// @Failure 403 {object} map[string]string
 // @Failure 500 {object} map[string]string
 // @Router /products [get]
 func (h *ProductHandler) GetProducts(c *gin.Context) {
-	products, err := h.productRepo.GetAll()
+	includeArchived := c.Query("include_archived") == "true"
+
+	scope, err := storeScope(c)
+	if err != nil {
+		respondError(c, err, "Failed to resolve store")
+		return
+	}
+
+	products, err := h.productRepo.GetAll(includeArchived, scope)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch products"})
 		return
@@ -36,27 +58,344 @@ func (h *ProductHandler) GetProducts(c *gin.Context) {
 
 // GetProductByID godoc
 // @Summary Get product by ID
-// @Description Get single product by ID (read-only)
+// @Description Get single product by ID, including archived products. Stock is reported like in the product list.
 // @Tags products
 // @Produce json
 // @Security BearerAuth
 // @Param id path string true "Product ID"
+// @Param store_id query string false "Store to report stock for (admins only)"
 // @Success 200 {object} models.Product
 // @Failure 404 {object} map[string]string
 // @Router /products/{id} [get]
 func (h *ProductHandler) GetProductByID(c *gin.Context) {
-	id := c.Param("id")
+	product, ok := h.getProduct(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, product)
+}
+
+// GetProductStocks godoc
+// @Summary Get stock per store
+// @Description Get the stock of a product in every store. Callers assigned to a store only see their own store.
+// @Tags products
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Product ID"
+// @Success 200 {array} models.ProductStock
+// @Failure 404 {object} map[string]string
+// @Router /products/{id}/stocks [get]
+func (h *ProductHandler) GetProductStocks(c *gin.Context) {
+	product, ok := h.getProduct(c)
+	if !ok {
+		return
+	}
+
+	scope, err := storeScope(c)
+	if err != nil {
+		respondError(c, err, "Failed to resolve store")
+		return
+	}
+
+	stocks, err := h.productRepo.GetStocks(product.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch stock"})
+		return
+	}
+
+	visible := []models.ProductStock{}
+	for _, stock := range stocks {
+		if inScope(scope, stock.StoreID) {
+			visible = append(visible, stock)
+		}
+	}
+
+	c.JSON(http.StatusOK, visible)
+}
+
+// CreateProduct godoc
+// @Summary Create product
+// @Description Create a product. Initial stock is recorded as an opening_stock stock event in the caller's store, or in store_id for admins without a store.
+// @Tags products
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body models.CreateProductRequest true "Product data"
+// @Success 201 {object} models.Product
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /products [post]
+func (h *ProductHandler) CreateProduct(c *gin.Context) {
+	var req models.CreateProductRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !validPrice(c, req.Price) {
+		return
+	}
+
+	if req.InitialStock > 0 && !rbac.CanInScope(c.GetString("role"), c.GetString("scope"), rbac.StockEventsAdjust) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Permission denied for opening stock"})
+		return
+	}
+
+	var storeID string
+	if req.InitialStock > 0 {
+		var err error
+		storeID, err = writeStore(c, req.StoreID)
+		if err != nil {
+			respondError(c, err, "Failed to resolve store")
+			return
+		}
+	}
+
+	userID := c.GetString("user_id")
+	now := time.Now()
 
-	product, err := h.productRepo.GetByID(id)
+	product := &models.Product{
+		ID:          ids.New(),
+		Name:        req.Name,
+		Description: req.Description,
+		Price:       req.Price,
+		ImageURL:    req.ImageURL,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+
+	tx, err := h.productRepo.BeginTx()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+		return
+	}
+	defer tx.Rollback()
+
+	if err := h.productRepo.Create(tx, product); err != nil {
+		log.Printf("Failed to create product: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create product"})
+		return
+	}
+
+	if req.InitialStock > 0 {
+		stockEvent := &models.StockEvent{
+			ID:        ids.New(),
+			StoreID:   storeID,
+			ProductID: product.ID,
+			Qty:       req.InitialStock,
+			Type:      "opening_stock",
+			Source:    "dashboard",
+			UserID:    &userID,
+			Note:      fmt.Sprintf("Opening stock for product %s", product.Name),
+			CreatedAt: now,
+		}
+
+		if err := h.stockEventRepo.Create(tx, stockEvent); err != nil {
+			if repositories.IsForeignKeyViolation(err) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Store not found: " + storeID})
+				return
+			}
+			log.Printf("Failed to create stock event: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create stock event"})
+			return
+		}
+
+		if err := h.productRepo.UpdateStockByQty(tx, storeID, stockEvent.LocationID, product.ID, req.InitialStock); err != nil {
+			log.Printf("Failed to update product stock: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update product stock"})
+			return
+		}
+
+		product.Stock = req.InitialStock
+		product.StoreID = &storeID
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, product)
+}
+
+// UpdateProduct godoc
+// @Summary Replace product details
+// @Description Replace the catalog fields of a product. Stock can only change through stock events.
+// @Tags products
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Product ID"
+// @Param request body models.UpdateProductRequest true "Product data"
+// @Success 200 {object} models.Product
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /products/{id} [put]
+func (h *ProductHandler) UpdateProduct(c *gin.Context) {
+	var req models.UpdateProductRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !validPrice(c, req.Price) {
+		return
+	}
+
+	product, ok := h.getEditableProduct(c)
+	if !ok {
+		return
+	}
+
+	product.Name = req.Name
+	product.Description = req.Description
+	product.Price = req.Price
+	product.ImageURL = req.ImageURL
+
+	h.saveProduct(c, product)
+}
+
+// PatchProduct godoc
+// @Summary Update product details
+// @Description Update only the provided catalog fields of a product
+// @Tags products
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Product ID"
+// @Param request body models.PatchProductRequest true "Fields to update"
+// @Success 200 {object} models.Product
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /products/{id} [patch]
+func (h *ProductHandler) PatchProduct(c *gin.Context) {
+	var req models.PatchProductRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.Price != nil && !validPrice(c, *req.Price) {
+		return
+	}
+
+	product, ok := h.getEditableProduct(c)
+	if !ok {
+		return
+	}
+
+	if req.Name != nil {
+		product.Name = *req.Name
+	}
+	if req.Description != nil {
+		product.Description = *req.Description
+	}
+	if req.Price != nil {
+		product.Price = *req.Price
+	}
+	if req.ImageURL != nil {
+		product.ImageURL = *req.ImageURL
+	}
+
+	h.saveProduct(c, product)
+}
+
+// ArchiveProduct godoc
+// @Summary Archive product
+// @Description Soft-delete a product. It disappears from the catalog and can no longer be sold, but its sales and stock history are kept.
+// @Tags products
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Product ID"
+// @Success 200 {object} models.Product
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /products/{id} [delete]
+func (h *ProductHandler) ArchiveProduct(c *gin.Context) {
+	product, ok := h.getProduct(c)
+	if !ok {
+		return
+	}
+
+	if err := h.productRepo.Archive(product.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to archive product"})
+		return
+	}
+
+	product, err := h.productRepo.GetByID(product.ID, product.StoreID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch product"})
 		return
 	}
 
+	c.JSON(http.StatusOK, product)
+}
+
+// validPrice writes a 400 response and returns false unless price is a
+// positive amount in the store currency.
+func validPrice(c *gin.Context, price money.Money) bool {
+	if !price.IsPositive() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Price must be greater than 0"})
+		return false
+	}
+
+	if price.Currency() != money.DefaultCurrency {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Price currency must be " + money.DefaultCurrency})
+		return false
+	}
+
+	return true
+}
+
+// getProduct loads the product in the id path parameter with the stock of
+// the caller's store scope.
+func (h *ProductHandler) getProduct(c *gin.Context) (*models.Product, bool) {
+	scope, err := storeScope(c)
+	if err != nil {
+		respondError(c, err, "Failed to resolve store")
+		return nil, false
+	}
+
+	product, err := h.productRepo.GetByID(c.Param("id"), scope)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch product"})
+		return nil, false
+	}
+
 	if product == nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+		return nil, false
+	}
+
+	return product, true
+}
+
+func (h *ProductHandler) getEditableProduct(c *gin.Context) (*models.Product, bool) {
+	product, ok := h.getProduct(c)
+	if !ok {
+		return nil, false
+	}
+
+	if product.ArchivedAt != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Product is archived"})
+		return nil, false
+	}
+
+	return product, true
+}
+
+func (h *ProductHandler) saveProduct(c *gin.Context, product *models.Product) {
+	product.UpdatedAt = time.Now()
+
+	if err := h.productRepo.Update(product); err != nil {
+		log.Printf("Failed to update product: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update product"})
 		return
 	}
 
 	c.JSON(http.StatusOK, product)
-}
\ No newline at end of file
+}
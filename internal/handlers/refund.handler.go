@@ -0,0 +1,298 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"pwa-backend/internal/ids"
+	"pwa-backend/internal/models"
+	"pwa-backend/internal/money"
+	"pwa-backend/internal/repositories"
+)
+
+type RefundHandler struct {
+	refundRepo      *repositories.RefundRepository
+	transactionRepo *repositories.TransactionRepository
+	productRepo     *repositories.ProductRepository
+	stockEventRepo  *repositories.StockEventRepository
+}
+
+func NewRefundHandler(refundRepo *repositories.RefundRepository, transactionRepo *repositories.TransactionRepository, productRepo *repositories.ProductRepository, stockEventRepo *repositories.StockEventRepository) *RefundHandler {
+	return &RefundHandler{
+		refundRepo:      refundRepo,
+		transactionRepo: transactionRepo,
+		productRepo:     productRepo,
+		stockEventRepo:  stockEventRepo,
+	}
+}
+
+// CreateRefund godoc
+// @Summary Refund transaction items
+// @Description Refund some or all units of a completed transaction's items. Units can never be refunded more than once; restocked units are returned to stock with a 'return' stock event. When every unit has been refunded the transaction becomes refunded.
+// @Tags refunds
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Transaction ID"
+// @Param request body models.CreateRefundRequest true "Items to refund"
+// @Success 201 {object} models.Refund
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} models.RefundConflictResponse
+// @Router /transactions/{id}/refunds [post]
+func (h *RefundHandler) CreateRefund(c *gin.Context) {
+	transactionID := c.Param("id")
+
+	var req models.CreateRefundRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	scope, err := storeScope(c)
+	if err != nil {
+		respondError(c, err, "Failed to resolve store")
+		return
+	}
+
+	userID := c.GetString("user_id")
+
+	tx, err := h.refundRepo.BeginTx()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+		return
+	}
+	defer tx.Rollback()
+
+	// Locking the transaction row serialises refunds for the same sale, so the
+	// already-refunded quantities read below stay accurate until commit.
+	transaction, err := h.transactionRepo.GetByIDForUpdate(tx, transactionID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch transaction"})
+		return
+	}
+
+	if transaction == nil || !inScope(scope, transaction.StoreID) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Transaction not found"})
+		return
+	}
+
+	if transaction.Status != models.TransactionStatusCompleted {
+		c.JSON(http.StatusConflict, gin.H{"error": "Only completed transactions can be refunded, status is " + transaction.Status})
+		return
+	}
+
+	soldItems, err := h.transactionRepo.GetItems(transactionID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch transaction items"})
+		return
+	}
+
+	soldByID := make(map[string]models.TransactionItem, len(soldItems))
+	for _, item := range soldItems {
+		soldByID[item.ID] = item
+	}
+
+	alreadyRefunded, err := h.refundRepo.RefundedQtyByItem(tx, transactionID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch previous refunds"})
+		return
+	}
+
+	requested := make(map[string]int)
+	for _, item := range req.Items {
+		if _, ok := soldByID[item.TransactionItemID]; !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Item does not belong to this transaction: " + item.TransactionItemID})
+			return
+		}
+		requested[item.TransactionItemID] += item.Quantity
+	}
+
+	var exceeded []models.RefundLimitExceeded
+	for itemID, qty := range requested {
+		sold := soldByID[itemID].Quantity
+		if alreadyRefunded[itemID]+qty > sold {
+			exceeded = append(exceeded, models.RefundLimitExceeded{
+				TransactionItemID: itemID,
+				Sold:              sold,
+				AlreadyRefunded:   alreadyRefunded[itemID],
+				Requested:         qty,
+			})
+		}
+	}
+
+	if len(exceeded) > 0 {
+		sort.Slice(exceeded, func(i, j int) bool { return exceeded[i].TransactionItemID < exceeded[j].TransactionItemID })
+		c.JSON(http.StatusConflict, models.RefundConflictResponse{
+			Error: "Refund quantity exceeds quantity sold",
+			Items: exceeded,
+		})
+		return
+	}
+
+	now := time.Now()
+	refund := &models.Refund{
+		ID:            ids.New(),
+		TransactionID: transactionID,
+		UserID:        &userID,
+		Reason:        req.Reason,
+		TotalAmount:   money.Zero(),
+		CreatedAt:     now,
+	}
+
+	restockQty := make(map[string]int)
+	for _, item := range req.Items {
+		sold := soldByID[item.TransactionItemID]
+		amount, err := sold.Price.Mul(item.Quantity)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Refund amount is too large"})
+			return
+		}
+
+		refund.TotalAmount, err = refund.TotalAmount.Add(amount)
+		if errors.Is(err, money.ErrOutOfRange) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Refund amount is too large"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot mix currencies in one refund"})
+			return
+		}
+
+		refund.Items = append(refund.Items, models.RefundItem{
+			ID:                ids.New(),
+			RefundID:          refund.ID,
+			TransactionItemID: sold.ID,
+			ProductID:         sold.ProductID,
+			Quantity:          item.Quantity,
+			Amount:            amount,
+			Restock:           item.Restock,
+			CreatedAt:         now,
+		})
+
+		if item.Restock {
+			restockQty[sold.ProductID] += item.Quantity
+		}
+	}
+
+	if err := h.refundRepo.Create(tx, refund); err != nil {
+		log.Printf("Failed to create refund: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create refund"})
+		return
+	}
+
+	if err := h.refundRepo.CreateItems(tx, refund.Items); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create refund items"})
+		return
+	}
+
+	// Update products in id order, matching the lock order used by checkout.
+	productIDs := make([]string, 0, len(restockQty))
+	for productID := range restockQty {
+		productIDs = append(productIDs, productID)
+	}
+	sort.Strings(productIDs)
+
+	deviceID := contextDeviceID(c)
+	for _, productID := range productIDs {
+		qty := restockQty[productID]
+		stockEvent := &models.StockEvent{
+			ID:            ids.New(),
+			StoreID:       transaction.StoreID,
+			ProductID:     productID,
+			Qty:           qty,
+			Type:          "return",
+			Source:        stockEventSource(deviceID),
+			TransactionID: &transactionID,
+			UserID:        &userID,
+			DeviceID:      deviceID,
+			Note:          fmt.Sprintf("Return from refund %s", refund.ID),
+			CreatedAt:     now,
+		}
+
+		if err := h.stockEventRepo.Create(tx, stockEvent); err != nil {
+			log.Printf("Failed to create stock event: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create stock event"})
+			return
+		}
+
+		if err := h.productRepo.UpdateStockByQty(tx, transaction.StoreID, stockEvent.LocationID, productID, qty); err != nil {
+			log.Printf("Failed to update product stock: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update product stock"})
+			return
+		}
+	}
+
+	if err := h.transactionRepo.AddRefundedAmount(tx, transactionID, refund.TotalAmount); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update transaction amount"})
+		return
+	}
+
+	fullyRefunded := true
+	for _, item := range soldItems {
+		if alreadyRefunded[item.ID]+requested[item.ID] < item.Quantity {
+			fullyRefunded = false
+			break
+		}
+	}
+
+	if fullyRefunded {
+		if err := h.transactionRepo.UpdateStatus(tx, transactionID, models.TransactionStatusRefunded); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update status"})
+			return
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, refund)
+}
+
+// GetRefunds godoc
+// @Summary List refunds of a transaction
+// @Description Get all refund documents for a transaction with their items
+// @Tags refunds
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Transaction ID"
+// @Success 200 {array} models.Refund
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /transactions/{id}/refunds [get]
+func (h *RefundHandler) GetRefunds(c *gin.Context) {
+	transactionID := c.Param("id")
+
+	scope, err := storeScope(c)
+	if err != nil {
+		respondError(c, err, "Failed to resolve store")
+		return
+	}
+
+	transaction, err := h.transactionRepo.GetByID(transactionID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch transaction"})
+		return
+	}
+
+	if transaction == nil || !inScope(scope, transaction.StoreID) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Transaction not found"})
+		return
+	}
+
+	refunds, err := h.refundRepo.GetByTransaction(transactionID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch refunds"})
+		return
+	}
+
+	c.JSON(http.StatusOK, refunds)
+}
@@ -0,0 +1,217 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"pwa-backend/internal/models"
+	"pwa-backend/internal/repositories"
+)
+
+// SessionHandler lets users see and revoke their own sessions, and admins do
+// the same for any user (e.g. when a till is lost). A session here is a whole
+// refresh token family, so revoking it also kills every rotated token.
+type SessionHandler struct {
+	sessionRepo *repositories.UserSessionRepository
+	userRepo    *repositories.UserRepository
+}
+
+func NewSessionHandler(sessionRepo *repositories.UserSessionRepository, userRepo *repositories.UserRepository) *SessionHandler {
+	return &SessionHandler{
+		sessionRepo: sessionRepo,
+		userRepo:    userRepo,
+	}
+}
+
+// GetMySessions godoc
+// @Summary List my sessions
+// @Description List the active sessions of the authenticated user with their device and user agent
+// @Tags sessions
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {array} models.SessionResponse
+// @Router /auth/sessions [get]
+func (h *SessionHandler) GetMySessions(c *gin.Context) {
+	h.listSessions(c, c.GetString("user_id"))
+}
+
+// RevokeMySession godoc
+// @Summary Revoke one of my sessions
+// @Tags sessions
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Session ID"
+// @Success 200 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /auth/sessions/{id} [delete]
+func (h *SessionHandler) RevokeMySession(c *gin.Context) {
+	h.revokeSession(c, c.GetString("user_id"), c.Param("id"))
+}
+
+// RevokeMyOtherSessions godoc
+// @Summary Revoke my other sessions
+// @Description Log out every session of the authenticated user except the current one
+// @Tags sessions
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} map[string]string
+// @Router /auth/sessions [delete]
+func (h *SessionHandler) RevokeMyOtherSessions(c *gin.Context) {
+	current, ok := h.currentSession(c)
+	if !ok {
+		return
+	}
+
+	if current == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Session not found"})
+		return
+	}
+
+	if err := h.sessionRepo.RevokeOthersByUserID(c.GetString("user_id"), current.FamilyID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke sessions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Other sessions revoked"})
+}
+
+// GetUserSessions godoc
+// @Summary List a user's sessions
+// @Description Admin only. List the active sessions of any user.
+// @Tags sessions
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "User ID"
+// @Success 200 {array} models.SessionResponse
+// @Failure 404 {object} map[string]string
+// @Router /users/{id}/sessions [get]
+func (h *SessionHandler) GetUserSessions(c *gin.Context) {
+	userID, ok := h.getUserID(c)
+	if !ok {
+		return
+	}
+
+	h.listSessions(c, userID)
+}
+
+// RevokeUserSession godoc
+// @Summary Revoke a user's session
+// @Description Admin only.
+// @Tags sessions
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "User ID"
+// @Param session_id path string true "Session ID"
+// @Success 200 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /users/{id}/sessions/{session_id} [delete]
+func (h *SessionHandler) RevokeUserSession(c *gin.Context) {
+	userID, ok := h.getUserID(c)
+	if !ok {
+		return
+	}
+
+	h.revokeSession(c, userID, c.Param("session_id"))
+}
+
+// RevokeUserSessions godoc
+// @Summary Revoke all of a user's sessions
+// @Description Admin only. Log the user out everywhere.
+// @Tags sessions
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "User ID"
+// @Success 200 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /users/{id}/sessions [delete]
+func (h *SessionHandler) RevokeUserSessions(c *gin.Context) {
+	userID, ok := h.getUserID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.sessionRepo.RevokeAllByUserID(userID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke sessions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "All sessions revoked"})
+}
+
+func (h *SessionHandler) listSessions(c *gin.Context, userID string) {
+	current, ok := h.currentSession(c)
+	if !ok {
+		return
+	}
+
+	sessions, err := h.sessionRepo.GetByUserID(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch sessions"})
+		return
+	}
+
+	response := make([]models.SessionResponse, 0, len(sessions))
+	for _, session := range sessions {
+		response = append(response, models.SessionResponse{
+			UserSession: *session,
+			Current:     current != nil && session.FamilyID == current.FamilyID,
+		})
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// revokeSession revokes the session family of sessionID if it belongs to
+// userID. Sessions of other users are reported as not found.
+func (h *SessionHandler) revokeSession(c *gin.Context, userID, sessionID string) {
+	session, err := h.sessionRepo.GetByID(sessionID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch session"})
+		return
+	}
+
+	if session == nil || session.UserID != userID {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
+		return
+	}
+
+	if err := h.sessionRepo.RevokeFamily(session.FamilyID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke session"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Session revoked"})
+}
+
+// currentSession returns the session the request was authenticated with, or
+// nil if the token carries none.
+func (h *SessionHandler) currentSession(c *gin.Context) (*models.UserSession, bool) {
+	sessionID := c.GetString("session_id")
+	if sessionID == "" {
+		return nil, true
+	}
+
+	session, err := h.sessionRepo.GetByID(sessionID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch session"})
+		return nil, false
+	}
+
+	return session, true
+}
+
+func (h *SessionHandler) getUserID(c *gin.Context) (string, bool) {
+	user, err := h.userRepo.GetByID(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch user"})
+		return "", false
+	}
+
+	if user == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return "", false
+	}
+
+	return user.ID, true
+}
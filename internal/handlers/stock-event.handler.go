@@ -1,6 +1,8 @@
 package handlers
 
 import (
+	"database/sql"
+	"fmt"
 	"net/http"
 	"strconv"
 	"time"
@@ -8,25 +10,29 @@ import (
 	"github.com/gin-gonic/gin"
 
 	"pwa-backend/internal/mathutil"
+	"pwa-backend/internal/ids"
 	"pwa-backend/internal/models"
+	"pwa-backend/internal/rbac"
 	"pwa-backend/internal/repositories"
 )
 
 type StockEventHandler struct {
 	stockEventRepo *repositories.StockEventRepository
 	productRepo    *repositories.ProductRepository
+	locationRepo   *repositories.LocationRepository
 }
 
-func NewStockEventHandler(stockEventRepo *repositories.StockEventRepository, productRepo *repositories.ProductRepository) *StockEventHandler {
+func NewStockEventHandler(stockEventRepo *repositories.StockEventRepository, productRepo *repositories.ProductRepository, locationRepo *repositories.LocationRepository) *StockEventHandler {
 	return &StockEventHandler{
 		stockEventRepo: stockEventRepo,
 		productRepo:    productRepo,
+		locationRepo:   locationRepo,
 	}
 }
 
 // CreateStockEvent godoc
 // @Summary Create stock event
-// @Description Create stock event (restock, adjustment, etc). Stock will be updated automatically.
+// @Description Create stock event (restock, adjustment, etc). Stock of the caller's store will be updated automatically; admins without a store must pass store_id. The event is booked at the store's default location unless location_id names another location of the store.
 // @Tags stock_events
 // @Accept json
 // @Produce json
@@ -34,6 +40,8 @@ func NewStockEventHandler(stockEventRepo *repositories.StockEventRepository, pro
 // @Param request body models.CreateStockEventRequest true "Stock event data"
 // @Success 201 {object} models.StockEvent
 // @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 409 {object} models.StockConflictResponse
 // @Router /stock-events [post]
 func (h *StockEventHandler) CreateStockEvent(c *gin.Context) {
 	var req models.CreateStockEventRequest
@@ -42,32 +50,26 @@ func (h *StockEventHandler) CreateStockEvent(c *gin.Context) {
 		return
 	}
 
-	userID := c.GetString("user_id")
-
-	product, err := h.productRepo.GetByID(req.ProductID)
-	if err != nil || product == nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Product not found"})
+	storeID, err := writeStore(c, req.StoreID)
+	if err != nil {
+		respondError(c, err, "Failed to resolve store")
 		return
 	}
 
-	qty := req.Qty
-	switch req.Type {
-	case "sale", "reject":
-		qty = -mathutil.Abs(req.Qty)
-	case "restock", "opening_stock":
-		qty = mathutil.Abs(req.Qty)
-	}
+	userID := c.GetString("user_id")
 
 	stockEvent := &models.StockEvent{
-		ID:        generateID(),
-		ProductID: req.ProductID,
-		Qty:       qty,
-		Type:      req.Type,
-		Source:    req.Source,
-		UserID:    &userID,
-		DeviceID:  req.DeviceID,
-		Note:      req.Note,
-		CreatedAt: time.Now(),
+		ID:         ids.New(),
+		StoreID:    storeID,
+		LocationID: req.LocationID,
+		ProductID:  req.ProductID,
+		Qty:        req.Qty,
+		Type:       req.Type,
+		Source:     req.Source,
+		UserID:     &userID,
+		DeviceID:   contextDeviceID(c),
+		Note:       req.Note,
+		CreatedAt:  time.Now(),
 	}
 
 	tx, err := h.stockEventRepo.BeginTx()
@@ -77,13 +79,8 @@ func (h *StockEventHandler) CreateStockEvent(c *gin.Context) {
 	}
 	defer tx.Rollback()
 
-	if err := h.stockEventRepo.Create(tx, stockEvent); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create stock event: " + err.Error()})
-		return
-	}
-
-	if err := h.productRepo.UpdateStockByQty(tx, req.ProductID, qty); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update product stock: " + err.Error()})
+	if err := h.recordStockEvent(tx, stockEvent, c.GetString("role"), c.GetString("scope")); err != nil {
+		respondError(c, err, "Failed to create stock event")
 		return
 	}
 
@@ -95,6 +92,107 @@ func (h *StockEventHandler) CreateStockEvent(c *gin.Context) {
 	c.JSON(http.StatusCreated, stockEvent)
 }
 
+// recordStockEvent applies the stock event rules inside tx: it checks the
+// role may post this type, normalises the sign of qty for the type, resolves
+// the location, locks the product, refuses to take the stock of the store or
+// the location below zero, and writes the event and the new stock levels.
+func (h *StockEventHandler) recordStockEvent(tx *sql.Tx, stockEvent *models.StockEvent, role, scope string) error {
+	if (stockEvent.Type == "adjustment" || stockEvent.Type == "opening_stock") && !rbac.CanInScope(role, scope, rbac.StockEventsAdjust) {
+		return newRequestError(http.StatusForbidden, "Permission denied for stock event type: "+stockEvent.Type)
+	}
+
+	switch stockEvent.Type {
+	case "sale", "reject":
+		stockEvent.Qty = -mathutil.Abs(stockEvent.Qty)
+	case "restock", "opening_stock":
+		stockEvent.Qty = mathutil.Abs(stockEvent.Qty)
+	}
+
+	if err := h.resolveLocation(stockEvent); err != nil {
+		return err
+	}
+
+	// Lock the product row so a concurrent checkout or stock event cannot
+	// push stock below zero between the check and the update.
+	product, err := h.productRepo.GetByIDForUpdate(tx, stockEvent.StoreID, stockEvent.ProductID)
+	if err != nil {
+		return fmt.Errorf("lock product: %w", err)
+	}
+
+	if product == nil {
+		return newRequestError(http.StatusBadRequest, "Product not found")
+	}
+
+	available := product.Stock
+	if stockEvent.Qty < 0 {
+		locationStock, err := h.productRepo.GetLocationStock(tx, stockEvent.LocationID, product.ID)
+		if err != nil {
+			return fmt.Errorf("fetch location stock: %w", err)
+		}
+		if locationStock < available {
+			available = locationStock
+		}
+	}
+
+	if stockEvent.Qty < 0 && available+stockEvent.Qty < 0 {
+		return &requestError{
+			status:  http.StatusConflict,
+			message: "Insufficient stock",
+			body: models.StockConflictResponse{
+				Error: "Insufficient stock",
+				Items: []models.StockShortage{{
+					ProductID:   product.ID,
+					ProductName: product.Name,
+					Requested:   -stockEvent.Qty,
+					Available:   available,
+				}},
+			},
+		}
+	}
+
+	if err := h.stockEventRepo.Create(tx, stockEvent); err != nil {
+		if repositories.IsUniqueViolation(err) {
+			return newRequestError(http.StatusConflict, "Stock event ID already exists: "+stockEvent.ID)
+		}
+		if repositories.IsForeignKeyViolation(err) {
+			return newRequestError(http.StatusBadRequest, "Store not found: "+stockEvent.StoreID)
+		}
+		return fmt.Errorf("create stock event: %w", err)
+	}
+
+	if err := h.productRepo.UpdateStockByQty(tx, stockEvent.StoreID, stockEvent.LocationID, stockEvent.ProductID, stockEvent.Qty); err != nil {
+		return fmt.Errorf("update product stock: %w", err)
+	}
+
+	return nil
+}
+
+// resolveLocation fills in the default location of the event's store, or
+// checks that the location the event names belongs to that store.
+func (h *StockEventHandler) resolveLocation(stockEvent *models.StockEvent) error {
+	if stockEvent.LocationID == "" {
+		location, err := h.locationRepo.GetDefault(stockEvent.StoreID)
+		if err != nil {
+			return fmt.Errorf("fetch default location: %w", err)
+		}
+		if location == nil {
+			return newRequestError(http.StatusBadRequest, "Store not found: "+stockEvent.StoreID)
+		}
+		stockEvent.LocationID = location.ID
+		return nil
+	}
+
+	location, err := h.locationRepo.GetByID(stockEvent.LocationID)
+	if err != nil {
+		return fmt.Errorf("fetch location: %w", err)
+	}
+	if location == nil || location.StoreID != stockEvent.StoreID {
+		return newRequestError(http.StatusBadRequest, "Location not found in store: "+stockEvent.LocationID)
+	}
+
+	return nil
+}
+
 // GetStockEventsByProduct godoc
 // @Summary Get stock events by product
 // @Description Get stock event history for a specific product
@@ -103,13 +201,20 @@ func (h *StockEventHandler) CreateStockEvent(c *gin.Context) {
 // @Security BearerAuth
 // @Param product_id path string true "Product ID"
 // @Param limit query int false "Limit" default(50)
+// @Param store_id query string false "Store (admins only)"
 // @Success 200 {array} models.StockEvent
 // @Router /stock-events/product/{product_id} [get]
 func (h *StockEventHandler) GetStockEventsByProduct(c *gin.Context) {
 	productID := c.Param("product_id")
 	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
 
-	events, err := h.stockEventRepo.GetByProduct(productID, limit)
+	scope, err := storeScope(c)
+	if err != nil {
+		respondError(c, err, "Failed to resolve store")
+		return
+	}
+
+	events, err := h.stockEventRepo.GetByProduct(productID, scope, limit)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch stock events"})
 		return
@@ -120,23 +225,171 @@ func (h *StockEventHandler) GetStockEventsByProduct(c *gin.Context) {
 
 // GetAllStockEvents godoc
 // @Summary Get all stock events
-// @Description Get all stock events with pagination
+// @Description Get all stock events of the caller's store with pagination. Admins without a store see every store unless they pass store_id.
 // @Tags stock_events
 // @Produce json
 // @Security BearerAuth
 // @Param limit query int false "Limit" default(50)
 // @Param offset query int false "Offset" default(0)
+// @Param store_id query string false "Store (admins only)"
 // @Success 200 {array} models.StockEvent
 // @Router /stock-events [get]
 func (h *StockEventHandler) GetAllStockEvents(c *gin.Context) {
 	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
 	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
 
-	events, err := h.stockEventRepo.GetAll(limit, offset)
+	scope, err := storeScope(c)
+	if err != nil {
+		respondError(c, err, "Failed to resolve store")
+		return
+	}
+
+	events, err := h.stockEventRepo.GetAll(scope, limit, offset)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch stock events"})
 		return
 	}
 
 	c.JSON(http.StatusOK, events)
-}
\ No newline at end of file
+}
+
+// GetStockAsOf godoc
+// @Summary Get stock as of a point in time
+// @Description Get the stock of every product as it was at the given time, summed from the stock event ledger. Events created at exactly that time are included. Stock is for the caller's store; admins without a store get the total over all stores unless they pass store_id.
+// @Tags stock_events
+// @Produce json
+// @Security BearerAuth
+// @Param at query string true "Point in time (RFC 3339)"
+// @Param product_id query string false "Only this product"
+// @Param location_id query string false "Only this location"
+// @Param store_id query string false "Store (admins only)"
+// @Success 200 {object} models.StockAsOfResponse
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /stock-events/as-of [get]
+func (h *StockEventHandler) GetStockAsOf(c *gin.Context) {
+	at, ok := timeQuery(c, "at")
+	if !ok {
+		return
+	}
+
+	scope, locationID, productID, ok := h.reportFilters(c)
+	if !ok {
+		return
+	}
+
+	items, err := h.stockEventRepo.StockAsOf(at, scope, locationID, productID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to compute stock"})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.StockAsOfResponse{
+		At:         at,
+		StoreID:    scope,
+		LocationID: locationID,
+		Items:      items,
+	})
+}
+
+// GetStockMovements godoc
+// @Summary Get stock movements for a period
+// @Description Get per product the opening balance just before from, inflow and outflow per stock event type between from and to (both inclusive), and the closing balance at to. Scoped like the stock as of a point in time.
+// @Tags stock_events
+// @Produce json
+// @Security BearerAuth
+// @Param from query string true "Start of the period (RFC 3339)"
+// @Param to query string true "End of the period (RFC 3339)"
+// @Param product_id query string false "Only this product"
+// @Param location_id query string false "Only this location"
+// @Param store_id query string false "Store (admins only)"
+// @Success 200 {object} models.StockMovementResponse
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /stock-events/movements [get]
+func (h *StockEventHandler) GetStockMovements(c *gin.Context) {
+	from, ok := timeQuery(c, "from")
+	if !ok {
+		return
+	}
+
+	to, ok := timeQuery(c, "to")
+	if !ok {
+		return
+	}
+
+	if to.Before(from) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "to must not be before from"})
+		return
+	}
+
+	scope, locationID, productID, ok := h.reportFilters(c)
+	if !ok {
+		return
+	}
+
+	items, err := h.stockEventRepo.Movements(from, to, scope, locationID, productID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to compute stock movements"})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.StockMovementResponse{
+		From:       from,
+		To:         to,
+		StoreID:    scope,
+		LocationID: locationID,
+		Items:      items,
+	})
+}
+
+// reportFilters reads the store scope and the optional location_id and
+// product_id filters of a stock report. A location outside the scope is
+// reported as not found.
+func (h *StockEventHandler) reportFilters(c *gin.Context) (scope, locationID, productID *string, ok bool) {
+	scope, err := storeScope(c)
+	if err != nil {
+		respondError(c, err, "Failed to resolve store")
+		return nil, nil, nil, false
+	}
+
+	if id := c.Query("location_id"); id != "" {
+		location, err := h.locationRepo.GetByID(id)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch location"})
+			return nil, nil, nil, false
+		}
+
+		if location == nil || !inScope(scope, location.StoreID) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Location not found"})
+			return nil, nil, nil, false
+		}
+		locationID = &location.ID
+	}
+
+	if id := c.Query("product_id"); id != "" {
+		productID = &id
+	}
+
+	return scope, locationID, productID, true
+}
+
+// timeQuery parses a required RFC 3339 query parameter, writing a 400
+// response if it is missing or malformed.
+func timeQuery(c *gin.Context, name string) (time.Time, bool) {
+	value := c.Query(name)
+	if value == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": name + " is required"})
+		return time.Time{}, false
+	}
+
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid " + name + ", expected RFC 3339 time: " + value})
+		return time.Time{}, false
+	}
+
+	// created_at columns hold the server's local wall-clock time without a
+	// zone, so compare in that zone.
+	return t.Local(), true
+}
@@ -0,0 +1,448 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"pwa-backend/internal/ids"
+	"pwa-backend/internal/models"
+	"pwa-backend/internal/repositories"
+)
+
+type StockTransferHandler struct {
+	transferRepo   *repositories.StockTransferRepository
+	locationRepo   *repositories.LocationRepository
+	productRepo    *repositories.ProductRepository
+	stockEventRepo *repositories.StockEventRepository
+}
+
+func NewStockTransferHandler(transferRepo *repositories.StockTransferRepository, locationRepo *repositories.LocationRepository, productRepo *repositories.ProductRepository, stockEventRepo *repositories.StockEventRepository) *StockTransferHandler {
+	return &StockTransferHandler{
+		transferRepo:   transferRepo,
+		locationRepo:   locationRepo,
+		productRepo:    productRepo,
+		stockEventRepo: stockEventRepo,
+	}
+}
+
+// CreateStockTransfer godoc
+// @Summary Create stock transfer
+// @Description Move stock from a location of the caller's store to another location. The source is booked out with transfer_out stock events. Within one store the destination is booked in with transfer_in events at once and the transfer is completed; to another store it stays in_transit until that store receives it.
+// @Tags stock_transfers
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body models.CreateStockTransferRequest true "Transfer data"
+// @Success 201 {object} models.StockTransfer
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 409 {object} models.StockConflictResponse
+// @Router /stock-transfers [post]
+func (h *StockTransferHandler) CreateStockTransfer(c *gin.Context) {
+	var req models.CreateStockTransferRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	transferID := ids.New()
+	if req.ID != "" {
+		if err := ids.Validate(req.ID); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid transfer id: " + err.Error()})
+			return
+		}
+		transferID = ids.Normalize(req.ID)
+	}
+
+	from, ok := h.findLocation(c, req.FromLocationID)
+	if !ok {
+		return
+	}
+
+	to, ok := h.findLocation(c, req.ToLocationID)
+	if !ok {
+		return
+	}
+
+	// Stock can only be sent from the caller's own store.
+	if _, err := writeStore(c, from.StoreID); err != nil {
+		respondError(c, err, "Failed to resolve store")
+		return
+	}
+
+	qtyByProduct := make(map[string]int)
+	for _, item := range req.Items {
+		qtyByProduct[item.ProductID] += item.Qty
+	}
+
+	productIDs := make([]string, 0, len(qtyByProduct))
+	for productID := range qtyByProduct {
+		productIDs = append(productIDs, productID)
+	}
+	sort.Strings(productIDs)
+
+	userID := c.GetString("user_id")
+	deviceID := contextDeviceID(c)
+	now := time.Now()
+
+	transfer := &models.StockTransfer{
+		ID:             transferID,
+		FromStoreID:    from.StoreID,
+		FromLocationID: from.ID,
+		ToStoreID:      to.StoreID,
+		ToLocationID:   to.ID,
+		Status:         models.TransferStatusInTransit,
+		Note:           req.Note,
+		CreatedBy:      &userID,
+		CreatedAt:      now,
+	}
+
+	if from.StoreID == to.StoreID {
+		transfer.Status = models.TransferStatusCompleted
+		transfer.ReceivedBy = &userID
+		transfer.ReceivedAt = &now
+	}
+
+	for _, productID := range productIDs {
+		transfer.Items = append(transfer.Items, models.StockTransferItem{
+			ID:         ids.New(),
+			TransferID: transfer.ID,
+			ProductID:  productID,
+			Qty:        qtyByProduct[productID],
+		})
+	}
+
+	tx, err := h.transferRepo.BeginTx()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+		return
+	}
+	defer tx.Rollback()
+
+	if err := h.checkAvailable(tx, transfer); err != nil {
+		respondError(c, err, "Failed to check stock")
+		return
+	}
+
+	if err := h.transferRepo.Create(tx, transfer); err != nil {
+		if repositories.IsUniqueViolation(err) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Transfer ID already exists: " + transfer.ID})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create transfer"})
+		return
+	}
+
+	if err := h.transferRepo.CreateItems(tx, transfer.Items); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create transfer items"})
+		return
+	}
+
+	if err := h.book(tx, transfer, "transfer_out", from, userID, deviceID); err != nil {
+		respondError(c, err, "Failed to book transfer")
+		return
+	}
+
+	if transfer.Status == models.TransferStatusCompleted {
+		if err := h.book(tx, transfer, "transfer_in", to, userID, deviceID); err != nil {
+			respondError(c, err, "Failed to book transfer")
+			return
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, transfer)
+}
+
+// ReceiveStockTransfer godoc
+// @Summary Receive stock transfer
+// @Description Book an in-transit transfer into its destination location with transfer_in stock events. Only the receiving store can do this.
+// @Tags stock_transfers
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Transfer ID"
+// @Success 200 {object} models.StockTransfer
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /stock-transfers/{id}/receive [post]
+func (h *StockTransferHandler) ReceiveStockTransfer(c *gin.Context) {
+	h.finish(c, models.TransferStatusCompleted)
+}
+
+// CancelStockTransfer godoc
+// @Summary Cancel stock transfer
+// @Description Cancel an in-transit transfer. The stock is booked back into the source location with transfer_in stock events. Only the sending store can do this.
+// @Tags stock_transfers
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Transfer ID"
+// @Success 200 {object} models.StockTransfer
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /stock-transfers/{id}/cancel [post]
+func (h *StockTransferHandler) CancelStockTransfer(c *gin.Context) {
+	h.finish(c, models.TransferStatusCancelled)
+}
+
+// GetStockTransfers godoc
+// @Summary Get stock transfers
+// @Description Get transfers out of and into the caller's store, newest first, without their items. Admins without a store see every store unless they pass store_id.
+// @Tags stock_transfers
+// @Produce json
+// @Security BearerAuth
+// @Param status query string false "Filter by status" Enums(in_transit, completed, cancelled)
+// @Param limit query int false "Limit" default(50)
+// @Param offset query int false "Offset" default(0)
+// @Param store_id query string false "Store (admins only)"
+// @Success 200 {array} models.StockTransfer
+// @Router /stock-transfers [get]
+func (h *StockTransferHandler) GetStockTransfers(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if limit <= 0 || limit > 200 {
+		limit = 50
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	status := c.Query("status")
+	switch status {
+	case "", models.TransferStatusInTransit, models.TransferStatusCompleted, models.TransferStatusCancelled:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid status: " + status})
+		return
+	}
+
+	scope, err := storeScope(c)
+	if err != nil {
+		respondError(c, err, "Failed to resolve store")
+		return
+	}
+
+	transfers, err := h.transferRepo.GetAll(scope, status, limit, offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch transfers"})
+		return
+	}
+
+	c.JSON(http.StatusOK, transfers)
+}
+
+// GetStockTransfer godoc
+// @Summary Get stock transfer by ID
+// @Tags stock_transfers
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Transfer ID"
+// @Success 200 {object} models.StockTransfer
+// @Failure 404 {object} map[string]string
+// @Router /stock-transfers/{id} [get]
+func (h *StockTransferHandler) GetStockTransfer(c *gin.Context) {
+	scope, err := storeScope(c)
+	if err != nil {
+		respondError(c, err, "Failed to resolve store")
+		return
+	}
+
+	transfer, err := h.transferRepo.GetByID(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch transfer"})
+		return
+	}
+
+	if transfer == nil || !(inScope(scope, transfer.FromStoreID) || inScope(scope, transfer.ToStoreID)) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Transfer not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, transfer)
+}
+
+// finish moves an in-transit transfer to status: completed books it into the
+// destination, cancelled books it back into the source.
+func (h *StockTransferHandler) finish(c *gin.Context, status string) {
+	scope, err := storeScope(c)
+	if err != nil {
+		respondError(c, err, "Failed to resolve store")
+		return
+	}
+
+	userID := c.GetString("user_id")
+
+	tx, err := h.transferRepo.BeginTx()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+		return
+	}
+	defer tx.Rollback()
+
+	transfer, err := h.transferRepo.GetByIDForUpdate(tx, c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch transfer"})
+		return
+	}
+
+	if transfer == nil || !(inScope(scope, transfer.FromStoreID) || inScope(scope, transfer.ToStoreID)) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Transfer not found"})
+		return
+	}
+
+	target := &models.Location{ID: transfer.ToLocationID, StoreID: transfer.ToStoreID}
+	if status == models.TransferStatusCancelled {
+		target = &models.Location{ID: transfer.FromLocationID, StoreID: transfer.FromStoreID}
+	}
+
+	if _, err := writeStore(c, target.StoreID); err != nil {
+		respondError(c, err, "Failed to resolve store")
+		return
+	}
+
+	if transfer.Status != models.TransferStatusInTransit {
+		c.JSON(http.StatusConflict, gin.H{"error": "Transfer is " + transfer.Status})
+		return
+	}
+
+	if err := h.book(tx, transfer, "transfer_in", target, userID, contextDeviceID(c)); err != nil {
+		respondError(c, err, "Failed to book transfer")
+		return
+	}
+
+	now := time.Now()
+	transfer.Status = status
+	if status == models.TransferStatusCompleted {
+		transfer.ReceivedBy = &userID
+		transfer.ReceivedAt = &now
+	} else {
+		transfer.CancelledAt = &now
+	}
+
+	if err := h.transferRepo.UpdateStatus(tx, transfer); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update transfer"})
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit"})
+		return
+	}
+
+	c.JSON(http.StatusOK, transfer)
+}
+
+// checkAvailable locks the products of a transfer and checks that the source
+// location, and its store as a whole, hold enough of each.
+func (h *StockTransferHandler) checkAvailable(tx *sql.Tx, transfer *models.StockTransfer) error {
+	productIDs := make([]string, 0, len(transfer.Items))
+	for _, item := range transfer.Items {
+		productIDs = append(productIDs, item.ProductID)
+	}
+
+	products, err := h.productRepo.GetByIDsForUpdate(tx, transfer.FromStoreID, productIDs)
+	if err != nil {
+		return fmt.Errorf("lock products: %w", err)
+	}
+
+	var shortages []models.StockShortage
+	for _, item := range transfer.Items {
+		product, ok := products[item.ProductID]
+		if !ok {
+			return newRequestError(http.StatusBadRequest, "Product not found: "+item.ProductID)
+		}
+
+		locationStock, err := h.productRepo.GetLocationStock(tx, transfer.FromLocationID, item.ProductID)
+		if err != nil {
+			return fmt.Errorf("fetch location stock: %w", err)
+		}
+
+		available := product.Stock
+		if locationStock < available {
+			available = locationStock
+		}
+
+		if available < item.Qty {
+			shortages = append(shortages, models.StockShortage{
+				ProductID:   product.ID,
+				ProductName: product.Name,
+				Requested:   item.Qty,
+				Available:   available,
+			})
+		}
+	}
+
+	if len(shortages) > 0 {
+		return &requestError{
+			status:  http.StatusConflict,
+			message: "Insufficient stock",
+			body: models.StockConflictResponse{
+				Error: "Insufficient stock",
+				Items: shortages,
+			},
+		}
+	}
+
+	return nil
+}
+
+// book writes one stock event of eventType per transfer item at location and
+// updates the stock there. transfer_out takes stock away, transfer_in adds it.
+func (h *StockTransferHandler) book(tx *sql.Tx, transfer *models.StockTransfer, eventType string, location *models.Location, userID string, deviceID *string) error {
+	for _, item := range transfer.Items {
+		qty := item.Qty
+		if eventType == "transfer_out" {
+			qty = -qty
+		}
+
+		stockEvent := &models.StockEvent{
+			ID:         ids.New(),
+			StoreID:    location.StoreID,
+			LocationID: location.ID,
+			ProductID:  item.ProductID,
+			Qty:        qty,
+			Type:       eventType,
+			Source:     "dashboard",
+			TransferID: &transfer.ID,
+			UserID:     &userID,
+			DeviceID:   deviceID,
+			Note:       fmt.Sprintf("Stock transfer %s", transfer.ID),
+			CreatedAt:  time.Now(),
+		}
+
+		if err := h.stockEventRepo.Create(tx, stockEvent); err != nil {
+			return fmt.Errorf("create stock event: %w", err)
+		}
+
+		if err := h.productRepo.UpdateStockByQty(tx, location.StoreID, location.ID, item.ProductID, qty); err != nil {
+			return fmt.Errorf("update product stock: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func (h *StockTransferHandler) findLocation(c *gin.Context, id string) (*models.Location, bool) {
+	location, err := h.locationRepo.GetByID(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch location"})
+		return nil, false
+	}
+
+	if location == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Location not found: " + id})
+		return nil, false
+	}
+
+	return location, true
+}
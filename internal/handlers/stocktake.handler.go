@@ -0,0 +1,524 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"pwa-backend/internal/ids"
+	"pwa-backend/internal/models"
+	"pwa-backend/internal/repositories"
+)
+
+type StocktakeHandler struct {
+	stocktakeRepo  *repositories.StocktakeRepository
+	locationRepo   *repositories.LocationRepository
+	productRepo    *repositories.ProductRepository
+	stockEventRepo *repositories.StockEventRepository
+}
+
+func NewStocktakeHandler(stocktakeRepo *repositories.StocktakeRepository, locationRepo *repositories.LocationRepository, productRepo *repositories.ProductRepository, stockEventRepo *repositories.StockEventRepository) *StocktakeHandler {
+	return &StocktakeHandler{
+		stocktakeRepo:  stocktakeRepo,
+		locationRepo:   locationRepo,
+		productRepo:    productRepo,
+		stockEventRepo: stockEventRepo,
+	}
+}
+
+// CreateStocktake godoc
+// @Summary Open stocktake
+// @Description Open a physical count of a location of the caller's store, or of its default location. The current stock at the location is frozen as the expected quantity of every product. A location can have one open stocktake at a time.
+// @Tags stocktakes
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body models.CreateStocktakeRequest true "Stocktake data"
+// @Success 201 {object} models.Stocktake
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /stocktakes [post]
+func (h *StocktakeHandler) CreateStocktake(c *gin.Context) {
+	var req models.CreateStocktakeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	stocktakeID := ids.New()
+	if req.ID != "" {
+		if err := ids.Validate(req.ID); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid stocktake id: " + err.Error()})
+			return
+		}
+		stocktakeID = ids.Normalize(req.ID)
+	}
+
+	location, ok := h.findLocation(c, req.LocationID, req.StoreID)
+	if !ok {
+		return
+	}
+
+	userID := c.GetString("user_id")
+	stocktake := &models.Stocktake{
+		ID:         stocktakeID,
+		StoreID:    location.StoreID,
+		LocationID: location.ID,
+		Status:     models.StocktakeStatusOpen,
+		Note:       req.Note,
+		CreatedBy:  &userID,
+		CreatedAt:  time.Now(),
+	}
+
+	tx, err := h.stocktakeRepo.BeginTx()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+		return
+	}
+	defer tx.Rollback()
+
+	openID, err := h.stocktakeRepo.GetOpenIDByLocation(tx, location.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check open stocktakes"})
+		return
+	}
+	if openID != "" {
+		c.JSON(http.StatusConflict, gin.H{"error": "Location already has an open stocktake: " + openID})
+		return
+	}
+
+	if err := h.stocktakeRepo.Create(tx, stocktake); err != nil {
+		if repositories.IsUniqueViolation(err) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Stocktake already exists: " + stocktake.ID})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create stocktake"})
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit"})
+		return
+	}
+
+	created, err := h.stocktakeRepo.GetByID(stocktake.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch stocktake"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, created)
+}
+
+// GetStocktakes godoc
+// @Summary Get stocktakes
+// @Description Get the stocktakes of the caller's store, newest first, without their items. Admins without a store see every store unless they pass store_id.
+// @Tags stocktakes
+// @Produce json
+// @Security BearerAuth
+// @Param status query string false "Filter by status" Enums(open, approved, cancelled)
+// @Param limit query int false "Limit" default(50)
+// @Param offset query int false "Offset" default(0)
+// @Param store_id query string false "Store (admins only)"
+// @Success 200 {array} models.Stocktake
+// @Router /stocktakes [get]
+func (h *StocktakeHandler) GetStocktakes(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if limit <= 0 || limit > 200 {
+		limit = 50
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	status := c.Query("status")
+	switch status {
+	case "", models.StocktakeStatusOpen, models.StocktakeStatusApproved, models.StocktakeStatusCancelled:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid status: " + status})
+		return
+	}
+
+	scope, err := storeScope(c)
+	if err != nil {
+		respondError(c, err, "Failed to resolve store")
+		return
+	}
+
+	stocktakes, err := h.stocktakeRepo.GetAll(scope, status, limit, offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch stocktakes"})
+		return
+	}
+
+	c.JSON(http.StatusOK, stocktakes)
+}
+
+// GetStocktake godoc
+// @Summary Get stocktake by ID
+// @Description Get a stocktake with the expected and counted quantity and the variance of every product, for review before approval
+// @Tags stocktakes
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Stocktake ID"
+// @Success 200 {object} models.Stocktake
+// @Failure 404 {object} map[string]string
+// @Router /stocktakes/{id} [get]
+func (h *StocktakeHandler) GetStocktake(c *gin.Context) {
+	scope, err := storeScope(c)
+	if err != nil {
+		respondError(c, err, "Failed to resolve store")
+		return
+	}
+
+	stocktake, err := h.stocktakeRepo.GetByID(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch stocktake"})
+		return
+	}
+
+	if stocktake == nil || !inScope(scope, stocktake.StoreID) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Stocktake not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, stocktake)
+}
+
+// SubmitStocktakeCounts godoc
+// @Summary Submit stocktake counts
+// @Description Add counted quantities to an open stocktake. Counts of a product add up, so it can be counted on several shelves or in several passes; a count with recount set replaces the counts made before it. Counts that were already submitted under the same id are skipped, so offline clients can send them again.
+// @Tags stocktakes
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Stocktake ID"
+// @Param request body models.SubmitStocktakeCountsRequest true "Counts"
+// @Success 200 {object} models.SubmitStocktakeCountsResponse
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /stocktakes/{id}/counts [post]
+func (h *StocktakeHandler) SubmitStocktakeCounts(c *gin.Context) {
+	var req models.SubmitStocktakeCountsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	scope, err := storeScope(c)
+	if err != nil {
+		respondError(c, err, "Failed to resolve store")
+		return
+	}
+
+	userID := c.GetString("user_id")
+	deviceID := contextDeviceID(c)
+	now := time.Now()
+
+	counts := make([]*models.StocktakeCount, 0, len(req.Counts))
+	for _, submitted := range req.Counts {
+		countID := ids.New()
+		if submitted.ID != "" {
+			if err := ids.Validate(submitted.ID); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid count id: " + err.Error()})
+				return
+			}
+			countID = ids.Normalize(submitted.ID)
+		}
+
+		countedAt := now
+		if submitted.CountedAt != nil {
+			countedAt = *submitted.CountedAt
+		}
+
+		counts = append(counts, &models.StocktakeCount{
+			ID:          countID,
+			StocktakeID: c.Param("id"),
+			ProductID:   submitted.ProductID,
+			Qty:         *submitted.Qty,
+			Recount:     submitted.Recount,
+			UserID:      &userID,
+			DeviceID:    deviceID,
+			CountedAt:   countedAt,
+			CreatedAt:   now,
+		})
+	}
+
+	tx, err := h.stocktakeRepo.BeginTx()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+		return
+	}
+	defer tx.Rollback()
+
+	var resp models.SubmitStocktakeCountsResponse
+	for _, count := range counts {
+		applied, err := h.recordCount(tx, count, scope)
+		if err != nil {
+			respondError(c, err, "Failed to record count")
+			return
+		}
+		if applied {
+			resp.Applied++
+		} else {
+			resp.Skipped++
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit"})
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// ApproveStocktake godoc
+// @Summary Approve stocktake
+// @Description Close an open stocktake and book, for every counted product, the difference between the count and the stock the ledger held at its location when it was counted as an adjustment stock event linked to the stocktake. Movements after the count, such as sales before approval, stay on top of the counted quantity. Products that were not counted are left alone.
+// @Tags stocktakes
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Stocktake ID"
+// @Success 200 {object} models.Stocktake
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /stocktakes/{id}/approve [post]
+func (h *StocktakeHandler) ApproveStocktake(c *gin.Context) {
+	h.finish(c, models.StocktakeStatusApproved)
+}
+
+// CancelStocktake godoc
+// @Summary Cancel stocktake
+// @Description Close an open stocktake without changing any stock
+// @Tags stocktakes
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Stocktake ID"
+// @Success 200 {object} models.Stocktake
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /stocktakes/{id}/cancel [post]
+func (h *StocktakeHandler) CancelStocktake(c *gin.Context) {
+	h.finish(c, models.StocktakeStatusCancelled)
+}
+
+// finish closes an open stocktake with status. Approval books its variances.
+func (h *StocktakeHandler) finish(c *gin.Context, status string) {
+	scope, err := storeScope(c)
+	if err != nil {
+		respondError(c, err, "Failed to resolve store")
+		return
+	}
+
+	userID := c.GetString("user_id")
+
+	tx, err := h.stocktakeRepo.BeginTx()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+		return
+	}
+	defer tx.Rollback()
+
+	stocktake, err := h.stocktakeRepo.GetByIDForUpdate(tx, c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch stocktake"})
+		return
+	}
+
+	if stocktake == nil || !inScope(scope, stocktake.StoreID) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Stocktake not found"})
+		return
+	}
+
+	if _, err := writeStore(c, stocktake.StoreID); err != nil {
+		respondError(c, err, "Failed to resolve store")
+		return
+	}
+
+	if stocktake.Status != models.StocktakeStatusOpen {
+		c.JSON(http.StatusConflict, gin.H{"error": "Stocktake is " + stocktake.Status})
+		return
+	}
+
+	now := time.Now()
+	stocktake.Status = status
+	if status == models.StocktakeStatusApproved {
+		if err := h.book(tx, stocktake, userID, contextDeviceID(c)); err != nil {
+			respondError(c, err, "Failed to book stocktake")
+			return
+		}
+		stocktake.ApprovedBy = &userID
+		stocktake.ApprovedAt = &now
+	} else {
+		stocktake.CancelledAt = &now
+	}
+
+	if err := h.stocktakeRepo.UpdateStatus(tx, stocktake); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update stocktake"})
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit"})
+		return
+	}
+
+	c.JSON(http.StatusOK, stocktake)
+}
+
+// recordCount adds a count to its stocktake, which must be open and within
+// scope. It returns false if the count was already recorded.
+func (h *StocktakeHandler) recordCount(tx *sql.Tx, count *models.StocktakeCount, scope *string) (bool, error) {
+	exists, err := h.stocktakeRepo.CountExistsTx(tx, count.ID)
+	if err != nil {
+		return false, fmt.Errorf("check count: %w", err)
+	}
+	if exists {
+		return false, nil
+	}
+
+	stocktake, err := h.stocktakeRepo.GetByIDForShare(tx, count.StocktakeID)
+	if err != nil {
+		return false, fmt.Errorf("fetch stocktake: %w", err)
+	}
+
+	if stocktake == nil || !inScope(scope, stocktake.StoreID) {
+		return false, newRequestError(http.StatusNotFound, "Stocktake not found")
+	}
+
+	if stocktake.Status != models.StocktakeStatusOpen {
+		return false, newRequestError(http.StatusConflict, "Stocktake is "+stocktake.Status)
+	}
+
+	count.StocktakeID = stocktake.ID
+	if err := h.stocktakeRepo.CreateCount(tx, count); err != nil {
+		if repositories.IsForeignKeyViolation(err) {
+			return false, newRequestError(http.StatusBadRequest, "Product is not part of the stocktake: "+count.ProductID)
+		}
+		return false, fmt.Errorf("create count: %w", err)
+	}
+
+	return true, nil
+}
+
+// book writes one adjustment stock event per counted product whose count
+// differs from what the ledger held when it was counted, and updates the
+// stock at the location. The products are locked up front, in id order, like
+// a checkout.
+//
+// What moved in or out after the count, e.g. sales between the count and
+// the approval, is carried on top of the counted quantity, so it is neither
+// undone by the adjustment nor absorbed into the variance.
+func (h *StocktakeHandler) book(tx *sql.Tx, stocktake *models.Stocktake, userID string, deviceID *string) error {
+	var counted []models.StocktakeItem
+	productIDs := make([]string, 0, len(stocktake.Items))
+	for _, item := range stocktake.Items {
+		if item.Counted != nil {
+			counted = append(counted, item)
+			productIDs = append(productIDs, item.ProductID)
+		}
+	}
+
+	if len(counted) == 0 {
+		return nil
+	}
+
+	if _, err := h.productRepo.GetByIDsForUpdate(tx, stocktake.StoreID, productIDs); err != nil {
+		return fmt.Errorf("lock products: %w", err)
+	}
+
+	for _, item := range counted {
+		onHand, err := h.productRepo.GetLocationStock(tx, stocktake.LocationID, item.ProductID)
+		if err != nil {
+			return fmt.Errorf("fetch location stock: %w", err)
+		}
+
+		movedSince, err := h.stockEventRepo.LocationMovementSince(tx, stocktake.LocationID, item.ProductID, *item.CountedAt)
+		if err != nil {
+			return fmt.Errorf("fetch stock movements: %w", err)
+		}
+
+		// The ledger held onHand-movedSince when the product was counted.
+		atCount := onHand - movedSince
+		adjustment := *item.Counted - atCount
+		if adjustment == 0 {
+			continue
+		}
+
+		stockEvent := &models.StockEvent{
+			ID:          ids.New(),
+			StoreID:     stocktake.StoreID,
+			LocationID:  stocktake.LocationID,
+			ProductID:   item.ProductID,
+			Qty:         adjustment,
+			Type:        "adjustment",
+			Source:      "dashboard",
+			StocktakeID: &stocktake.ID,
+			UserID:      &userID,
+			DeviceID:    deviceID,
+			Note:        fmt.Sprintf("Stocktake %s: counted %d, expected %d, moved %+d before the count", stocktake.ID, *item.Counted, item.Expected, atCount-item.Expected),
+			CreatedAt:   time.Now(),
+		}
+
+		if err := h.stockEventRepo.Create(tx, stockEvent); err != nil {
+			return fmt.Errorf("create stock event: %w", err)
+		}
+
+		if err := h.productRepo.UpdateStockByQty(tx, stocktake.StoreID, stocktake.LocationID, item.ProductID, adjustment); err != nil {
+			return fmt.Errorf("update product stock: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// findLocation resolves the location to count: the given one, which must
+// belong to a store the caller can write to, or the default location of the
+// caller's store.
+func (h *StocktakeHandler) findLocation(c *gin.Context, locationID, storeID string) (*models.Location, bool) {
+	if locationID == "" {
+		store, err := writeStore(c, storeID)
+		if err != nil {
+			respondError(c, err, "Failed to resolve store")
+			return nil, false
+		}
+
+		location, err := h.locationRepo.GetDefault(store)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch location"})
+			return nil, false
+		}
+		if location == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Store not found: " + store})
+			return nil, false
+		}
+		return location, true
+	}
+
+	location, err := h.locationRepo.GetByID(locationID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch location"})
+		return nil, false
+	}
+	if location == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Location not found: " + locationID})
+		return nil, false
+	}
+
+	if _, err := writeStore(c, location.StoreID); err != nil {
+		respondError(c, err, "Failed to resolve store")
+		return nil, false
+	}
+
+	return location, true
+}
@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"pwa-backend/internal/ids"
+	"pwa-backend/internal/models"
+	"pwa-backend/internal/money"
+	"pwa-backend/internal/repositories"
+	"pwa-backend/internal/testdb"
+)
+
+// TestBookStocktakeKeepsSalesAfterCount approves a stocktake of a product that
+// sold once before it was counted and twice after. The adjustment must only
+// book the shortfall found by the count, and the later sale must stay booked.
+func TestBookStocktakeKeepsSalesAfterCount(t *testing.T) {
+	db := testdb.Open(t)
+
+	productRepo := repositories.NewProductRepository(db)
+	stockEventRepo := repositories.NewStockEventRepository(db)
+	stocktakeRepo := repositories.NewStocktakeRepository(db)
+	locationRepo := repositories.NewLocationRepository(db)
+	h := NewStocktakeHandler(stocktakeRepo, locationRepo, productRepo, stockEventRepo)
+
+	userID := ids.New()
+	if _, err := db.Exec(`INSERT INTO users (id, username, password, name, role) VALUES ($1, 'manager', 'x', 'Manager', 'manager')`, userID); err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now().Add(-time.Hour)
+	store := &models.Store{ID: ids.New(), Name: "Outlet", CreatedAt: start, UpdatedAt: start}
+	if err := repositories.NewStoreRepository(db).Create(store); err != nil {
+		t.Fatal(err)
+	}
+	storeID := store.ID
+
+	location, err := locationRepo.GetDefault(storeID)
+	if err != nil || location == nil {
+		t.Fatalf("fetch default location: %v", err)
+	}
+
+	at := func(minutes int) time.Time { return start.Add(time.Duration(minutes) * time.Minute) }
+
+	product := &models.Product{
+		ID:        ids.New(),
+		Name:      "Kopi",
+		Price:     money.MustParse("15000", money.DefaultCurrency),
+		CreatedAt: at(0),
+		UpdatedAt: at(0),
+	}
+
+	inTx := func(fn func(tx *sql.Tx) error) {
+		t.Helper()
+		tx, err := db.Begin()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer tx.Rollback()
+		if err := fn(tx); err != nil {
+			t.Fatal(err)
+		}
+		if err := tx.Commit(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	move := func(qty int, eventType string, createdAt time.Time) {
+		t.Helper()
+		inTx(func(tx *sql.Tx) error {
+			event := &models.StockEvent{
+				ID:         ids.New(),
+				StoreID:    storeID,
+				LocationID: location.ID,
+				ProductID:  product.ID,
+				Qty:        qty,
+				Type:       eventType,
+				Source:     "pos",
+				UserID:     &userID,
+				CreatedAt:  createdAt,
+			}
+			if err := stockEventRepo.Create(tx, event); err != nil {
+				return err
+			}
+			return productRepo.UpdateStockByQty(tx, storeID, location.ID, product.ID, qty)
+		})
+	}
+
+	inTx(func(tx *sql.Tx) error { return productRepo.Create(tx, product) })
+	move(10, "opening_stock", at(1))
+
+	stocktake := &models.Stocktake{
+		ID:         ids.New(),
+		StoreID:    storeID,
+		LocationID: location.ID,
+		Status:     models.StocktakeStatusOpen,
+		CreatedBy:  &userID,
+		CreatedAt:  at(2),
+	}
+	inTx(func(tx *sql.Tx) error { return stocktakeRepo.Create(tx, stocktake) })
+
+	// The ledger holds 9 when the shelf is counted, and one unit is missing.
+	move(-1, "sale", at(3))
+	inTx(func(tx *sql.Tx) error {
+		return stocktakeRepo.CreateCount(tx, &models.StocktakeCount{
+			ID:          ids.New(),
+			StocktakeID: stocktake.ID,
+			ProductID:   product.ID,
+			Qty:         8,
+			UserID:      &userID,
+			CountedAt:   at(4),
+			CreatedAt:   at(4),
+		})
+	})
+	move(-2, "sale", at(5))
+
+	inTx(func(tx *sql.Tx) error {
+		locked, err := stocktakeRepo.GetByIDForUpdate(tx, stocktake.ID)
+		if err != nil {
+			return err
+		}
+		return h.book(tx, locked, userID, nil)
+	})
+
+	var adjustment int
+	err = db.QueryRow(`SELECT qty FROM stock_events WHERE stocktake_id = $1 AND product_id = $2`, stocktake.ID, product.ID).Scan(&adjustment)
+	if err != nil {
+		t.Fatalf("read adjustment: %v", err)
+	}
+	if adjustment != -1 {
+		t.Errorf("adjustment = %d, want -1", adjustment)
+	}
+
+	var stock int
+	if err := db.QueryRow(`SELECT stock FROM location_stocks WHERE location_id = $1 AND product_id = $2`, location.ID, product.ID).Scan(&stock); err != nil {
+		t.Fatalf("read location stock: %v", err)
+	}
+	if stock != 6 {
+		t.Errorf("location stock = %d, want 6 (8 counted, 2 sold after the count)", stock)
+	}
+}
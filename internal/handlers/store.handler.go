@@ -0,0 +1,248 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"pwa-backend/internal/ids"
+	"pwa-backend/internal/models"
+	"pwa-backend/internal/rbac"
+	"pwa-backend/internal/repositories"
+)
+
+type StoreHandler struct {
+	storeRepo *repositories.StoreRepository
+}
+
+func NewStoreHandler(storeRepo *repositories.StoreRepository) *StoreHandler {
+	return &StoreHandler{storeRepo: storeRepo}
+}
+
+// CreateStore godoc
+// @Summary Create store
+// @Description Admin only. Add an outlet that users, devices, stock and sales can be assigned to.
+// @Tags stores
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body models.CreateStoreRequest true "Store data"
+// @Success 201 {object} models.Store
+// @Failure 400 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /stores [post]
+func (h *StoreHandler) CreateStore(c *gin.Context) {
+	var req models.CreateStoreRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	storeID := ids.New()
+	if req.ID != "" {
+		if err := ids.Validate(req.ID); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid store id: " + err.Error()})
+			return
+		}
+		storeID = ids.Normalize(req.ID)
+	}
+
+	now := time.Now()
+	store := &models.Store{
+		ID:        storeID,
+		Name:      req.Name,
+		Address:   emptyToNil(req.Address),
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	if err := h.storeRepo.Create(store); err != nil {
+		if repositories.IsUniqueViolation(err) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Store already exists: " + storeID})
+			return
+		}
+		log.Printf("Failed to create store: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create store"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, store)
+}
+
+// GetStores godoc
+// @Summary Get stores
+// @Tags stores
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {array} models.Store
+// @Router /stores [get]
+func (h *StoreHandler) GetStores(c *gin.Context) {
+	stores, err := h.storeRepo.GetAll()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch stores"})
+		return
+	}
+
+	c.JSON(http.StatusOK, stores)
+}
+
+// GetStore godoc
+// @Summary Get store by ID
+// @Tags stores
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Store ID"
+// @Success 200 {object} models.Store
+// @Failure 404 {object} map[string]string
+// @Router /stores/{id} [get]
+func (h *StoreHandler) GetStore(c *gin.Context) {
+	store, ok := h.getStore(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, store)
+}
+
+// UpdateStore godoc
+// @Summary Update store
+// @Description Admin only. Rename a store or change its address.
+// @Tags stores
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Store ID"
+// @Param request body models.UpdateStoreRequest true "Fields to update"
+// @Success 200 {object} models.Store
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /stores/{id} [patch]
+func (h *StoreHandler) UpdateStore(c *gin.Context) {
+	var req models.UpdateStoreRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	store, ok := h.getStore(c)
+	if !ok {
+		return
+	}
+
+	if req.Name != nil {
+		store.Name = *req.Name
+	}
+	if req.Address != nil {
+		store.Address = emptyToNil(req.Address)
+	}
+	store.UpdatedAt = time.Now()
+
+	if err := h.storeRepo.Update(store); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update store"})
+		return
+	}
+
+	c.JSON(http.StatusOK, store)
+}
+
+// DeleteStore godoc
+// @Summary Delete store
+// @Description Admin only. Delete a store that never held stock or recorded sales. Users and devices assigned to it are unassigned.
+// @Tags stores
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Store ID"
+// @Success 200 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /stores/{id} [delete]
+func (h *StoreHandler) DeleteStore(c *gin.Context) {
+	store, ok := h.getStore(c)
+	if !ok {
+		return
+	}
+
+	if err := h.storeRepo.Delete(store.ID); err != nil {
+		if repositories.IsForeignKeyViolation(err) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Store has stock or sales and cannot be deleted"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete store"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Store deleted"})
+}
+
+func (h *StoreHandler) getStore(c *gin.Context) (*models.Store, bool) {
+	store, err := h.storeRepo.GetByID(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch store"})
+		return nil, false
+	}
+
+	if store == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Store not found"})
+		return nil, false
+	}
+
+	return store, true
+}
+
+// storeScope returns the store that reads are limited to. Callers with a
+// store in their token only see that store. Admins without one see every
+// store (nil) unless they pick one with the store_id query parameter. Anyone
+// else without a store sees nothing.
+func storeScope(c *gin.Context) (*string, error) {
+	return scopeFor(c.GetString("store_id"), c.GetString("role"), c.Query("store_id"))
+}
+
+func scopeFor(callerStore, role, requested string) (*string, error) {
+	if callerStore != "" {
+		if requested != "" && requested != callerStore {
+			return nil, newRequestError(http.StatusForbidden, "Cannot access another store")
+		}
+		return &callerStore, nil
+	}
+
+	if role != string(rbac.RoleAdmin) {
+		return nil, newRequestError(http.StatusForbidden, "No store assigned")
+	}
+
+	if requested == "" {
+		return nil, nil
+	}
+	return &requested, nil
+}
+
+// writeStore returns the store a write goes to: the caller's store, or for
+// admins without one the store they name in requested.
+func writeStore(c *gin.Context, requested string) (string, error) {
+	return pickStore(c.GetString("store_id"), c.GetString("role"), requested)
+}
+
+func pickStore(callerStore, role, requested string) (string, error) {
+	scope, err := scopeFor(callerStore, role, requested)
+	if err != nil {
+		return "", err
+	}
+
+	if scope == nil {
+		return "", newRequestError(http.StatusBadRequest, "store_id is required")
+	}
+	return *scope, nil
+}
+
+// inScope reports whether a record of storeID is visible in scope.
+func inScope(scope *string, storeID string) bool {
+	return scope == nil || *scope == storeID
+}
+
+func sameStore(a, b *string) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
@@ -0,0 +1,421 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+
+	"pwa-backend/internal/ids"
+	"pwa-backend/internal/models"
+	"pwa-backend/internal/rbac"
+	"pwa-backend/internal/repositories"
+	"pwa-backend/internal/syncrules"
+)
+
+const (
+	syncTableTransactions     = "transactions"
+	syncTableTransactionItems = "transaction_items"
+	syncTableStockEvents      = "stock_events"
+	syncTableStocktakeCounts  = "stocktake_counts"
+)
+
+type SyncHandler struct {
+	transactionRepo    *repositories.TransactionRepository
+	stockEventRepo     *repositories.StockEventRepository
+	transactionHandler *TransactionHandler
+	stockEventHandler  *StockEventHandler
+	stocktakeHandler   *StocktakeHandler
+}
+
+func NewSyncHandler(transactionRepo *repositories.TransactionRepository, stockEventRepo *repositories.StockEventRepository, transactionHandler *TransactionHandler, stockEventHandler *StockEventHandler, stocktakeHandler *StocktakeHandler) *SyncHandler {
+	return &SyncHandler{
+		transactionRepo:    transactionRepo,
+		stockEventRepo:     stockEventRepo,
+		transactionHandler: transactionHandler,
+		stockEventHandler:  stockEventHandler,
+		stocktakeHandler:   stocktakeHandler,
+	}
+}
+
+// syncBatch carries the state of one upload while its operations are applied.
+type syncBatch struct {
+	userID   string
+	role     string
+	scope    string
+	store    string
+	deviceID *string
+	// items holds transaction_items PUTs keyed by their transaction id; they are
+	// written together with the parent transaction PUT.
+	items    map[string][]models.CheckoutItem
+	consumed map[string]bool
+	applied  int
+	skipped  int
+}
+
+// Upload godoc
+// @Summary Upload PowerSync CRUD batch
+// @Description Apply a batch of offline writes from the PowerSync client connector's uploadData. Supported: PUT/PATCH on transactions, PUT on transaction_items (together with their transaction), PUT on stock_events and PUT on stocktake_counts. Every operation goes through the same rules as checkout and stock events, and the batch is applied atomically. Operations that were already applied are skipped, so a batch can be uploaded again safely.
+// @Tags sync
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body models.CrudBatchRequest true "CRUD batch"
+// @Success 200 {object} models.CrudBatchResponse
+// @Failure 400 {object} map[string]string
+// @Failure 422 {object} models.CrudErrorResponse "Permanent failure, discard or fix the batch"
+// @Failure 503 {object} models.CrudErrorResponse "Retryable failure, upload the batch again"
+// @Router /sync/upload [post]
+func (h *SyncHandler) Upload(c *gin.Context) {
+	var req models.CrudBatchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	batch := &syncBatch{
+		userID:   c.GetString("user_id"),
+		role:     c.GetString("role"),
+		scope:    c.GetString("scope"),
+		store:    c.GetString("store_id"),
+		deviceID: contextDeviceID(c),
+		items:    make(map[string][]models.CheckoutItem),
+		consumed: make(map[string]bool),
+	}
+
+	for i := range req.Batch {
+		entry := &req.Batch[i]
+		if err := ids.Validate(entry.ID); err != nil {
+			h.respondFailure(c, i, entry, newRequestError(http.StatusBadRequest, "Invalid id: "+err.Error()))
+			return
+		}
+		entry.ID = ids.Normalize(entry.ID)
+
+		if entry.Table == syncTableTransactionItems && entry.Op == "PUT" {
+			var data models.SyncTransactionItemData
+			if err := decodeSyncData(entry, &data); err != nil {
+				h.respondFailure(c, i, entry, err)
+				return
+			}
+			if err := ids.Validate(data.TransactionID); err != nil {
+				h.respondFailure(c, i, entry, newRequestError(http.StatusBadRequest, "Invalid transaction_id: "+err.Error()))
+				return
+			}
+
+			transactionID := ids.Normalize(data.TransactionID)
+			batch.items[transactionID] = append(batch.items[transactionID], models.CheckoutItem{
+				ID:        entry.ID,
+				ProductID: data.ProductID,
+				Quantity:  data.Quantity,
+			})
+		}
+	}
+
+	tx, err := h.transactionRepo.BeginTx()
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, models.CrudErrorResponse{Error: "Failed to start transaction", Retryable: true})
+		return
+	}
+	defer tx.Rollback()
+
+	for i := range req.Batch {
+		entry := &req.Batch[i]
+		if err := h.apply(tx, batch, entry); err != nil {
+			h.respondFailure(c, i, entry, err)
+			return
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusServiceUnavailable, models.CrudErrorResponse{Error: "Failed to commit", Retryable: true})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.CrudBatchResponse{
+		Applied: batch.applied,
+		Skipped: batch.skipped,
+	})
+}
+
+// Rules godoc
+// @Summary Get PowerSync sync rules
+// @Description Render the sync rules YAML to deploy to PowerSync. It is generated from the same definition that decides the parameter claims in access tokens.
+// @Tags sync
+// @Produce plain
+// @Security BearerAuth
+// @Success 200 {string} string
+// @Router /sync/rules [get]
+func (h *SyncHandler) Rules(c *gin.Context) {
+	c.Data(http.StatusOK, "application/yaml; charset=utf-8", []byte(syncrules.Default.YAML()))
+}
+
+func (h *SyncHandler) apply(tx *sql.Tx, batch *syncBatch, entry *models.CrudEntry) error {
+	switch {
+	case entry.Table == syncTableTransactions && entry.Op == "PUT":
+		return h.putTransaction(tx, batch, entry)
+	case entry.Table == syncTableTransactions && entry.Op == "PATCH":
+		return h.patchTransaction(tx, batch, entry)
+	case entry.Table == syncTableTransactionItems && entry.Op == "PUT":
+		return h.putTransactionItem(tx, batch, entry)
+	case entry.Table == syncTableStockEvents && entry.Op == "PUT":
+		return h.putStockEvent(tx, batch, entry)
+	case entry.Table == syncTableStocktakeCounts && entry.Op == "PUT":
+		return h.putStocktakeCount(tx, batch, entry)
+	case entry.Table == syncTableTransactions || entry.Table == syncTableTransactionItems || entry.Table == syncTableStockEvents ||
+		entry.Table == syncTableStocktakeCounts:
+		return newRequestError(http.StatusBadRequest, fmt.Sprintf("%s is not allowed on %s", entry.Op, entry.Table))
+	}
+
+	return newRequestError(http.StatusBadRequest, "Unsupported table: "+entry.Table)
+}
+
+func (h *SyncHandler) putTransaction(tx *sql.Tx, batch *syncBatch, entry *models.CrudEntry) error {
+	var data models.SyncTransactionData
+	if err := decodeSyncData(entry, &data); err != nil {
+		return err
+	}
+
+	lines := batch.items[entry.ID]
+	for _, line := range lines {
+		batch.consumed[line.ID] = true
+	}
+
+	exists, err := h.transactionRepo.ExistsTx(tx, entry.ID)
+	if err != nil {
+		return fmt.Errorf("check transaction: %w", err)
+	}
+	if exists {
+		batch.skipped++
+		return nil
+	}
+
+	if !rbac.CanInScope(batch.role, batch.scope, rbac.TransactionsCreate) {
+		return newRequestError(http.StatusForbidden, "Permission denied")
+	}
+
+	if len(lines) == 0 {
+		return newRequestError(http.StatusBadRequest, "Transaction has no transaction_items in this batch")
+	}
+
+	storeID, err := pickStore(batch.store, batch.role, data.StoreID)
+	if err != nil {
+		return err
+	}
+
+	if _, err := h.transactionHandler.createSale(tx, entry.ID, batch.userID, storeID, batch.deviceID, lines); err != nil {
+		return err
+	}
+
+	if data.Status != "" && data.Status != models.TransactionStatusPending {
+		if err := h.setStatus(tx, batch, entry.ID, data.Status); err != nil {
+			return err
+		}
+	}
+
+	batch.applied++
+	return nil
+}
+
+func (h *SyncHandler) patchTransaction(tx *sql.Tx, batch *syncBatch, entry *models.CrudEntry) error {
+	var data models.SyncTransactionData
+	if err := decodeSyncData(entry, &data); err != nil {
+		return err
+	}
+
+	if data.Status == "" {
+		return newRequestError(http.StatusBadRequest, "Only status can be changed on transactions")
+	}
+
+	transaction, err := h.transactionRepo.GetByIDForUpdate(tx, entry.ID)
+	if err != nil {
+		return fmt.Errorf("fetch transaction: %w", err)
+	}
+	if transaction != nil && transaction.Status == data.Status {
+		batch.skipped++
+		return nil
+	}
+
+	if err := h.setStatus(tx, batch, entry.ID, data.Status); err != nil {
+		return err
+	}
+
+	batch.applied++
+	return nil
+}
+
+func (h *SyncHandler) setStatus(tx *sql.Tx, batch *syncBatch, id, status string) error {
+	if !rbac.CanInScope(batch.role, batch.scope, statusPermissions[status]) {
+		return newRequestError(http.StatusForbidden, "Permission denied for status: "+status)
+	}
+
+	scope, err := scopeFor(batch.store, batch.role, "")
+	if err != nil {
+		return err
+	}
+
+	return h.transactionHandler.changeStatus(tx, id, status, batch.userID, batch.deviceID, scope)
+}
+
+func (h *SyncHandler) putTransactionItem(tx *sql.Tx, batch *syncBatch, entry *models.CrudEntry) error {
+	if batch.consumed[entry.ID] {
+		return nil
+	}
+
+	exists, err := h.transactionRepo.ItemExistsTx(tx, entry.ID)
+	if err != nil {
+		return fmt.Errorf("check transaction item: %w", err)
+	}
+	if exists {
+		batch.skipped++
+		return nil
+	}
+
+	return newRequestError(http.StatusBadRequest, "transaction_items must be uploaded in the same batch as their transaction")
+}
+
+func (h *SyncHandler) putStockEvent(tx *sql.Tx, batch *syncBatch, entry *models.CrudEntry) error {
+	var data models.CreateStockEventRequest
+	if err := decodeSyncData(entry, &data); err != nil {
+		return err
+	}
+
+	exists, err := h.stockEventRepo.ExistsTx(tx, entry.ID)
+	if err != nil {
+		return fmt.Errorf("check stock event: %w", err)
+	}
+	if exists {
+		batch.skipped++
+		return nil
+	}
+
+	if !rbac.CanInScope(batch.role, batch.scope, rbac.StockEventsCreate) {
+		return newRequestError(http.StatusForbidden, "Permission denied")
+	}
+
+	storeID, err := pickStore(batch.store, batch.role, data.StoreID)
+	if err != nil {
+		return err
+	}
+
+	stockEvent := &models.StockEvent{
+		ID:         entry.ID,
+		StoreID:    storeID,
+		LocationID: data.LocationID,
+		ProductID:  data.ProductID,
+		Qty:        data.Qty,
+		Type:       data.Type,
+		Source:     data.Source,
+		UserID:     &batch.userID,
+		DeviceID:   batch.deviceID,
+		Note:       data.Note,
+		CreatedAt:  time.Now(),
+	}
+
+	if err := h.stockEventHandler.recordStockEvent(tx, stockEvent, batch.role, batch.scope); err != nil {
+		return err
+	}
+
+	batch.applied++
+	return nil
+}
+
+func (h *SyncHandler) putStocktakeCount(tx *sql.Tx, batch *syncBatch, entry *models.CrudEntry) error {
+	var data models.SyncStocktakeCountData
+	if err := decodeSyncData(entry, &data); err != nil {
+		return err
+	}
+
+	if !rbac.CanInScope(batch.role, batch.scope, rbac.StocktakesCount) {
+		return newRequestError(http.StatusForbidden, "Permission denied")
+	}
+
+	scope, err := scopeFor(batch.store, batch.role, "")
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	count := &models.StocktakeCount{
+		ID:          entry.ID,
+		StocktakeID: data.StocktakeID,
+		ProductID:   data.ProductID,
+		Qty:         *data.Qty,
+		Recount:     data.Recount == 1,
+		UserID:      &batch.userID,
+		DeviceID:    batch.deviceID,
+		CountedAt:   now,
+		CreatedAt:   now,
+	}
+	if data.CountedAt != nil {
+		count.CountedAt = *data.CountedAt
+	}
+
+	applied, err := h.stocktakeHandler.recordCount(tx, count, scope)
+	if err != nil {
+		return err
+	}
+
+	if applied {
+		batch.applied++
+	} else {
+		batch.skipped++
+	}
+	return nil
+}
+
+// decodeSyncData reads an operation's data into dst and runs the same binding
+// validation as the REST endpoints.
+func decodeSyncData(entry *models.CrudEntry, dst interface{}) error {
+	if len(entry.Data) > 0 {
+		if err := json.Unmarshal(entry.Data, dst); err != nil {
+			return newRequestError(http.StatusBadRequest, "Invalid data: "+err.Error())
+		}
+	}
+
+	if err := binding.Validator.ValidateStruct(dst); err != nil {
+		return newRequestError(http.StatusBadRequest, err.Error())
+	}
+
+	return nil
+}
+
+// respondFailure rejects the whole batch. Business-rule failures are
+// permanent; anything else (database errors, lock timeouts) is retryable.
+func (h *SyncHandler) respondFailure(c *gin.Context, index int, entry *models.CrudEntry, err error) {
+	failure := models.CrudFailure{
+		Index: index,
+		Op:    entry.Op,
+		Table: entry.Table,
+		ID:    entry.ID,
+	}
+
+	var reqErr *requestError
+	if errors.As(err, &reqErr) {
+		failure.Status = reqErr.status
+		failure.Error = reqErr.message
+		failure.Detail = reqErr.body
+
+		c.JSON(http.StatusUnprocessableEntity, models.CrudErrorResponse{
+			Error:     "Batch rejected",
+			Retryable: false,
+			Failure:   failure,
+		})
+		return
+	}
+
+	log.Printf("Failed to apply sync operation %d (%s %s %s): %v", index, entry.Op, entry.Table, entry.ID, err)
+	failure.Status = http.StatusInternalServerError
+	failure.Error = "Internal error"
+
+	c.JSON(http.StatusServiceUnavailable, models.CrudErrorResponse{
+		Error:     "Batch could not be applied, retry later",
+		Retryable: true,
+		Failure:   failure,
+	})
+}
@@ -1,39 +1,61 @@
 package handlers
 
 import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"pwa-backend/internal/ids"
 	"pwa-backend/internal/models"
+	"pwa-backend/internal/money"
+	"pwa-backend/internal/rbac"
 	"pwa-backend/internal/repositories"
+	"sort"
 	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
+// statusPermissions lists the permission needed to move a transaction into
+// each status.
+var statusPermissions = map[string]rbac.Permission{
+	models.TransactionStatusCompleted: rbac.TransactionsComplete,
+	models.TransactionStatusCancelled: rbac.TransactionsCancel,
+}
+
 type TransactionHandler struct {
 	transactionRepo *repositories.TransactionRepository
 	productRepo     *repositories.ProductRepository
 	stockEventRepo  *repositories.StockEventRepository
+	idempotencyRepo *repositories.IdempotencyKeyRepository
 }
 
-func NewTransactionHandler(transactionRepo *repositories.TransactionRepository, productRepo *repositories.ProductRepository, stockEventRepo *repositories.StockEventRepository) *TransactionHandler {
+func NewTransactionHandler(transactionRepo *repositories.TransactionRepository, productRepo *repositories.ProductRepository, stockEventRepo *repositories.StockEventRepository, idempotencyRepo *repositories.IdempotencyKeyRepository) *TransactionHandler {
 	return &TransactionHandler{
 		transactionRepo: transactionRepo,
 		productRepo:     productRepo,
 		stockEventRepo:  stockEventRepo,
+		idempotencyRepo: idempotencyRepo,
 	}
 }
 
 // Checkout godoc
 // @Summary Checkout transaction
-// @Description Create transaction and deduct stock via stock_events
+// @Description Create transaction and deduct stock via stock_events. Product rows are locked for the duration of the checkout so stock can never go below zero.
+// @Description Retries are safe when the client sends an Idempotency-Key header or its own transaction id: the first successful response is replayed, and reusing a key with a different payload is rejected. Keys are kept for the OFFLINE_WINDOW; a transaction id that already has a sale is answered with that sale even after its key expired.
 // @Tags transactions
 // @Accept json
 // @Produce json
 // @Security BearerAuth
+// @Param Idempotency-Key header string false "Client-generated key identifying this checkout"
 // @Param request body models.CheckoutRequest true "Checkout items"
 // @Success 201 {object} models.Transaction
 // @Failure 400 {object} map[string]string
+// @Failure 409 {object} models.StockConflictResponse
+// @Failure 422 {object} map[string]string
 // @Router /transactions/checkout [post]
 func (h *TransactionHandler) Checkout(c *gin.Context) {
 	var req models.CheckoutRequest
@@ -42,8 +64,44 @@ func (h *TransactionHandler) Checkout(c *gin.Context) {
 		return
 	}
 
+	if req.ID != "" {
+		if err := ids.Validate(req.ID); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid transaction id: " + err.Error()})
+			return
+		}
+		req.ID = ids.Normalize(req.ID)
+	}
+
+	for i := range req.Items {
+		if req.Items[i].ID == "" {
+			continue
+		}
+		if err := ids.Validate(req.Items[i].ID); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid transaction item id: " + err.Error()})
+			return
+		}
+		req.Items[i].ID = ids.Normalize(req.Items[i].ID)
+	}
+
+	storeID, err := writeStore(c, req.StoreID)
+	if err != nil {
+		respondError(c, err, "Failed to resolve store")
+		return
+	}
+
 	userID := c.GetString("user_id")
 
+	// A client-generated transaction id doubles as the idempotency key when
+	// no explicit key is sent.
+	idempotencyKey := c.GetHeader("Idempotency-Key")
+	if idempotencyKey == "" && req.ID != "" {
+		idempotencyKey = "transaction:" + req.ID
+	}
+	if len(idempotencyKey) > 255 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Idempotency-Key too long"})
+		return
+	}
+
 	tx, err := h.transactionRepo.BeginTx()
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
@@ -51,31 +109,257 @@ func (h *TransactionHandler) Checkout(c *gin.Context) {
 	}
 	defer tx.Rollback()
 
-	var totalAmount float64
-	var items []models.TransactionItem
-	transactionID := generateID()
+	var requestHash string
+	if idempotencyKey != "" {
+		requestHash, err = hashRequest(req)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash request"})
+			return
+		}
 
-	for _, item := range req.Items {
-		product, err := h.productRepo.GetByID(item.ProductID)
-		if err != nil || product == nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Product not found: " + item.ProductID})
+		reserved, err := h.idempotencyRepo.Reserve(tx, &models.IdempotencyKey{
+			UserID:      userID,
+			Key:         idempotencyKey,
+			Endpoint:    "checkout",
+			RequestHash: requestHash,
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reserve idempotency key"})
 			return
 		}
 
-		if product.Stock < item.Quantity {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Insufficient stock for product: " + product.Name})
+		if !reserved {
+			tx.Rollback()
+			h.replayIdempotent(c, userID, idempotencyKey, requestHash)
 			return
 		}
+	}
 
-		subtotal := product.Price * float64(item.Quantity)
-		totalAmount += subtotal
+	// A queued checkout may be retried after its key expired. Its client id
+	// still identifies the sale, so answer with that instead of failing.
+	if req.ID != "" {
+		existing, err := h.transactionRepo.GetByIDWithItems(req.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch transaction"})
+			return
+		}
+
+		if existing != nil {
+			tx.Rollback()
+			h.replayTransaction(c, existing, userID, storeID, req.Items)
+			return
+		}
+	}
+
+	transactionID := req.ID
+	if transactionID == "" {
+		transactionID = ids.New()
+	}
+
+	transaction, err := h.createSale(tx, transactionID, userID, storeID, contextDeviceID(c), req.Items)
+	if err != nil {
+		respondError(c, err, "Failed to create transaction")
+		return
+	}
+
+	if idempotencyKey != "" {
+		body, err := json.Marshal(transaction)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to encode response"})
+			return
+		}
+
+		if err := h.idempotencyRepo.SaveResponse(tx, userID, idempotencyKey, http.StatusCreated, body); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store idempotent response"})
+			return
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, transaction)
+}
+
+// replayIdempotent answers a retried request with the response stored for its
+// key, or rejects it if the key was used for a different payload.
+func (h *TransactionHandler) replayIdempotent(c *gin.Context, userID, key, requestHash string) {
+	stored, err := h.idempotencyRepo.Get(userID, key)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch idempotent response"})
+		return
+	}
+
+	if stored == nil || stored.ResponseStatus == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "A request with this Idempotency-Key is still in progress"})
+		return
+	}
+
+	if stored.RequestHash != requestHash {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Idempotency-Key was already used with a different payload"})
+		return
+	}
+
+	c.Header("Idempotent-Replayed", "true")
+	c.Data(*stored.ResponseStatus, "application/json; charset=utf-8", stored.ResponseBody)
+}
+
+// replayTransaction answers a retried checkout whose client transaction id
+// already has a sale, or rejects it if the sale was made with another payload.
+func (h *TransactionHandler) replayTransaction(c *gin.Context, transaction *models.Transaction, userID, storeID string, lines []models.CheckoutItem) {
+	if transaction.UserID != userID {
+		c.JSON(http.StatusConflict, gin.H{"error": "Transaction ID already exists: " + transaction.ID})
+		return
+	}
+
+	if transaction.StoreID != storeID || !sameItems(transaction.Items, lines) {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Transaction ID was already used with a different payload"})
+		return
+	}
+
+	c.Header("Idempotent-Replayed", "true")
+	c.JSON(http.StatusCreated, transaction)
+}
+
+// sameItems reports whether items are the ones a checkout of lines created.
+// Lines with a client item id must match that item; the rest are matched by
+// product and quantity.
+func sameItems(items []models.TransactionItem, lines []models.CheckoutItem) bool {
+	if len(items) != len(lines) {
+		return false
+	}
+
+	type line struct {
+		productID string
+		quantity  int
+	}
+
+	byID := make(map[string]models.TransactionItem, len(items))
+	for _, item := range items {
+		byID[item.ID] = item
+	}
+
+	unmatched := make(map[line]int)
+	for _, l := range lines {
+		if l.ID == "" {
+			unmatched[line{l.ProductID, l.Quantity}]++
+			continue
+		}
+
+		item, ok := byID[l.ID]
+		if !ok || item.ProductID != l.ProductID || item.Quantity != l.Quantity {
+			return false
+		}
+		delete(byID, l.ID)
+	}
+
+	for _, item := range byID {
+		key := line{item.ProductID, item.Quantity}
+		if unmatched[key] == 0 {
+			return false
+		}
+		unmatched[key]--
+	}
+
+	return true
+}
+
+// createSale records a pending sale in storeID inside tx: it locks the
+// products, checks the store's stock, prices the lines from the catalog, and
+// writes the transaction, its items and the matching sale stock events, all
+// stamped with the selling device. Lines may carry client-generated item ids.
+func (h *TransactionHandler) createSale(tx *sql.Tx, transactionID, userID, storeID string, deviceID *string, lines []models.CheckoutItem) (*models.Transaction, error) {
+	// Lock every product in the cart before checking stock. The same product
+	// may appear on several lines, so compare against the combined quantity.
+	requested := make(map[string]int)
+	var productIDs []string
+	for _, line := range lines {
+		if _, seen := requested[line.ProductID]; !seen {
+			productIDs = append(productIDs, line.ProductID)
+		}
+		requested[line.ProductID] += line.Quantity
+	}
+
+	products, err := h.productRepo.GetByIDsForUpdate(tx, storeID, productIDs)
+	if err != nil {
+		return nil, fmt.Errorf("lock products: %w", err)
+	}
+
+	var shortages []models.StockShortage
+	for _, productID := range productIDs {
+		product, ok := products[productID]
+		if !ok {
+			return nil, newRequestError(http.StatusBadRequest, "Product not found: "+productID)
+		}
+
+		if product.ArchivedAt != nil {
+			return nil, newRequestError(http.StatusBadRequest, "Product is archived: "+product.Name)
+		}
+
+		// Sales are taken from the default location, so the store may hold
+		// enough in total and still be short on the shop floor.
+		locationStock, err := h.productRepo.GetDefaultLocationStock(tx, storeID, productID)
+		if err != nil {
+			return nil, fmt.Errorf("fetch location stock: %w", err)
+		}
+
+		available := product.Stock
+		if locationStock < available {
+			available = locationStock
+		}
+
+		if available < requested[productID] {
+			shortages = append(shortages, models.StockShortage{
+				ProductID:   product.ID,
+				ProductName: product.Name,
+				Requested:   requested[productID],
+				Available:   available,
+			})
+		}
+	}
+
+	if len(shortages) > 0 {
+		return nil, &requestError{
+			status:  http.StatusConflict,
+			message: "Insufficient stock",
+			body: models.StockConflictResponse{
+				Error: "Insufficient stock",
+				Items: shortages,
+			},
+		}
+	}
+
+	totalAmount := money.Zero()
+	var items []models.TransactionItem
+
+	for _, line := range lines {
+		product := products[line.ProductID]
+
+		subtotal, err := product.Price.Mul(line.Quantity)
+		if err != nil {
+			return nil, newRequestError(http.StatusBadRequest, "Amount too large for product: "+product.Name)
+		}
+		totalAmount, err = totalAmount.Add(subtotal)
+		if errors.Is(err, money.ErrOutOfRange) {
+			return nil, newRequestError(http.StatusBadRequest, "Transaction total is too large")
+		}
+		if err != nil {
+			return nil, newRequestError(http.StatusBadRequest, "Cannot mix currencies in one transaction: "+product.Name)
+		}
+
+		itemID := line.ID
+		if itemID == "" {
+			itemID = ids.New()
+		}
 
 		items = append(items, models.TransactionItem{
-			ID:            generateID(),
+			ID:            itemID,
 			TransactionID: transactionID,
 			ProductID:     product.ID,
 			ProductName:   product.Name,
-			Quantity:      item.Quantity,
+			Quantity:      line.Quantity,
 			Price:         product.Price,
 			Subtotal:      subtotal,
 			UserID:        userID,
@@ -85,51 +369,66 @@ func (h *TransactionHandler) Checkout(c *gin.Context) {
 	transaction := &models.Transaction{
 		ID:          transactionID,
 		UserID:      userID,
+		StoreID:     storeID,
+		DeviceID:    deviceID,
 		TotalAmount: totalAmount,
-		Status:      "pending",
+		NetAmount:   totalAmount,
+		Status:      models.TransactionStatusPending,
 	}
 
 	if err := h.transactionRepo.Create(tx, transaction); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create transaction"})
-		return
+		if repositories.IsUniqueViolation(err) {
+			return nil, newRequestError(http.StatusConflict, "Transaction ID already exists: "+transactionID)
+		}
+		if repositories.IsForeignKeyViolation(err) {
+			return nil, newRequestError(http.StatusBadRequest, "Store not found: "+storeID)
+		}
+		return nil, fmt.Errorf("create transaction: %w", err)
 	}
 
 	if err := h.transactionRepo.CreateItems(tx, items); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create transaction items"})
-		return
+		if repositories.IsUniqueViolation(err) {
+			return nil, newRequestError(http.StatusConflict, "Transaction item ID already exists")
+		}
+		return nil, fmt.Errorf("create transaction items: %w", err)
 	}
 
-	for _, item := range req.Items {
+	for _, line := range lines {
 		stockEvent := &models.StockEvent{
-			ID:            generateID(),
-			ProductID:     item.ProductID,
-			Qty:           -item.Quantity, 
+			ID:            ids.New(),
+			StoreID:       storeID,
+			ProductID:     line.ProductID,
+			Qty:           -line.Quantity,
 			Type:          "sale",
 			Source:        "pos",
 			TransactionID: &transactionID,
 			UserID:        &userID,
+			DeviceID:      deviceID,
 			Note:          fmt.Sprintf("Sale from transaction %s", transactionID),
 			CreatedAt:     time.Now(),
 		}
 
 		if err := h.stockEventRepo.Create(tx, stockEvent); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create stock event: " + err.Error()})
-			return
+			return nil, fmt.Errorf("create stock event: %w", err)
 		}
 
-		if err := h.productRepo.UpdateStockByQty(tx, item.ProductID, -item.Quantity); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update product stock: " + err.Error()})
-			return
+		if err := h.productRepo.UpdateStockByQty(tx, storeID, stockEvent.LocationID, line.ProductID, -line.Quantity); err != nil {
+			return nil, fmt.Errorf("update product stock: %w", err)
 		}
 	}
 
-	if err := tx.Commit(); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction"})
-		return
+	transaction.Items = items
+	return transaction, nil
+}
+
+func hashRequest(req interface{}) (string, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return "", err
 	}
 
-	transaction.Items = items
-	c.JSON(http.StatusCreated, transaction)
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:]), nil
 }
 
 // GetTransaction godoc
@@ -147,13 +446,20 @@ func (h *TransactionHandler) Checkout(c *gin.Context) {
 func (h *TransactionHandler) GetTransaction(c *gin.Context) {
 	id := c.Param("id")
 
+	scope, err := storeScope(c)
+	if err != nil {
+		respondError(c, err, "Failed to resolve store")
+		return
+	}
+
 	transaction, err := h.transactionRepo.GetByIDWithItems(id)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch transaction"})
 		return
 	}
 
-	if transaction == nil {
+	// Sales of other stores are reported as missing rather than forbidden.
+	if transaction == nil || !inScope(scope, transaction.StoreID) {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Transaction not found"})
 		return
 	}
@@ -163,7 +469,7 @@ func (h *TransactionHandler) GetTransaction(c *gin.Context) {
 
 // UpdateStatus godoc
 // @Summary Update transaction status
-// @Description Update status of existing transaction
+// @Description Move a transaction along its status flow: pending to completed or cancelled, completed to cancelled. Refunded is only reached through the refunds endpoint. Cancelling returns the sold stock through compensating stock events.
 // @Tags transactions
 // @Accept json
 // @Produce json
@@ -172,7 +478,9 @@ func (h *TransactionHandler) GetTransaction(c *gin.Context) {
 // @Param request body models.UpdateStatusRequest true "Status update"
 // @Success 200 {object} models.Transaction
 // @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
 // @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
 // @Router /transactions/{id}/status [put]
 func (h *TransactionHandler) UpdateStatus(c *gin.Context) {
 	id := c.Param("id")
@@ -183,35 +491,125 @@ func (h *TransactionHandler) UpdateStatus(c *gin.Context) {
 		return
 	}
 
-	transaction, err := h.transactionRepo.GetByIDWithItems(id)
+	if !rbac.CanInScope(c.GetString("role"), c.GetString("scope"), statusPermissions[req.Status]) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Permission denied for status: " + req.Status})
+		return
+	}
+
+	scope, err := storeScope(c)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch transaction"})
+		respondError(c, err, "Failed to resolve store")
 		return
 	}
 
-	if transaction == nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Transaction not found"})
+	userID := c.GetString("user_id")
+
+	tx, err := h.transactionRepo.BeginTx()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+		return
+	}
+	defer tx.Rollback()
+
+	if err := h.changeStatus(tx, id, req.Status, userID, contextDeviceID(c), scope); err != nil {
+		respondError(c, err, "Failed to update status")
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction"})
 		return
 	}
 
-	if err := h.transactionRepo.UpdateStatus(id, req.Status); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update status"})
+	transaction, err := h.transactionRepo.GetByIDWithItems(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch transaction"})
 		return
 	}
 
-	transaction.Status = req.Status
 	c.JSON(http.StatusOK, transaction)
 }
 
-func generateID() string {
-	return time.Now().Format("20060102150405") + randomString(6)
+// changeStatus moves a locked transaction of the store scope to status if
+// the state machine allows it, returning stock when it is cancelled.
+func (h *TransactionHandler) changeStatus(tx *sql.Tx, id, status, userID string, deviceID *string, scope *string) error {
+	transaction, err := h.transactionRepo.GetByIDForUpdate(tx, id)
+	if err != nil {
+		return fmt.Errorf("fetch transaction: %w", err)
+	}
+
+	if transaction == nil || !inScope(scope, transaction.StoreID) {
+		return newRequestError(http.StatusNotFound, "Transaction not found")
+	}
+
+	if !models.CanTransitionTransaction(transaction.Status, status) {
+		return newRequestError(http.StatusConflict, fmt.Sprintf("Cannot change status from %s to %s", transaction.Status, status))
+	}
+
+	// A sale with refunds on it can no longer be voided as a whole; the
+	// remaining items have to go through the refund flow.
+	if status == models.TransactionStatusCancelled && !transaction.RefundedAmount.IsZero() {
+		return newRequestError(http.StatusConflict, "Cannot cancel a transaction that has refunds")
+	}
+
+	if status == models.TransactionStatusCancelled {
+		if err := h.reverseStock(tx, transaction, userID, deviceID); err != nil {
+			return fmt.Errorf("return stock: %w", err)
+		}
+	}
+
+	if err := h.transactionRepo.UpdateStatus(tx, id, status); err != nil {
+		return fmt.Errorf("update status: %w", err)
+	}
+
+	return nil
 }
 
-func randomString(n int) string {
-	const letters = "abcdefghijklmnopqrstuvwxyz0123456789"
-	b := make([]byte, n)
-	for i := range b {
-		b[i] = letters[time.Now().UnixNano()%int64(len(letters))]
+// reverseStock writes a cancellation stock event for every product whose
+// stock is still reduced by the transaction and puts that stock back in the
+// store that sold it.
+func (h *TransactionHandler) reverseStock(tx *sql.Tx, transaction *models.Transaction, userID string, deviceID *string) error {
+	transactionID := transaction.ID
+	net, err := h.stockEventRepo.NetQtyByTransaction(tx, transactionID)
+	if err != nil {
+		return err
+	}
+
+	// Update products in id order, matching the lock order used by checkout.
+	productIDs := make([]string, 0, len(net))
+	for productID := range net {
+		productIDs = append(productIDs, productID)
+	}
+	sort.Strings(productIDs)
+
+	for _, productID := range productIDs {
+		qty := -net[productID]
+		if qty == 0 {
+			continue
+		}
+
+		stockEvent := &models.StockEvent{
+			ID:            ids.New(),
+			StoreID:       transaction.StoreID,
+			ProductID:     productID,
+			Qty:           qty,
+			Type:          "cancellation",
+			Source:        stockEventSource(deviceID),
+			TransactionID: &transactionID,
+			UserID:        &userID,
+			DeviceID:      deviceID,
+			Note:          fmt.Sprintf("Cancellation of transaction %s", transactionID),
+			CreatedAt:     time.Now(),
+		}
+
+		if err := h.stockEventRepo.Create(tx, stockEvent); err != nil {
+			return err
+		}
+
+		if err := h.productRepo.UpdateStockByQty(tx, transaction.StoreID, stockEvent.LocationID, productID, qty); err != nil {
+			return err
+		}
 	}
-	return string(b)
-}
\ No newline at end of file
+
+	return nil
+}
@@ -0,0 +1,165 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"pwa-backend/internal/ids"
+	"pwa-backend/internal/models"
+	"pwa-backend/internal/money"
+	"pwa-backend/internal/repositories"
+	"pwa-backend/internal/testdb"
+)
+
+// TestCheckoutConcurrent runs many parallel checkouts of one product. The row
+// locks must serialise them so exactly the available stock is sold and no
+// stock level goes negative.
+func TestCheckoutConcurrent(t *testing.T) {
+	db := testdb.Open(t)
+
+	productRepo := repositories.NewProductRepository(db)
+	stockEventRepo := repositories.NewStockEventRepository(db)
+	h := NewTransactionHandler(repositories.NewTransactionRepository(db), productRepo, stockEventRepo, repositories.NewIdempotencyKeyRepository(db, time.Hour))
+
+	const (
+		openingStock = 10
+		checkouts    = 40
+	)
+
+	now := time.Now()
+	store := &models.Store{ID: ids.New(), Name: "Outlet", CreatedAt: now, UpdatedAt: now}
+	if err := repositories.NewStoreRepository(db).Create(store); err != nil {
+		t.Fatal(err)
+	}
+	storeID := store.ID
+
+	location, err := repositories.NewLocationRepository(db).GetDefault(storeID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	userID := ids.New()
+	if _, err := db.Exec(`INSERT INTO users (id, username, password, name, role, store_id) VALUES ($1, 'cashier', 'x', 'Cashier', 'cashier', $2)`, userID, storeID); err != nil {
+		t.Fatal(err)
+	}
+
+	product := &models.Product{
+		ID:        ids.New(),
+		Name:      "Kopi",
+		Price:     money.MustParse("15000", money.DefaultCurrency),
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := productRepo.Create(tx, product); err != nil {
+		t.Fatal(err)
+	}
+	opening := &models.StockEvent{
+		ID:         ids.New(),
+		StoreID:    storeID,
+		LocationID: location.ID,
+		ProductID:  product.ID,
+		Qty:        openingStock,
+		Type:       "opening_stock",
+		Source:     "dashboard",
+		CreatedAt:  now,
+	}
+	if err := stockEventRepo.Create(tx, opening); err != nil {
+		t.Fatal(err)
+	}
+	if err := productRepo.UpdateStockByQty(tx, storeID, location.ID, product.ID, openingStock); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/checkout", func(c *gin.Context) {
+		c.Set("user_id", userID)
+		c.Set("role", "cashier")
+		c.Set("store_id", storeID)
+	}, h.Checkout)
+
+	body, err := json.Marshal(models.CheckoutRequest{
+		Items: []models.CheckoutItem{{ProductID: product.ID, Quantity: 1}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		wg        sync.WaitGroup
+		mu        sync.Mutex
+		sold      int
+		conflicts int
+		failures  []string
+	)
+
+	start := make(chan struct{})
+	for i := 0; i < checkouts; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/checkout", bytes.NewReader(body)))
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			switch w.Code {
+			case http.StatusCreated:
+				sold++
+			case http.StatusConflict:
+				conflicts++
+			default:
+				failures = append(failures, fmt.Sprintf("%d %s", w.Code, w.Body.String()))
+			}
+		}()
+	}
+
+	close(start)
+	wg.Wait()
+
+	for _, failure := range failures {
+		t.Errorf("checkout failed: %s", failure)
+	}
+
+	if sold != openingStock || conflicts != checkouts-openingStock {
+		t.Errorf("sold %d and rejected %d, want %d and %d", sold, conflicts, openingStock, checkouts-openingStock)
+	}
+
+	levels := map[string]string{
+		"product":  `SELECT stock FROM products WHERE id = $1`,
+		"store":    `SELECT stock FROM product_stocks WHERE product_id = $1`,
+		"location": `SELECT stock FROM location_stocks WHERE product_id = $1`,
+		"ledger":   `SELECT SUM(qty) FROM stock_events WHERE product_id = $1`,
+	}
+	for level, query := range levels {
+		var stock int
+		if err := db.QueryRow(query, product.ID).Scan(&stock); err != nil {
+			t.Fatalf("read %s stock: %v", level, err)
+		}
+		if stock < 0 {
+			t.Errorf("%s stock is negative: %d", level, stock)
+		}
+		if want := openingStock - sold; stock != want {
+			t.Errorf("%s stock = %d, want %d", level, stock, want)
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package handlers
+
+import (
+	"testing"
+
+	"pwa-backend/internal/models"
+)
+
+func TestSameItems(t *testing.T) {
+	items := []models.TransactionItem{
+		{ID: "item-1", ProductID: "kopi", Quantity: 2},
+		{ID: "item-2", ProductID: "teh", Quantity: 1},
+		{ID: "item-3", ProductID: "kopi", Quantity: 1},
+	}
+
+	tests := []struct {
+		name  string
+		lines []models.CheckoutItem
+		want  bool
+	}{
+		{"same lines", []models.CheckoutItem{{ProductID: "kopi", Quantity: 2}, {ProductID: "teh", Quantity: 1}, {ProductID: "kopi", Quantity: 1}}, true},
+		{"other order", []models.CheckoutItem{{ProductID: "teh", Quantity: 1}, {ProductID: "kopi", Quantity: 1}, {ProductID: "kopi", Quantity: 2}}, true},
+		{"client item ids", []models.CheckoutItem{{ID: "item-3", ProductID: "kopi", Quantity: 1}, {ProductID: "kopi", Quantity: 2}, {ID: "item-2", ProductID: "teh", Quantity: 1}}, true},
+		{"other quantity", []models.CheckoutItem{{ProductID: "kopi", Quantity: 3}, {ProductID: "teh", Quantity: 1}, {ProductID: "kopi", Quantity: 1}}, false},
+		{"missing line", []models.CheckoutItem{{ProductID: "kopi", Quantity: 2}, {ProductID: "teh", Quantity: 1}}, false},
+		{"extra line", []models.CheckoutItem{{ProductID: "kopi", Quantity: 2}, {ProductID: "teh", Quantity: 1}, {ProductID: "kopi", Quantity: 1}, {ProductID: "gula", Quantity: 1}}, false},
+		{"unknown item id", []models.CheckoutItem{{ID: "item-9", ProductID: "kopi", Quantity: 2}, {ProductID: "teh", Quantity: 1}, {ProductID: "kopi", Quantity: 1}}, false},
+		{"item id on other line", []models.CheckoutItem{{ID: "item-1", ProductID: "kopi", Quantity: 1}, {ProductID: "teh", Quantity: 1}, {ProductID: "kopi", Quantity: 2}}, false},
+	}
+
+	for _, tt := range tests {
+		if got := sameItems(items, tt.lines); got != tt.want {
+			t.Errorf("%s: sameItems = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
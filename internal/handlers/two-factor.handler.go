@@ -0,0 +1,533 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+
+	"pwa-backend/internal/ids"
+	"pwa-backend/internal/models"
+	"pwa-backend/internal/totp"
+)
+
+const (
+	loginChallengeDuration    = 5 * time.Minute
+	maxLoginChallengeAttempts = 5
+	recoveryCodeCount         = 10
+)
+
+// startTwoFactorChallenge answers a correct password with a challenge token
+// that must be exchanged, together with a code, at /auth/login/2fa.
+func (h *AuthHandlerStruct) startTwoFactorChallenge(c *gin.Context, user *models.User, deviceID *string) {
+	if err := h.twoFactorRepo.DeleteExpiredChallenges(); err != nil {
+		log.Printf("Failed to delete expired login challenges: %v", err)
+	}
+
+	now := time.Now()
+	challenge := &models.LoginChallenge{
+		ID:        ids.NewSecret(),
+		UserID:    user.ID,
+		DeviceID:  deviceID,
+		UserAgent: userAgent(c),
+		ExpiresAt: now.Add(loginChallengeDuration),
+		CreatedAt: now,
+	}
+
+	if err := h.twoFactorRepo.CreateChallenge(challenge); err != nil {
+		log.Printf("Failed to create login challenge: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start two-factor login"})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.TwoFactorChallengeResponse{
+		TwoFactorRequired: true,
+		ChallengeToken:    challenge.ID,
+		ExpiresAt:         challenge.ExpiresAt,
+	})
+}
+
+// LoginTwoFactor godoc
+// @Summary Complete two-factor login
+// @Description Exchange the challenge token from /auth/login and a TOTP code (or an unused recovery code) for a session.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body models.TwoFactorLoginRequest true "Challenge token and code"
+// @Success 200 {object} models.TokenResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 429 {object} map[string]interface{}
+// @Router /auth/login/2fa [post]
+func (h *AuthHandlerStruct) LoginTwoFactor(c *gin.Context) {
+	var req models.TwoFactorLoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	challenge, err := h.twoFactorRepo.GetChallenge(req.ChallengeToken)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch login challenge"})
+		return
+	}
+
+	if challenge == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired challenge, please login again"})
+		return
+	}
+
+	if challenge.Attempts > maxLoginChallengeAttempts {
+		if _, err := h.twoFactorRepo.DeleteChallenge(challenge.ID); err != nil {
+			log.Printf("Failed to delete login challenge: %v", err)
+		}
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Too many attempts, please login again"})
+		return
+	}
+
+	user, err := h.userRepo.GetByID(challenge.UserID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+
+	if user == nil || !user.TwoFactorEnabled() {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired challenge, please login again"})
+		return
+	}
+
+	clientIP := c.ClientIP()
+	attempt, wait, err := h.loginLimiter.Reserve(user.Username, clientIP)
+	if err != nil {
+		log.Printf("Failed to check login attempts: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+
+	if wait > 0 {
+		respondTooManyAttempts(c, wait)
+		return
+	}
+
+	valid, err := h.verifySecondFactor(user, req.Code, req.RecoveryCode)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify code"})
+		return
+	}
+
+	if !valid {
+		if err := attempt.Fail(); err != nil {
+			log.Printf("Failed to record login failure: %v", err)
+		}
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid two-factor code"})
+		return
+	}
+
+	if err := attempt.Release(); err != nil {
+		log.Printf("Failed to release login attempt: %v", err)
+	}
+
+	consumed, err := h.twoFactorRepo.DeleteChallenge(challenge.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to consume login challenge"})
+		return
+	}
+
+	if !consumed {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired challenge, please login again"})
+		return
+	}
+
+	if err := h.loginLimiter.RecordSuccess(user.Username); err != nil {
+		log.Printf("Failed to reset login failures: %v", err)
+	}
+
+	var deviceID *string
+	if challenge.DeviceID != nil {
+		var ok bool
+		if deviceID, ok = h.resolveDevice(c, user, *challenge.DeviceID); !ok {
+			return
+		}
+	}
+
+	h.startSession(c, user, deviceID, models.AuthMethodPassword)
+}
+
+// verifySecondFactor checks a TOTP code, or a recovery code when no TOTP code
+// is given. Both can only be used once.
+func (h *AuthHandlerStruct) verifySecondFactor(user *models.User, code, recoveryCode string) (bool, error) {
+	if code != "" {
+		step, ok := totp.Validate(*user.TOTPSecret, code, time.Now())
+		if !ok {
+			return false, nil
+		}
+		return h.twoFactorRepo.ClaimStep(user.ID, step)
+	}
+
+	if recoveryCode == "" {
+		return false, nil
+	}
+	return h.twoFactorRepo.UseRecoveryCode(user.ID, hashRecoveryCode(recoveryCode))
+}
+
+// SetupTwoFactor godoc
+// @Summary Start two-factor enrolment
+// @Description Generate a new TOTP secret for the authenticated user. Scan the otpauth URI in an authenticator app, then confirm with a code at /auth/2fa/confirm.
+// @Tags auth
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} models.TwoFactorSetupResponse
+// @Failure 409 {object} map[string]string
+// @Router /auth/2fa/setup [post]
+func (h *AuthHandlerStruct) SetupTwoFactor(c *gin.Context) {
+	user, ok := h.currentUser(c)
+	if !ok {
+		return
+	}
+
+	if user.TwoFactorEnabled() {
+		c.JSON(http.StatusConflict, gin.H{"error": "Two-factor authentication is already enabled"})
+		return
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate secret"})
+		return
+	}
+
+	if err := h.twoFactorRepo.SetPendingSecret(user.ID, secret); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store secret"})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.TwoFactorSetupResponse{
+		Secret:     secret,
+		OTPAuthURI: totp.URI(h.jwtConfig.Issuer, user.Username, secret),
+	})
+}
+
+// ConfirmTwoFactor godoc
+// @Summary Confirm two-factor enrolment
+// @Description Enable two-factor authentication with a code from the authenticator app. Returns recovery codes, which are shown only once, and a new access token for the current session.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body models.TwoFactorCodeRequest true "TOTP code"
+// @Success 200 {object} models.TwoFactorEnabledResponse
+// @Failure 400 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /auth/2fa/confirm [post]
+func (h *AuthHandlerStruct) ConfirmTwoFactor(c *gin.Context) {
+	var req models.TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, ok := h.currentUser(c)
+	if !ok {
+		return
+	}
+
+	if user.TwoFactorEnabled() {
+		c.JSON(http.StatusConflict, gin.H{"error": "Two-factor authentication is already enabled"})
+		return
+	}
+
+	if user.TOTPSecret == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Start enrolment at /auth/2fa/setup first"})
+		return
+	}
+
+	step, valid := totp.Validate(*user.TOTPSecret, req.Code, time.Now())
+	if !valid {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid two-factor code"})
+		return
+	}
+
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate recovery codes"})
+		return
+	}
+
+	tx, err := h.twoFactorRepo.BeginTx()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+		return
+	}
+	defer tx.Rollback()
+
+	if err := h.twoFactorRepo.Enable(tx, user.ID, step); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to enable two-factor authentication"})
+		return
+	}
+
+	if err := h.twoFactorRepo.ReplaceRecoveryCodes(tx, user.ID, hashes); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store recovery codes"})
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit"})
+		return
+	}
+
+	now := time.Now()
+	user.TwoFactorEnabledAt = &now
+
+	// Tokens issued before enrolment may carry the mfa_enroll flag; hand out
+	// one without it.
+	response := models.TwoFactorEnabledResponse{RecoveryCodes: codes}
+	session, err := h.sessionRepo.GetByID(c.GetString("session_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch session"})
+		return
+	}
+
+	if session != nil {
+		response.AccessToken, err = h.generateAccessToken(user, session, now)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// RegenerateRecoveryCodes godoc
+// @Summary Regenerate recovery codes
+// @Description Replace all recovery codes of the authenticated user. Requires a current TOTP code.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body models.TwoFactorCodeRequest true "TOTP code"
+// @Success 200 {object} models.RecoveryCodesResponse
+// @Failure 400 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /auth/2fa/recovery-codes [post]
+func (h *AuthHandlerStruct) RegenerateRecoveryCodes(c *gin.Context) {
+	var req models.TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, ok := h.currentUser(c)
+	if !ok {
+		return
+	}
+
+	if !user.TwoFactorEnabled() {
+		c.JSON(http.StatusConflict, gin.H{"error": "Two-factor authentication is not enabled"})
+		return
+	}
+
+	valid, err := h.verifySecondFactor(user, req.Code, "")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify code"})
+		return
+	}
+
+	if !valid {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid two-factor code"})
+		return
+	}
+
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate recovery codes"})
+		return
+	}
+
+	tx, err := h.twoFactorRepo.BeginTx()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+		return
+	}
+	defer tx.Rollback()
+
+	if err := h.twoFactorRepo.ReplaceRecoveryCodes(tx, user.ID, hashes); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store recovery codes"})
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit"})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.RecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// DisableTwoFactor godoc
+// @Summary Disable two-factor authentication
+// @Description Turn off two-factor authentication for the authenticated user. Requires the password and a current TOTP code, and is refused when the user's role requires two-factor authentication. Wrong guesses count towards the login lockout. Every other session of the user is revoked.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body models.DisableTwoFactorRequest true "Password and TOTP code"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 429 {object} map[string]interface{}
+// @Router /auth/2fa/disable [post]
+func (h *AuthHandlerStruct) DisableTwoFactor(c *gin.Context) {
+	var req models.DisableTwoFactorRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, ok := h.currentUser(c)
+	if !ok {
+		return
+	}
+
+	if !user.TwoFactorEnabled() {
+		c.JSON(http.StatusConflict, gin.H{"error": "Two-factor authentication is not enabled"})
+		return
+	}
+
+	required, err := h.twoFactorRepo.IsRequired(user.Role)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch two-factor policy"})
+		return
+	}
+
+	if required {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Two-factor authentication is required for role: " + user.Role})
+		return
+	}
+
+	// Like ChangePassword, a stolen access token must not allow unlimited
+	// guessing of the password and code.
+	clientIP := c.ClientIP()
+	attempt, wait, err := h.loginLimiter.Reserve(user.Username, clientIP)
+	if err != nil {
+		log.Printf("Failed to check login attempts: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+
+	if wait > 0 {
+		respondTooManyAttempts(c, wait)
+		return
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)); err != nil {
+		if err := attempt.Fail(); err != nil {
+			log.Printf("Failed to record login failure: %v", err)
+		}
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Password is incorrect"})
+		return
+	}
+
+	valid, err := h.verifySecondFactor(user, req.Code, "")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify code"})
+		return
+	}
+
+	if !valid {
+		if err := attempt.Fail(); err != nil {
+			log.Printf("Failed to record login failure: %v", err)
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid two-factor code"})
+		return
+	}
+
+	if err := attempt.Release(); err != nil {
+		log.Printf("Failed to release login attempt: %v", err)
+	}
+
+	session, err := h.sessionRepo.GetByID(c.GetString("session_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch session"})
+		return
+	}
+
+	if session == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Session not found"})
+		return
+	}
+
+	tx, err := h.twoFactorRepo.BeginTx()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+		return
+	}
+	defer tx.Rollback()
+
+	if err := h.twoFactorRepo.Disable(tx, user.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to disable two-factor authentication"})
+		return
+	}
+
+	// Without the second factor a leaked password is enough again, so only
+	// the session that turned it off stays logged in.
+	if err := h.sessionRepo.RevokeOthersByUserIDTx(tx, user.ID, session.FamilyID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke sessions"})
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication disabled"})
+}
+
+func (h *AuthHandlerStruct) currentUser(c *gin.Context) (*models.User, bool) {
+	user, err := h.userRepo.GetByID(c.GetString("user_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch user"})
+		return nil, false
+	}
+
+	if user == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+		return nil, false
+	}
+
+	return user, true
+}
+
+// generateRecoveryCodes returns new recovery codes in the form "abcd-efgh"
+// and the hashes to store for them.
+func generateRecoveryCodes() ([]string, []string, error) {
+	encoding := base32.StdEncoding.WithPadding(base32.NoPadding)
+	codes := make([]string, 0, recoveryCodeCount)
+	hashes := make([]string, 0, recoveryCodeCount)
+
+	for i := 0; i < recoveryCodeCount; i++ {
+		b := make([]byte, 5)
+		if _, err := rand.Read(b); err != nil {
+			return nil, nil, err
+		}
+
+		raw := strings.ToLower(encoding.EncodeToString(b))
+		code := raw[:4] + "-" + raw[4:]
+		codes = append(codes, code)
+		hashes = append(hashes, hashRecoveryCode(code))
+	}
+
+	return codes, hashes, nil
+}
+
+// hashRecoveryCode hashes a recovery code ignoring case, dashes and spaces.
+func hashRecoveryCode(code string) string {
+	return ids.HashSecret(strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code)))
+}
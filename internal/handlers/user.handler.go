@@ -0,0 +1,451 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"pwa-backend/internal/ids"
+	"pwa-backend/internal/models"
+	"pwa-backend/internal/password"
+	"pwa-backend/internal/rbac"
+	"pwa-backend/internal/repositories"
+)
+
+// UserHandler holds the admin endpoints for managing other users' accounts.
+type UserHandler struct {
+	userRepo       *repositories.UserRepository
+	sessionRepo    *repositories.UserSessionRepository
+	passwordPolicy *password.Policy
+	twoFactorRepo  *repositories.TwoFactorRepository
+}
+
+func NewUserHandler(userRepo *repositories.UserRepository, sessionRepo *repositories.UserSessionRepository, passwordPolicy *password.Policy, twoFactorRepo *repositories.TwoFactorRepository) *UserHandler {
+	return &UserHandler{
+		userRepo:       userRepo,
+		sessionRepo:    sessionRepo,
+		passwordPolicy: passwordPolicy,
+		twoFactorRepo:  twoFactorRepo,
+	}
+}
+
+// GetUsers godoc
+// @Summary List users
+// @Description Admin only. List users ordered by username with pagination. q searches username and name. Disabled users are hidden unless include_disabled is set. The total number of matches is returned in the X-Total-Count header.
+// @Tags users
+// @Produce json
+// @Security BearerAuth
+// @Param q query string false "Search username or name"
+// @Param role query string false "Filter by role"
+// @Param include_disabled query bool false "Include disabled users" default(false)
+// @Param limit query int false "Limit" default(50)
+// @Param offset query int false "Offset" default(0)
+// @Success 200 {array} models.User
+// @Failure 400 {object} map[string]string
+// @Router /users [get]
+func (h *UserHandler) GetUsers(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if limit <= 0 || limit > 200 {
+		limit = 50
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	filter := models.UserFilter{
+		Search:          c.Query("q"),
+		Role:            c.Query("role"),
+		IncludeDisabled: c.Query("include_disabled") == "true",
+		Limit:           limit,
+		Offset:          offset,
+	}
+
+	if filter.Role != "" && !rbac.IsValidRole(filter.Role) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid role: " + filter.Role})
+		return
+	}
+
+	users, total, err := h.userRepo.List(filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch users"})
+		return
+	}
+
+	c.Header("X-Total-Count", strconv.Itoa(total))
+	c.JSON(http.StatusOK, users)
+}
+
+// GetUser godoc
+// @Summary Get user by ID
+// @Description Admin only. Get a single user, including disabled users.
+// @Tags users
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "User ID"
+// @Success 200 {object} models.User
+// @Failure 404 {object} map[string]string
+// @Router /users/{id} [get]
+func (h *UserHandler) GetUser(c *gin.Context) {
+	user, ok := h.findUser(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
+}
+
+// CreateUser godoc
+// @Summary Create user
+// @Description Admin only. Create a user with any role, optionally assigned to a store. The password must satisfy the password policy and is temporary: the user must change it at first login.
+// @Tags users
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body models.CreateUserRequest true "User data"
+// @Success 201 {object} models.User
+// @Failure 400 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /users [post]
+func (h *UserHandler) CreateUser(c *gin.Context) {
+	var req models.CreateUserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.passwordPolicy.Validate(req.Password, req.Username); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	hashedPassword, err := password.Hash(req.Password)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash password"})
+		return
+	}
+
+	now := time.Now()
+	user := &models.User{
+		ID:                 ids.New(),
+		Username:           req.Username,
+		Password:           hashedPassword,
+		Name:               req.Name,
+		Role:               req.Role,
+		StoreID:            emptyToNil(&req.StoreID),
+		MustChangePassword: true,
+		CreatedAt:          now,
+		UpdatedAt:          now,
+	}
+
+	if err := h.userRepo.Create(user); err != nil {
+		if repositories.IsUniqueViolation(err) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Username already exists"})
+			return
+		}
+		if repositories.IsForeignKeyViolation(err) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Store not found: " + req.StoreID})
+			return
+		}
+		log.Printf("Failed to create user: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create user"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, user)
+}
+
+// UpdateUser godoc
+// @Summary Update user
+// @Description Admin only. Change a user's name, role, store or disabled state; only the fields present are changed. Changing the role or store, or disabling the user, revokes all of their sessions. Admins cannot change their own role or disable themselves.
+// @Tags users
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "User ID"
+// @Param request body models.UpdateUserRequest true "Fields to change"
+// @Success 200 {object} models.User
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /users/{id} [patch]
+func (h *UserHandler) UpdateUser(c *gin.Context) {
+	var req models.UpdateUserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, ok := h.findUser(c)
+	if !ok {
+		return
+	}
+
+	self := user.ID == c.GetString("user_id")
+	revoke := false
+
+	if req.Name != nil {
+		user.Name = *req.Name
+	}
+
+	if req.Role != nil && *req.Role != user.Role {
+		if self {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Cannot change your own role"})
+			return
+		}
+		user.Role = *req.Role
+		revoke = true
+	}
+
+	if req.StoreID != nil {
+		storeID := emptyToNil(req.StoreID)
+		if !sameStore(storeID, user.StoreID) {
+			user.StoreID = storeID
+			revoke = true
+		}
+	}
+
+	if req.Disabled != nil && *req.Disabled != user.IsDisabled() {
+		if self {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Cannot disable your own account"})
+			return
+		}
+		if *req.Disabled {
+			now := time.Now()
+			user.DisabledAt = &now
+			revoke = true
+		} else {
+			user.DisabledAt = nil
+		}
+	}
+
+	if err := h.userRepo.Update(user); err != nil {
+		if repositories.IsForeignKeyViolation(err) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Store not found: " + *user.StoreID})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user"})
+		return
+	}
+
+	// The role and store are baked into access tokens, so new ones only
+	// apply after a fresh login.
+	if revoke {
+		if err := h.sessionRepo.RevokeAllByUserID(user.ID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke sessions"})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, user)
+}
+
+// DeleteUser godoc
+// @Summary Delete user
+// @Description Admin only. Permanently delete a user and their sessions. Users with transactions cannot be deleted; disable them instead. Admins cannot delete themselves.
+// @Tags users
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "User ID"
+// @Success 200 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /users/{id} [delete]
+func (h *UserHandler) DeleteUser(c *gin.Context) {
+	user, ok := h.findUser(c)
+	if !ok {
+		return
+	}
+
+	if user.ID == c.GetString("user_id") {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Cannot delete your own account"})
+		return
+	}
+
+	if err := h.userRepo.Delete(user.ID); err != nil {
+		if repositories.IsForeignKeyViolation(err) {
+			c.JSON(http.StatusConflict, gin.H{"error": "User has transactions, disable the user instead"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete user"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "User deleted"})
+}
+
+func (h *UserHandler) findUser(c *gin.Context) (*models.User, bool) {
+	user, err := h.userRepo.GetByID(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch user"})
+		return nil, false
+	}
+
+	if user == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return nil, false
+	}
+
+	return user, true
+}
+
+// ResetPassword godoc
+// @Summary Reset a user's password
+// @Description Admin only. Set a temporary password that the user must change at next login. All of the user's sessions are revoked.
+// @Tags users
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "User ID"
+// @Param request body models.ResetPasswordRequest true "Temporary password"
+// @Success 200 {object} models.User
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /users/{id}/reset-password [post]
+func (h *UserHandler) ResetPassword(c *gin.Context) {
+	var req models.ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, err := h.userRepo.GetByID(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch user"})
+		return
+	}
+
+	if user == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	if err := checkNewPassword(h.userRepo, h.passwordPolicy, user, req.NewPassword); err != nil {
+		respondError(c, err, "Failed to check password history")
+		return
+	}
+
+	if err := setPassword(h.userRepo, user, req.NewPassword, true); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset password"})
+		return
+	}
+
+	if err := h.sessionRepo.RevokeAllByUserID(user.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke sessions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
+}
+
+// ResetTwoFactor godoc
+// @Summary Reset a user's two-factor authentication
+// @Description Admin only. Remove the TOTP secret and recovery codes of a user who lost their authenticator, and revoke all of their sessions. The user enrols again at next login if their role requires it.
+// @Tags users
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "User ID"
+// @Success 200 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /users/{id}/2fa [delete]
+func (h *UserHandler) ResetTwoFactor(c *gin.Context) {
+	user, err := h.userRepo.GetByID(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch user"})
+		return
+	}
+
+	if user == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	tx, err := h.twoFactorRepo.BeginTx()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+		return
+	}
+	defer tx.Rollback()
+
+	if err := h.twoFactorRepo.Disable(tx, user.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset two-factor authentication"})
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit"})
+		return
+	}
+
+	if err := h.sessionRepo.RevokeAllByUserID(user.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke sessions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication reset"})
+}
+
+// GetTwoFactorPolicies godoc
+// @Summary List two-factor policies
+// @Description Admin only. List the roles for which two-factor authentication is mandatory.
+// @Tags users
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {array} models.TwoFactorPolicy
+// @Router /two-factor/policies [get]
+func (h *UserHandler) GetTwoFactorPolicies(c *gin.Context) {
+	policies, err := h.twoFactorRepo.GetPolicies()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch two-factor policies"})
+		return
+	}
+
+	if policies == nil {
+		policies = []models.TwoFactorPolicy{}
+	}
+
+	c.JSON(http.StatusOK, policies)
+}
+
+// UpdateTwoFactorPolicy godoc
+// @Summary Set two-factor policy for a role
+// @Description Admin only. Make two-factor authentication mandatory (or optional) for a role. Users of that role without two-factor authentication must enrol before they can use the API.
+// @Tags users
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param role path string true "Role"
+// @Param request body models.UpdateTwoFactorPolicyRequest true "Policy"
+// @Success 200 {object} models.TwoFactorPolicy
+// @Failure 400 {object} map[string]string
+// @Router /two-factor/policies/{role} [put]
+func (h *UserHandler) UpdateTwoFactorPolicy(c *gin.Context) {
+	role := c.Param("role")
+	if !rbac.IsValidRole(role) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid role: " + role})
+		return
+	}
+
+	var req models.UpdateTwoFactorPolicyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	policy := &models.TwoFactorPolicy{
+		Role:      role,
+		Required:  *req.Required,
+		UpdatedAt: time.Now(),
+	}
+
+	if err := h.twoFactorRepo.SetPolicy(policy); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update two-factor policy"})
+		return
+	}
+
+	c.JSON(http.StatusOK, policy)
+}
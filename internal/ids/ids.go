@@ -0,0 +1,67 @@
+// Package ids generates and validates the identifiers stored in the database.
+//
+// Row ids are UUIDv7: 36 characters like the existing varchar(36) columns,
+// globally unique without coordination, and sortable by creation time so new
+// rows land at the end of primary key indexes. Offline clients may generate
+// their own ids (PowerSync uses UUIDv4); Validate accepts any RFC 4122 UUID in
+// canonical form.
+package ids
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+var ErrInvalidID = errors.New("id must be a UUID in canonical 8-4-4-4-12 form")
+
+// New returns a new UUIDv7 string. Values generated by one process are
+// strictly increasing.
+func New() string {
+	return uuid.Must(uuid.NewV7()).String()
+}
+
+// NewSecret returns 32 random bytes encoded as URL-safe base64, for opaque
+// credentials such as refresh tokens that must not be guessable or sortable.
+func NewSecret() string {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// HashSecret returns the hex SHA-256 of a random single-use secret, such as
+// an invite token or a recovery code, for storage. Such secrets cannot be
+// guessed, so a fast unsalted hash is enough to keep them out of the database
+// in plain text.
+func HashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// Validate checks a client-supplied id. Only lowercase or uppercase canonical
+// UUIDs are accepted; the braced and urn: forms uuid.Parse also allows are
+// rejected so the same id cannot be stored under two spellings.
+func Validate(id string) error {
+	if len(id) != 36 {
+		return ErrInvalidID
+	}
+
+	parsed, err := uuid.Parse(id)
+	if err != nil || parsed == uuid.Nil || parsed.Variant() != uuid.RFC4122 {
+		return ErrInvalidID
+	}
+
+	return nil
+}
+
+// Normalize returns the canonical lowercase spelling of a valid id.
+func Normalize(id string) string {
+	return strings.ToLower(id)
+}
@@ -0,0 +1,105 @@
+package ids
+
+import (
+	"errors"
+	"sync"
+	"testing"
+)
+
+// TestNewUniqueAndMonotonic generates ids from many goroutines at once. Every
+// id must be unique, each goroutine must see strictly increasing values, and
+// an id generated afterwards must sort after all of them.
+func TestNewUniqueAndMonotonic(t *testing.T) {
+	const (
+		workers   = 32
+		perWorker = 2000
+	)
+
+	results := make([][]string, workers)
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			generated := make([]string, perWorker)
+			for i := range generated {
+				generated[i] = New()
+			}
+			results[w] = generated
+		}(w)
+	}
+	wg.Wait()
+
+	last := New()
+	seen := make(map[string]bool, workers*perWorker)
+
+	for w, generated := range results {
+		for i, id := range generated {
+			if err := Validate(id); err != nil {
+				t.Fatalf("New() = %q is not valid: %v", id, err)
+			}
+			if seen[id] {
+				t.Fatalf("New() returned %q twice", id)
+			}
+			seen[id] = true
+
+			if i > 0 && id <= generated[i-1] {
+				t.Fatalf("worker %d: %q is not after %q", w, id, generated[i-1])
+			}
+			if id >= last {
+				t.Fatalf("%q does not sort before later id %q", id, last)
+			}
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		id  string
+		err error
+	}{
+		{"0190b8a2-5c4e-7d3a-8f21-1a2b3c4d5e6f", nil},
+		{"0190B8A2-5C4E-7D3A-8F21-1A2B3C4D5E6F", nil},
+		{"f47ac10b-58cc-4372-a567-0e02b2c3d479", nil},
+		{"", ErrInvalidID},
+		{"00000000-0000-0000-0000-000000000000", ErrInvalidID},
+		{"{f47ac10b-58cc-4372-a567-0e02b2c3d479}", ErrInvalidID},
+		{"urn:uuid:f47ac10b-58cc-4372-a567-0e02b2c3d479", ErrInvalidID},
+		{"f47ac10b58cc4372a5670e02b2c3d479", ErrInvalidID},
+		{"f47ac10b-58cc-4372-a567-0e02b2c3d47z", ErrInvalidID},
+		{"f47ac10b-58cc-4372-c567-0e02b2c3d479", ErrInvalidID},
+		{"12345", ErrInvalidID},
+	}
+
+	for _, tt := range tests {
+		if err := Validate(tt.id); !errors.Is(err, tt.err) {
+			t.Errorf("Validate(%q) = %v, want %v", tt.id, err, tt.err)
+		}
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		id   string
+		want string
+	}{
+		{"0190b8a2-5c4e-7d3a-8f21-1a2b3c4d5e6f", "0190b8a2-5c4e-7d3a-8f21-1a2b3c4d5e6f"},
+		{"0190B8A2-5C4E-7D3A-8F21-1A2B3C4D5E6F", "0190b8a2-5c4e-7d3a-8f21-1a2b3c4d5e6f"},
+		{"F47ac10b-58CC-4372-a567-0E02B2C3D479", "f47ac10b-58cc-4372-a567-0e02b2c3d479"},
+	}
+
+	for _, tt := range tests {
+		if got := Normalize(tt.id); got != tt.want {
+			t.Errorf("Normalize(%q) = %q, want %q", tt.id, got, tt.want)
+		}
+	}
+}
+
+func TestHashSecret(t *testing.T) {
+	// SHA-256 of "abc" from FIPS 180-2.
+	const want = "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"
+	if got := HashSecret("abc"); got != want {
+		t.Errorf("HashSecret(abc) = %s, want %s", got, want)
+	}
+}
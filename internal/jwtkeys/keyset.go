@@ -0,0 +1,357 @@
+package jwtkeys
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Key is a single signing or verification key identified by its kid.
+type Key struct {
+	ID         string
+	Method     jwt.SigningMethod
+	PrivateKey crypto.Signer
+	PublicKey  crypto.PublicKey
+	// LoadedAt is the modification time of the key file. A new private key is
+	// only used for signing once it is older than the activation delay, so
+	// consumers have time to fetch it from the JWKS first.
+	LoadedAt time.Time
+}
+
+// KeySet holds every key found in the keys directory. Private keys (`<kid>.pem`)
+// can sign and verify; public keys (`<kid>.pub.pem`) only verify and are used to
+// keep retired keys valid until the tokens they signed have expired.
+type KeySet struct {
+	dir             string
+	signingKeyID    string
+	activationDelay time.Duration
+
+	mu      sync.RWMutex
+	keys    map[string]*Key
+	signing *Key
+}
+
+type JWKSResponse struct {
+	Keys []JWK `json:"keys"`
+}
+
+type JWK struct {
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+}
+
+// Load reads all keys from dir. When signingKeyID is empty the newest active
+// private key by kid (name kids by date, e.g. 2026-10-01) is used for signing.
+func Load(dir, signingKeyID string, activationDelay time.Duration) (*KeySet, error) {
+	ks := &KeySet{
+		dir:             dir,
+		signingKeyID:    signingKeyID,
+		activationDelay: activationDelay,
+	}
+
+	if err := ks.Reload(); err != nil {
+		return nil, err
+	}
+
+	return ks, nil
+}
+
+// NewEphemeral returns a key set with a single in-memory Ed25519 key. Tokens
+// signed with it do not survive a restart, so it is only meant for local
+// development.
+func NewEphemeral() (*KeySet, error) {
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	key := &Key{
+		ID:         "ephemeral-" + time.Now().UTC().Format("20060102150405"),
+		Method:     jwt.SigningMethodEdDSA,
+		PrivateKey: priv,
+		PublicKey:  pub,
+	}
+
+	return &KeySet{
+		keys:    map[string]*Key{key.ID: key},
+		signing: key,
+	}, nil
+}
+
+// Reload re-reads the keys directory. Existing keys stay in place if the
+// directory can no longer be parsed.
+func (ks *KeySet) Reload() error {
+	if ks.dir == "" {
+		return errors.New("jwt keys directory not configured")
+	}
+
+	entries, err := os.ReadDir(ks.dir)
+	if err != nil {
+		return fmt.Errorf("error reading jwt keys directory: %w", err)
+	}
+
+	keys := make(map[string]*Key)
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".pem") {
+			continue
+		}
+
+		path := filepath.Join(ks.dir, entry.Name())
+		key, err := readKeyFile(path)
+		if err != nil {
+			return fmt.Errorf("error loading jwt key %s: %w", entry.Name(), err)
+		}
+
+		if existing, ok := keys[key.ID]; ok && existing.PrivateKey != nil {
+			continue
+		}
+		keys[key.ID] = key
+	}
+
+	signing, err := pickSigningKey(keys, ks.signingKeyID, ks.activationDelay)
+	if err != nil {
+		return err
+	}
+
+	ks.mu.Lock()
+	ks.keys = keys
+	ks.signing = signing
+	ks.mu.Unlock()
+
+	return nil
+}
+
+// StartReloader re-reads the keys directory every interval so new keys can be
+// dropped in and old ones retired without a restart.
+func (ks *KeySet) StartReloader(interval time.Duration) {
+	if ks.dir == "" || interval <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			previous := ks.SigningKeyID()
+			if err := ks.Reload(); err != nil {
+				log.Printf("Failed to reload jwt keys: %v", err)
+				continue
+			}
+			if current := ks.SigningKeyID(); current != previous {
+				log.Printf("JWT signing key rotated from %s to %s", previous, current)
+			}
+		}
+	}()
+}
+
+func (ks *KeySet) SigningKeyID() string {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	return ks.signing.ID
+}
+
+// Sign signs the claims with the current signing key and sets the kid header.
+func (ks *KeySet) Sign(claims jwt.Claims) (string, error) {
+	ks.mu.RLock()
+	key := ks.signing
+	ks.mu.RUnlock()
+
+	token := jwt.NewWithClaims(key.Method, claims)
+	token.Header["kid"] = key.ID
+
+	return token.SignedString(key.PrivateKey)
+}
+
+// Parse verifies a token signed by this key set and checks that it was
+// issued by issuer for audience, so tokens minted for another consumer (such
+// as PowerSync) are rejected.
+func (ks *KeySet) Parse(tokenString, issuer, audience string) (*jwt.Token, error) {
+	return jwt.Parse(tokenString, ks.Keyfunc,
+		jwt.WithValidMethods(ks.ValidMethods()),
+		jwt.WithIssuer(issuer),
+		jwt.WithAudience(audience),
+	)
+}
+
+// Keyfunc resolves the verification key from the token's kid header and
+// rejects tokens whose alg does not match that key.
+func (ks *KeySet) Keyfunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	if kid == "" {
+		return nil, errors.New("token has no kid")
+	}
+
+	ks.mu.RLock()
+	key, ok := ks.keys[kid]
+	ks.mu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("unknown kid %q", kid)
+	}
+
+	if token.Method.Alg() != key.Method.Alg() {
+		return nil, jwt.ErrSignatureInvalid
+	}
+
+	return key.PublicKey, nil
+}
+
+// ValidMethods lists the algorithms the key set can verify, for use with
+// jwt.WithValidMethods.
+func (ks *KeySet) ValidMethods() []string {
+	return []string{jwt.SigningMethodRS256.Alg(), jwt.SigningMethodEdDSA.Alg()}
+}
+
+// JWKS returns the public half of every key, sorted by kid.
+func (ks *KeySet) JWKS() JWKSResponse {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	ids := make([]string, 0, len(ks.keys))
+	for id := range ks.keys {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	response := JWKSResponse{Keys: []JWK{}}
+	for _, id := range ids {
+		key := ks.keys[id]
+		jwk := JWK{Use: "sig", Kid: key.ID, Alg: key.Method.Alg()}
+
+		switch pub := key.PublicKey.(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(pub)
+		default:
+			continue
+		}
+
+		response.Keys = append(response.Keys, jwk)
+	}
+
+	return response
+}
+
+func pickSigningKey(keys map[string]*Key, signingKeyID string, activationDelay time.Duration) (*Key, error) {
+	if signingKeyID != "" {
+		key, ok := keys[signingKeyID]
+		if !ok || key.PrivateKey == nil {
+			return nil, fmt.Errorf("signing key %q not found in jwt keys directory", signingKeyID)
+		}
+		return key, nil
+	}
+
+	var ids []string
+	for id, key := range keys {
+		if key.PrivateKey != nil {
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 {
+		return nil, errors.New("no private jwt keys found")
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(ids)))
+
+	// Prefer the newest key that has been published long enough; fall back to
+	// the newest key if none has (e.g. on first start).
+	cutoff := time.Now().Add(-activationDelay)
+	for _, id := range ids {
+		if !keys[id].LoadedAt.After(cutoff) {
+			return keys[id], nil
+		}
+	}
+
+	return keys[ids[0]], nil
+}
+
+func readKeyFile(path string) (*Key, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+
+	name := strings.TrimSuffix(filepath.Base(path), ".pem")
+	key := &Key{
+		ID:       strings.TrimSuffix(name, ".pub"),
+		LoadedAt: info.ModTime(),
+	}
+
+	switch block.Type {
+	case "PRIVATE KEY", "RSA PRIVATE KEY":
+		var parsed interface{}
+		if block.Type == "RSA PRIVATE KEY" {
+			parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+		} else {
+			parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		signer, ok := parsed.(crypto.Signer)
+		if !ok {
+			return nil, errors.New("unsupported private key type")
+		}
+		key.PrivateKey = signer
+		key.PublicKey = signer.Public()
+	case "PUBLIC KEY":
+		parsed, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		key.PublicKey = parsed
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+
+	switch pub := key.PublicKey.(type) {
+	case *rsa.PublicKey:
+		if pub.N.BitLen() < 2048 {
+			return nil, errors.New("RSA keys must be at least 2048 bits")
+		}
+		key.Method = jwt.SigningMethodRS256
+	case ed25519.PublicKey:
+		key.Method = jwt.SigningMethodEdDSA
+	default:
+		return nil, errors.New("only RSA and Ed25519 keys are supported")
+	}
+
+	return key, nil
+}
@@ -0,0 +1,260 @@
+package jwtkeys
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	testIssuer   = "pwa-backend"
+	testAudience = "pwa-api"
+)
+
+// writeKey stores a private key as <kid>.pem with the given modification
+// time, which decides when it becomes the signing key.
+func writeKey(t *testing.T, dir, kid string, key crypto.Signer, modTime time.Time) {
+	t.Helper()
+
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writePEM(t, filepath.Join(dir, kid+".pem"), "PRIVATE KEY", der, modTime)
+}
+
+// writePublicKey stores only the public half as <kid>.pub.pem, as done when a
+// key is retired.
+func writePublicKey(t *testing.T, dir, kid string, key crypto.PublicKey) {
+	t.Helper()
+
+	der, err := x509.MarshalPKIXPublicKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writePEM(t, filepath.Join(dir, kid+".pub.pem"), "PUBLIC KEY", der, time.Now())
+}
+
+func writePEM(t *testing.T, path, blockType string, der []byte, modTime time.Time) {
+	t.Helper()
+
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func newEd25519(t *testing.T) ed25519.PrivateKey {
+	t.Helper()
+
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return priv
+}
+
+func sign(t *testing.T, ks *KeySet) string {
+	t.Helper()
+
+	token, err := ks.Sign(jwt.MapClaims{
+		"sub": "user",
+		"iss": testIssuer,
+		"aud": testAudience,
+		"exp": time.Now().Add(time.Minute).Unix(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func TestRotation(t *testing.T) {
+	dir := t.TempDir()
+	old := newEd25519(t)
+	next := newEd25519(t)
+
+	writeKey(t, dir, "2026-01-01", old, time.Now().Add(-24*time.Hour))
+	writeKey(t, dir, "2026-02-01", next, time.Now())
+
+	ks, err := Load(dir, "", 10*time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The new key is published in the JWKS but not used until the activation
+	// delay has passed.
+	if got := ks.SigningKeyID(); got != "2026-01-01" {
+		t.Fatalf("signing key = %s, want 2026-01-01 until the new key is active", got)
+	}
+	if got := len(ks.JWKS().Keys); got != 2 {
+		t.Fatalf("JWKS has %d keys, want 2", got)
+	}
+
+	oldToken := sign(t, ks)
+
+	writeKey(t, dir, "2026-02-01", next, time.Now().Add(-time.Hour))
+	if err := ks.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if got := ks.SigningKeyID(); got != "2026-02-01" {
+		t.Fatalf("signing key = %s, want 2026-02-01 after the activation delay", got)
+	}
+
+	// Retiring the old key to its public half keeps its tokens valid.
+	if err := os.Remove(filepath.Join(dir, "2026-01-01.pem")); err != nil {
+		t.Fatal(err)
+	}
+	writePublicKey(t, dir, "2026-01-01", old.Public())
+	if err := ks.Reload(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ks.Parse(oldToken, testIssuer, testAudience); err != nil {
+		t.Errorf("token of the retired key was rejected: %v", err)
+	}
+	if _, err := ks.Parse(sign(t, ks), testIssuer, testAudience); err != nil {
+		t.Errorf("token of the new key was rejected: %v", err)
+	}
+
+	// Once the public key is removed too, its tokens stop working.
+	if err := os.Remove(filepath.Join(dir, "2026-01-01.pub.pem")); err != nil {
+		t.Fatal(err)
+	}
+	if err := ks.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ks.Parse(oldToken, testIssuer, testAudience); err == nil {
+		t.Error("token of a removed key was accepted")
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	dir := t.TempDir()
+	writePublicKey(t, dir, "2026-01-01", newEd25519(t).Public())
+
+	if _, err := Load(dir, "", 0); err == nil {
+		t.Error("Load without a private key succeeded")
+	}
+
+	writeKey(t, dir, "2026-02-01", newEd25519(t), time.Now())
+	if _, err := Load(dir, "2026-01-01", 0); err == nil {
+		t.Error("Load with a public-only signing key succeeded")
+	}
+	if _, err := Load(dir, "2026-03-01", 0); err == nil {
+		t.Error("Load with an unknown signing key succeeded")
+	}
+
+	weak, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeKey(t, dir, "2026-04-01", weak, time.Now())
+	if _, err := Load(dir, "", 0); err == nil {
+		t.Error("Load with a 1024 bit RSA key succeeded")
+	}
+}
+
+func TestParse(t *testing.T) {
+	dir := t.TempDir()
+	writeKey(t, dir, "2026-01-01", newEd25519(t), time.Now())
+
+	ks, err := Load(dir, "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token := sign(t, ks)
+
+	if _, err := ks.Parse(token, testIssuer, testAudience); err != nil {
+		t.Fatalf("valid token was rejected: %v", err)
+	}
+	if _, err := ks.Parse(token, testIssuer, "powersync"); err == nil {
+		t.Error("token for another audience was accepted")
+	}
+	if _, err := ks.Parse(token, "someone-else", testAudience); err == nil {
+		t.Error("token from another issuer was accepted")
+	}
+
+	other, err := NewEphemeral()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ks.Parse(sign(t, other), testIssuer, testAudience); err == nil {
+		t.Error("token signed by an unknown key was accepted")
+	}
+
+	unsigned, err := jwt.NewWithClaims(jwt.SigningMethodNone, jwt.MapClaims{
+		"iss": testIssuer, "aud": testAudience,
+	}).SignedString(jwt.UnsafeAllowNoneSignatureType)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ks.Parse(unsigned, testIssuer, testAudience); err == nil {
+		t.Error("unsigned token was accepted")
+	}
+}
+
+func TestJWKS(t *testing.T) {
+	dir := t.TempDir()
+
+	edKey := newEd25519(t)
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writeKey(t, dir, "b-ed25519", edKey, time.Now())
+	writeKey(t, dir, "a-rsa", rsaKey, time.Now())
+	writePublicKey(t, dir, "c-retired", newEd25519(t).Public())
+
+	ks, err := Load(dir, "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keys := ks.JWKS().Keys
+	if len(keys) != 3 {
+		t.Fatalf("JWKS has %d keys, want 3", len(keys))
+	}
+
+	for i, kid := range []string{"a-rsa", "b-ed25519", "c-retired"} {
+		if keys[i].Kid != kid {
+			t.Errorf("JWKS key %d = %s, want %s", i, keys[i].Kid, kid)
+		}
+		if keys[i].Use != "sig" {
+			t.Errorf("%s: use = %q, want sig", kid, keys[i].Use)
+		}
+	}
+
+	rsaJWK := keys[0]
+	if rsaJWK.Kty != "RSA" || rsaJWK.Alg != "RS256" || rsaJWK.E != "AQAB" {
+		t.Errorf("RSA JWK = %+v", rsaJWK)
+	}
+	if n, _ := base64.RawURLEncoding.DecodeString(rsaJWK.N); len(n) != 256 {
+		t.Errorf("RSA modulus is %d bytes, want 256", len(n))
+	}
+
+	edJWK := keys[1]
+	if edJWK.Kty != "OKP" || edJWK.Crv != "Ed25519" || edJWK.Alg != "EdDSA" {
+		t.Errorf("Ed25519 JWK = %+v", edJWK)
+	}
+	if x, _ := base64.RawURLEncoding.DecodeString(edJWK.X); !edKey.Public().(ed25519.PublicKey).Equal(ed25519.PublicKey(x)) {
+		t.Errorf("Ed25519 JWK x does not match the key")
+	}
+
+	if rsaJWK.N == "" || edJWK.N != "" || rsaJWK.X != "" {
+		t.Error("JWK carries fields of the other key type")
+	}
+}
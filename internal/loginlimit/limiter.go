@@ -0,0 +1,208 @@
+// Package loginlimit slows down and locks out password guessing. Failed
+// logins are counted per username and per client IP; every failure makes the
+// caller wait a little longer before the next attempt, and reaching the
+// threshold locks the key out for a while.
+package loginlimit
+
+import (
+	"strings"
+	"time"
+)
+
+const (
+	KindUsername = "username"
+	KindIP       = "ip"
+)
+
+// Key identifies what failures are counted against.
+type Key struct {
+	Kind  string `json:"kind"`
+	Value string `json:"value"`
+}
+
+// Entry is the failure state of a key.
+type Entry struct {
+	Key
+	Failures      int       `json:"failures"`
+	LastFailureAt time.Time `json:"last_failure_at"`
+	// BlockedUntil is when the next attempt is allowed. Locked tells a
+	// lockout apart from the short progressive delay.
+	BlockedUntil time.Time `json:"blocked_until"`
+	Locked       bool      `json:"locked"`
+}
+
+// Store keeps failure counters. MemoryStore is enough for a single instance;
+// deployments with several instances need a shared store such as the Postgres
+// one in the repositories package.
+type Store interface {
+	// Get returns the entry for key, or nil if it has no failures.
+	Get(key Key) (*Entry, error)
+	// Reserve atomically counts an attempt on key at now, as a failure until
+	// it is released, unless key is blocked at now. Failures before since are
+	// forgotten first. When the count reaches maxFailures (if positive) key
+	// is locked until lockUntil. It returns the new count, or 0 and the end
+	// of the block when the attempt is refused.
+	Reserve(key Key, now, since time.Time, maxFailures int, lockUntil time.Time) (int, time.Time, error)
+	// Release takes back an attempt counted by Reserve. lockedUntil is the
+	// lock that attempt set, or zero; that lock is lifted as well.
+	Release(key Key, lockedUntil time.Time) error
+	// Block refuses attempts for key until the given time. A block never
+	// replaces one that lasts longer.
+	Block(key Key, until time.Time, locked bool) error
+	// Reset forgets every failure of key.
+	Reset(key Key) error
+	// Locked lists keys that are locked out at now.
+	Locked(now time.Time) ([]Entry, error)
+}
+
+type Policy struct {
+	// Scope keeps the counters of this limiter apart from other limiters on
+	// the same store, e.g. "pin" counts PIN logins as "pin:username".
+	Scope string
+	// MaxFailures locks a username out once it is reached.
+	MaxFailures int
+	// MaxFailuresPerIP locks a client IP out once it is reached. It is higher
+	// than MaxFailures because several cashiers may share one NAT address.
+	MaxFailuresPerIP int
+	LockoutDuration  time.Duration
+	// FailureWindow is how long a failure counts towards a lockout.
+	FailureWindow time.Duration
+	// BaseDelay is the wait after the first failure; it doubles with every
+	// further failure up to MaxDelay.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+}
+
+type Limiter struct {
+	store  Store
+	policy Policy
+	now    func() time.Time
+}
+
+func New(store Store, policy Policy) *Limiter {
+	return &Limiter{store: store, policy: policy, now: time.Now}
+}
+
+func (l *Limiter) key(kind, value string) Key {
+	if l.policy.Scope != "" {
+		kind = l.policy.Scope + ":" + kind
+	}
+	return Key{Kind: kind, Value: value}
+}
+
+// IsValidKind reports whether kind is a username or IP kind, optionally
+// prefixed with a limiter scope.
+func IsValidKind(kind string) bool {
+	if i := strings.LastIndex(kind, ":"); i >= 0 {
+		kind = kind[i+1:]
+	}
+	return kind == KindUsername || kind == KindIP
+}
+
+// Attempt is a login attempt reserved by Reserve. It counts as a failure
+// until Release is called, so concurrent requests cannot all slip past the
+// limiter before the first failure is recorded.
+type Attempt struct {
+	limiter *Limiter
+	keys    []reservedKey
+}
+
+type reservedKey struct {
+	key         Key
+	failures    int
+	lockedUntil time.Time
+}
+
+// Reserve claims an attempt to log in as username from ip. When either is
+// blocked it returns how long the caller must wait and no attempt.
+func (l *Limiter) Reserve(username, ip string) (*Attempt, time.Duration, error) {
+	now := l.now().Truncate(time.Microsecond)
+	since := now.Add(-l.policy.FailureWindow)
+	lockUntil := now.Add(l.policy.LockoutDuration)
+
+	attempt := &Attempt{limiter: l}
+	limits := []struct {
+		key         Key
+		maxFailures int
+	}{
+		{l.key(KindUsername, username), l.policy.MaxFailures},
+		{l.key(KindIP, ip), l.policy.MaxFailuresPerIP},
+	}
+
+	for _, limit := range limits {
+		failures, blockedUntil, err := l.store.Reserve(limit.key, now, since, limit.maxFailures, lockUntil)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		if failures == 0 {
+			if err := attempt.Release(); err != nil {
+				return nil, 0, err
+			}
+			return nil, blockedUntil.Sub(now), nil
+		}
+
+		reserved := reservedKey{key: limit.key, failures: failures}
+		if limit.maxFailures > 0 && failures >= limit.maxFailures {
+			reserved.lockedUntil = lockUntil
+		}
+		attempt.keys = append(attempt.keys, reserved)
+	}
+
+	return attempt, 0, nil
+}
+
+// Fail keeps the attempt counted and blocks further attempts for the
+// progressive delay. Keys that reached their threshold were already locked
+// out by Reserve.
+func (a *Attempt) Fail() error {
+	now := a.limiter.now()
+	for _, reserved := range a.keys {
+		if !reserved.lockedUntil.IsZero() {
+			continue
+		}
+		if err := a.limiter.store.Block(reserved.key, now.Add(a.limiter.delay(reserved.failures)), false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Release takes the attempt back once the credentials turned out to be
+// right. Failures recorded before it are kept; RecordSuccess clears them.
+func (a *Attempt) Release() error {
+	for _, reserved := range a.keys {
+		if err := a.limiter.store.Release(reserved.key, reserved.lockedUntil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (l *Limiter) delay(failures int) time.Duration {
+	d := l.policy.BaseDelay
+	for i := 1; i < failures && d < l.policy.MaxDelay; i++ {
+		d *= 2
+	}
+	if d > l.policy.MaxDelay {
+		d = l.policy.MaxDelay
+	}
+	return d
+}
+
+// RecordSuccess clears the failures of username. The IP counter is left
+// alone so one valid account cannot be used to reset guessing from an address.
+func (l *Limiter) RecordSuccess(username string) error {
+	return l.store.Reset(l.key(KindUsername, username))
+}
+
+// Locked lists the usernames and IPs currently locked out, for every limiter
+// sharing the store.
+func (l *Limiter) Locked() ([]Entry, error) {
+	return l.store.Locked(l.now())
+}
+
+// Unlock lifts a lockout and forgets the failures of key.
+func (l *Limiter) Unlock(key Key) error {
+	return l.store.Reset(key)
+}
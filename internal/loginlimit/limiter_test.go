@@ -0,0 +1,287 @@
+package loginlimit
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+var testPolicy = Policy{
+	MaxFailures:      3,
+	MaxFailuresPerIP: 10,
+	LockoutDuration:  15 * time.Minute,
+	FailureWindow:    10 * time.Minute,
+	BaseDelay:        time.Second,
+	MaxDelay:         4 * time.Second,
+}
+
+// newTestLimiter returns a limiter on a fresh MemoryStore whose clock only
+// moves when the returned advance function is called.
+func newTestLimiter(policy Policy) (*Limiter, *MemoryStore, func(time.Duration)) {
+	store := NewMemoryStore()
+	l := New(store, policy)
+
+	now := time.Date(2026, 1, 1, 8, 0, 0, 0, time.UTC)
+	l.now = func() time.Time { return now }
+	return l, store, func(d time.Duration) { now = now.Add(d) }
+}
+
+func fail(t *testing.T, l *Limiter, username, ip string) {
+	t.Helper()
+
+	attempt, wait, err := l.Reserve(username, ip)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if attempt == nil {
+		t.Fatalf("attempt for %s from %s refused, wait %s", username, ip, wait)
+	}
+	if err := attempt.Fail(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReserveIsAtomic(t *testing.T) {
+	l, _, _ := newTestLimiter(Policy{
+		MaxFailures:      5,
+		MaxFailuresPerIP: 100,
+		LockoutDuration:  time.Minute,
+		FailureWindow:    time.Minute,
+	})
+
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		allowed int
+	)
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			attempt, _, err := l.Reserve("alice", "10.0.0.1")
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if attempt != nil {
+				mu.Lock()
+				allowed++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if allowed != 5 {
+		t.Errorf("%d concurrent attempts got through, want 5", allowed)
+	}
+}
+
+func TestLockout(t *testing.T) {
+	l, _, advance := newTestLimiter(testPolicy)
+
+	for i := 0; i < testPolicy.MaxFailures; i++ {
+		fail(t, l, "alice", "10.0.0.1")
+		advance(testPolicy.MaxDelay)
+	}
+
+	attempt, wait, err := l.Reserve("alice", "10.0.0.2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if attempt != nil {
+		t.Fatal("attempt after reaching the threshold was allowed")
+	}
+	if want := testPolicy.LockoutDuration - testPolicy.MaxDelay; wait != want {
+		t.Errorf("wait = %s, want %s", wait, want)
+	}
+
+	locked, err := l.Locked()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(locked) != 1 || locked[0].Key != (Key{Kind: KindUsername, Value: "alice"}) || locked[0].Failures != 3 {
+		t.Errorf("locked = %+v, want alice with 3 failures", locked)
+	}
+
+	// Another username from the same address is not affected.
+	if attempt, _, _ := l.Reserve("bob", "10.0.0.1"); attempt == nil {
+		t.Error("attempt for another username was refused")
+	}
+
+	advance(testPolicy.LockoutDuration)
+	if attempt, _, _ := l.Reserve("alice", "10.0.0.1"); attempt == nil {
+		t.Error("attempt after the lockout was refused")
+	}
+}
+
+func TestUnlock(t *testing.T) {
+	l, store, _ := newTestLimiter(testPolicy)
+
+	for i := 0; i < testPolicy.MaxFailures; i++ {
+		attempt, _, _ := l.Reserve("alice", "10.0.0.1")
+		if attempt == nil {
+			t.Fatalf("attempt %d was refused", i+1)
+		}
+	}
+
+	key := Key{Kind: KindUsername, Value: "alice"}
+	if err := l.Unlock(key); err != nil {
+		t.Fatal(err)
+	}
+	if entry, _ := store.Get(key); entry != nil {
+		t.Errorf("entry after unlock = %+v, want none", entry)
+	}
+	if attempt, _, _ := l.Reserve("alice", "10.0.0.1"); attempt == nil {
+		t.Error("attempt after unlock was refused")
+	}
+}
+
+func TestIPLockout(t *testing.T) {
+	l, store, advance := newTestLimiter(Policy{
+		MaxFailures:      3,
+		MaxFailuresPerIP: 2,
+		LockoutDuration:  time.Minute,
+		FailureWindow:    time.Minute,
+	})
+
+	fail(t, l, "alice", "10.0.0.1")
+	fail(t, l, "bob", "10.0.0.1")
+	advance(time.Second)
+
+	attempt, wait, err := l.Reserve("carol", "10.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if attempt != nil {
+		t.Fatal("attempt from a locked address was allowed")
+	}
+	if wait != time.Minute-time.Second {
+		t.Errorf("wait = %s, want %s", wait, time.Minute-time.Second)
+	}
+
+	// The username reserved before the address was refused is given back.
+	entry, err := store.Get(Key{Kind: KindUsername, Value: "carol"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry != nil && entry.Failures != 0 {
+		t.Errorf("carol has %d failures after a refused attempt, want 0", entry.Failures)
+	}
+}
+
+func TestReleaseLiftsLock(t *testing.T) {
+	l, _, _ := newTestLimiter(testPolicy)
+
+	for i := 0; i < testPolicy.MaxFailures-1; i++ {
+		attempt, _, _ := l.Reserve("alice", "10.0.0.1")
+		if attempt == nil {
+			t.Fatalf("attempt %d was refused", i+1)
+		}
+	}
+
+	// The attempt reaching the threshold locks the username while it runs,
+	// but a right password must not leave the user locked out.
+	attempt, _, err := l.Reserve("alice", "10.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if attempt == nil {
+		t.Fatal("attempt at the threshold was refused")
+	}
+	if other, _, _ := l.Reserve("alice", "10.0.0.1"); other != nil {
+		t.Fatal("concurrent attempt during the last reservation was allowed")
+	}
+
+	if err := attempt.Release(); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.RecordSuccess("alice"); err != nil {
+		t.Fatal(err)
+	}
+	if attempt, _, _ := l.Reserve("alice", "10.0.0.1"); attempt == nil {
+		t.Error("attempt after a successful login was refused")
+	}
+}
+
+func TestProgressiveDelay(t *testing.T) {
+	l, _, advance := newTestLimiter(Policy{
+		MaxFailures:      10,
+		MaxFailuresPerIP: 10,
+		LockoutDuration:  time.Hour,
+		FailureWindow:    time.Hour,
+		BaseDelay:        time.Second,
+		MaxDelay:         4 * time.Second,
+	})
+
+	for _, want := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 4 * time.Second} {
+		fail(t, l, "alice", "10.0.0.1")
+
+		attempt, wait, err := l.Reserve("alice", "10.0.0.1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if attempt != nil || wait != want {
+			t.Fatalf("attempt right after a failure: allowed %v, wait %s, want refused for %s", attempt != nil, wait, want)
+		}
+		advance(want)
+	}
+}
+
+func TestRecordSuccess(t *testing.T) {
+	l, store, advance := newTestLimiter(testPolicy)
+
+	fail(t, l, "alice", "10.0.0.1")
+	fail(t, l, "bob", "10.0.0.2")
+	advance(testPolicy.MaxDelay)
+
+	if err := l.RecordSuccess("alice"); err != nil {
+		t.Fatal(err)
+	}
+	if entry, _ := store.Get(Key{Kind: KindUsername, Value: "alice"}); entry != nil {
+		t.Errorf("alice after success = %+v, want no failures", entry)
+	}
+	if entry, _ := store.Get(Key{Kind: KindIP, Value: "10.0.0.1"}); entry == nil || entry.Failures != 1 {
+		t.Errorf("address after success = %+v, want 1 failure kept", entry)
+	}
+	if entry, _ := store.Get(Key{Kind: KindUsername, Value: "bob"}); entry == nil || entry.Failures != 1 {
+		t.Errorf("bob after alice's success = %+v, want 1 failure kept", entry)
+	}
+}
+
+func TestScope(t *testing.T) {
+	policy := testPolicy
+	policy.Scope = "pin"
+	l, store, _ := newTestLimiter(policy)
+	password := New(store, testPolicy)
+
+	for i := 0; i < policy.MaxFailures; i++ {
+		if attempt, _, _ := l.Reserve("alice", "10.0.0.1"); attempt == nil {
+			t.Fatalf("attempt %d was refused", i+1)
+		}
+	}
+
+	if entry, _ := store.Get(Key{Kind: "pin:" + KindUsername, Value: "alice"}); entry == nil || !entry.Locked {
+		t.Errorf("pin entry = %+v, want locked", entry)
+	}
+	if attempt, _, _ := password.Reserve("alice", "10.0.0.2"); attempt == nil {
+		t.Error("PIN lockout also locked password logins")
+	}
+}
+
+func TestIsValidKind(t *testing.T) {
+	for kind, want := range map[string]bool{
+		KindUsername:          true,
+		KindIP:                true,
+		"pin:" + KindUsername: true,
+		"pin:" + KindIP:       true,
+		"device":              false,
+		"pin:device":          false,
+		"":                    false,
+	} {
+		if got := IsValidKind(kind); got != want {
+			t.Errorf("IsValidKind(%q) = %v, want %v", kind, got, want)
+		}
+	}
+}
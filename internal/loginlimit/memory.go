@@ -0,0 +1,128 @@
+package loginlimit
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryStore keeps failure counters in process. Counters are lost on restart
+// and not shared between instances.
+type MemoryStore struct {
+	mu        sync.Mutex
+	entries   map[Key]*Entry
+	lastPrune time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{entries: make(map[Key]*Entry)}
+}
+
+func (s *MemoryStore) Get(key Key) (*Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.entries[key]
+	if !ok {
+		return nil, nil
+	}
+	e := *entry
+	return &e, nil
+}
+
+func (s *MemoryStore) Reserve(key Key, now, since time.Time, maxFailures int, lockUntil time.Time) (int, time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.prune(now, since)
+
+	entry, ok := s.entries[key]
+	if !ok {
+		entry = &Entry{Key: key}
+		s.entries[key] = entry
+	}
+	if entry.BlockedUntil.After(now) {
+		return 0, entry.BlockedUntil, nil
+	}
+	if entry.LastFailureAt.Before(since) {
+		entry.Failures = 0
+	}
+
+	entry.Failures++
+	entry.LastFailureAt = now
+	if maxFailures > 0 && entry.Failures >= maxFailures {
+		entry.BlockedUntil = lockUntil
+		entry.Locked = true
+	}
+	return entry.Failures, time.Time{}, nil
+}
+
+func (s *MemoryStore) Release(key Key, lockedUntil time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.entries[key]
+	if !ok {
+		return nil
+	}
+	if entry.Failures > 0 {
+		entry.Failures--
+	}
+	if !lockedUntil.IsZero() && entry.Locked && entry.BlockedUntil.Equal(lockedUntil) {
+		entry.BlockedUntil = time.Time{}
+		entry.Locked = false
+	}
+	return nil
+}
+
+func (s *MemoryStore) Block(key Key, until time.Time, locked bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if entry, ok := s.entries[key]; ok && !entry.BlockedUntil.After(until) {
+		entry.BlockedUntil = until
+		entry.Locked = locked
+	}
+	return nil
+}
+
+func (s *MemoryStore) Reset(key Key) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.entries, key)
+	return nil
+}
+
+func (s *MemoryStore) Locked(now time.Time) ([]Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var locked []Entry
+	for _, entry := range s.entries {
+		if entry.Locked && entry.BlockedUntil.After(now) {
+			locked = append(locked, *entry)
+		}
+	}
+
+	sort.Slice(locked, func(i, j int) bool {
+		return locked[i].BlockedUntil.Before(locked[j].BlockedUntil)
+	})
+	return locked, nil
+}
+
+// prune drops entries that no longer block anything and whose failures have
+// expired, at most once per failure window, so guessing from many addresses
+// cannot grow the map forever.
+func (s *MemoryStore) prune(now, since time.Time) {
+	if now.Sub(s.lastPrune) < now.Sub(since) {
+		return
+	}
+	s.lastPrune = now
+
+	for key, entry := range s.entries {
+		if entry.LastFailureAt.Before(since) && !entry.BlockedUntil.After(now) {
+			delete(s.entries, key)
+		}
+	}
+}
@@ -1,17 +1,20 @@
 package middleware
 
 import (
-	"database/sql"
 	"net/http"
 	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
 
+	"pwa-backend/internal/config"
+	"pwa-backend/internal/jwtkeys"
+	"pwa-backend/internal/models"
+	"pwa-backend/internal/rbac"
 	"pwa-backend/internal/repositories"
 )
 
-func AuthMiddleware(jwtSecret string, sessionRepo *repositories.UserSessionRepository) gin.HandlerFunc {
+func AuthMiddleware(keys *jwtkeys.KeySet, jwtConfig *config.JWTConfig, sessionRepo *repositories.UserSessionRepository) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
@@ -27,12 +30,7 @@ func AuthMiddleware(jwtSecret string, sessionRepo *repositories.UserSessionRepos
 			tokenString = authHeader
 		}
 
-		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, jwt.ErrSignatureInvalid
-			}
-			return []byte(jwtSecret), nil
-		})
+		token, err := keys.Parse(tokenString, jwtConfig.Issuer, jwtConfig.Audience)
 
 		if err != nil || !token.Valid {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
@@ -46,27 +44,73 @@ func AuthMiddleware(jwtSecret string, sessionRepo *repositories.UserSessionRepos
 
 			// Validate session if present
 			if sessionID != nil {
-				isValid, err := sessionRepo.IsSessionValid(sessionID.(string))
-				if err != nil && err != sql.ErrNoRows {
+				session, err := sessionRepo.GetActive(sessionID.(string))
+				if err != nil {
 					c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to validate session"})
 					c.Abort()
 					return
 				}
 
-				if !isValid {
+				if session == nil {
 					c.JSON(http.StatusUnauthorized, gin.H{"error": "Session invalid or revoked"})
 					c.Abort()
 					return
 				}
 
-				c.Set("session_id", sessionID.(string))
+				c.Set("session_id", session.ID)
+
+				// The device comes from the session, never from the request
+				// body, so handlers can stamp it on what they write.
+				if session.DeviceID != nil {
+					c.Set("device_id", *session.DeviceID)
+				}
+
+				// Like the device, the scope follows the session so a
+				// token cannot widen what a PIN login may do.
+				if session.AuthMethod == models.AuthMethodPIN {
+					c.Set("scope", rbac.ScopePIN)
+				}
 			}
 
 			c.Set("user_id", userID)
 			c.Set("username", claims["username"])
 			c.Set("role", claims["role"])
+
+			// The store is resolved when the token is issued and signed
+			// into it, like the PowerSync parameter claims.
+			if storeID, _ := claims["store_id"].(string); storeID != "" {
+				c.Set("store_id", storeID)
+			}
+
+			if pwdChange, _ := claims["pwd_change"].(bool); pwdChange {
+				c.Set("password_change_required", true)
+			}
+			if mfaEnroll, _ := claims["mfa_enroll"].(bool); mfaEnroll {
+				c.Set("two_factor_enrollment_required", true)
+			}
+		}
+
+		c.Next()
+	}
+}
+
+// RequireAccountReady blocks users with pending account setup: a password
+// reset by an admin that has not been changed yet, or two-factor enrolment
+// required by their role. It must run after AuthMiddleware.
+func RequireAccountReady() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetBool("password_change_required") {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Password change required"})
+			c.Abort()
+			return
+		}
+
+		if c.GetBool("two_factor_enrollment_required") {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Two-factor enrolment required"})
+			c.Abort()
+			return
 		}
 
 		c.Next()
 	}
-}
\ No newline at end of file
+}
@@ -0,0 +1,78 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"pwa-backend/internal/rbac"
+)
+
+// RequireRole only lets through users whose role is one of roles. Tokens with
+// a restricted scope never pass, since role checks guard administration. It
+// must run after AuthMiddleware.
+func RequireRole(roles ...rbac.Role) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if rejectScoped(c) {
+			return
+		}
+
+		role := rbac.Role(c.GetString("role"))
+		for _, allowed := range roles {
+			if role == allowed {
+				c.Next()
+				return
+			}
+		}
+
+		c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient role"})
+		c.Abort()
+	}
+}
+
+// RequireFullLogin blocks tokens with a restricted scope, e.g. from a PIN
+// login, on routes that change credentials. It must run after AuthMiddleware.
+func RequireFullLogin() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if rejectScoped(c) {
+			return
+		}
+		c.Next()
+	}
+}
+
+func rejectScoped(c *gin.Context) bool {
+	if c.GetString("scope") == "" {
+		return false
+	}
+
+	c.JSON(http.StatusForbidden, gin.H{"error": "Not allowed with a PIN login"})
+	c.Abort()
+	return true
+}
+
+// RequirePermission only lets through users whose role grants perm. It must
+// run after AuthMiddleware.
+func RequirePermission(perm rbac.Permission) gin.HandlerFunc {
+	return RequireAnyPermission(perm)
+}
+
+// RequireAnyPermission lets through users whose role grants at least one of
+// perms. Handlers are expected to narrow the check further.
+func RequireAnyPermission(perms ...rbac.Permission) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		for _, perm := range perms {
+			if HasPermission(c, perm) {
+				c.Next()
+				return
+			}
+		}
+
+		c.JSON(http.StatusForbidden, gin.H{"error": "Permission denied"})
+		c.Abort()
+	}
+}
+
+func HasPermission(c *gin.Context, perm rbac.Permission) bool {
+	return rbac.CanInScope(c.GetString("role"), c.GetString("scope"), perm)
+}
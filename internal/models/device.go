@@ -0,0 +1,35 @@
+package models
+
+import "time"
+
+// Device is a registered client (POS till, tablet, browser). A deactivated
+// device can no longer log in and its sessions are revoked.
+type Device struct {
+	ID            string     `json:"id"`
+	Name          string     `json:"name"`
+	StoreID       *string    `json:"store_id"`
+	RegisteredBy  *string    `json:"registered_by,omitempty"`
+	LastSeenAt    *time.Time `json:"last_seen_at"`
+	DeactivatedAt *time.Time `json:"deactivated_at"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+func (d *Device) IsActive() bool {
+	return d.DeactivatedAt == nil
+}
+
+type RegisterDeviceRequest struct {
+	// ID is an optional client-generated device id, e.g. one the PWA already
+	// keeps in local storage.
+	ID      string  `json:"id" binding:"omitempty,max=36"`
+	Name    string  `json:"name" binding:"required,max=100"`
+	StoreID *string `json:"store_id" binding:"omitempty,max=36"`
+}
+
+// UpdateDeviceRequest renames a device or moves it to another store. An empty
+// store_id unassigns it.
+type UpdateDeviceRequest struct {
+	Name    *string `json:"name" binding:"omitempty,min=1,max=100"`
+	StoreID *string `json:"store_id" binding:"omitempty,max=36"`
+}
@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+type IdempotencyKey struct {
+	UserID         string    `json:"user_id"`
+	Key            string    `json:"key"`
+	Endpoint       string    `json:"endpoint"`
+	RequestHash    string    `json:"request_hash"`
+	ResponseStatus *int      `json:"response_status"`
+	ResponseBody   []byte    `json:"-"`
+	CreatedAt      time.Time `json:"created_at"`
+	ExpiresAt      time.Time `json:"expires_at"`
+}
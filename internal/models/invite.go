@@ -0,0 +1,35 @@
+package models
+
+import "time"
+
+// Invite lets one person register with a fixed role and store. It can be
+// used once, before ExpiresAt, unless an admin revoked it.
+type Invite struct {
+	ID        string     `json:"id"`
+	Role      string     `json:"role"`
+	StoreID   *string    `json:"store_id"`
+	CreatedBy *string    `json:"created_by,omitempty"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at"`
+	UsedBy    *string    `json:"used_by"`
+	RevokedAt *time.Time `json:"revoked_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+func (i *Invite) IsUsable(now time.Time) bool {
+	return i.UsedAt == nil && i.RevokedAt == nil && now.Before(i.ExpiresAt)
+}
+
+type CreateInviteRequest struct {
+	Role    string  `json:"role" binding:"required,oneof=admin manager cashier staff"`
+	StoreID *string `json:"store_id" binding:"omitempty,max=36"`
+	// ExpiresInHours overrides the default invite lifetime.
+	ExpiresInHours int `json:"expires_in_hours" binding:"omitempty,min=1,max=720"`
+}
+
+// InviteResponse is returned once when an invite is created. The token is
+// not stored and cannot be shown again.
+type InviteResponse struct {
+	Invite
+	Token string `json:"token"`
+}
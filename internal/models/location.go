@@ -0,0 +1,44 @@
+package models
+
+import "time"
+
+// DefaultLocationName is the name of the default location created with a
+// store.
+const DefaultLocationName = "Shop floor"
+
+// Location is a place inside a store where stock is kept, such as the back
+// room or the shop floor. Each store has one default location that sales,
+// cancellations and returns use.
+type Location struct {
+	ID        string    `json:"id"`
+	StoreID   string    `json:"store_id"`
+	Name      string    `json:"name"`
+	IsDefault bool      `json:"is_default"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type CreateLocationRequest struct {
+	// ID is an optional client-generated location id.
+	ID   string `json:"id" binding:"omitempty,max=36"`
+	Name string `json:"name" binding:"required,max=100"`
+	// StoreID defaults to the caller's store. Only callers without one, such
+	// as admins, may pick a store.
+	StoreID string `json:"store_id" binding:"omitempty,max=36"`
+}
+
+// UpdateLocationRequest changes only the fields that are present. Setting
+// is_default makes the location the store's default; the previous default
+// loses the flag. A default cannot be unset directly.
+type UpdateLocationRequest struct {
+	Name      *string `json:"name" binding:"omitempty,min=1,max=100"`
+	IsDefault *bool   `json:"is_default"`
+}
+
+// LocationStock is the stock of one product at one location.
+type LocationStock struct {
+	LocationID string    `json:"location_id"`
+	ProductID  string    `json:"product_id"`
+	Stock      int       `json:"stock"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
@@ -1,14 +1,46 @@
 package models
 
-import "time"
+import (
+	"time"
+
+	"pwa-backend/internal/money"
+)
 
 type Product struct {
-	ID          string    `json:"id"`
-	Name        string    `json:"name"`
-	Description string    `json:"description"`
-	Price       float64   `json:"price"`
-	Stock       int       `json:"stock"`
-	ImageURL    string    `json:"image_url"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
-}
\ No newline at end of file
+	ID          string      `json:"id"`
+	Name        string      `json:"name"`
+	Description string      `json:"description"`
+	Price       money.Money `json:"price"`
+	// Stock is the stock in StoreID, or the total over all stores when
+	// StoreID is empty.
+	Stock      int        `json:"stock"`
+	StoreID    *string    `json:"store_id,omitempty"`
+	ImageURL   string     `json:"image_url"`
+	ArchivedAt *time.Time `json:"archived_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+}
+
+type CreateProductRequest struct {
+	Name         string      `json:"name" binding:"required,max=100"`
+	Description  string      `json:"description"`
+	Price        money.Money `json:"price"`
+	ImageURL     string      `json:"image_url" binding:"omitempty,url"`
+	InitialStock int         `json:"initial_stock" binding:"min=0"`
+	// StoreID receives the initial stock. It defaults to the caller's store.
+	StoreID string `json:"store_id" binding:"omitempty,max=36"`
+}
+
+type UpdateProductRequest struct {
+	Name        string      `json:"name" binding:"required,max=100"`
+	Description string      `json:"description"`
+	Price       money.Money `json:"price"`
+	ImageURL    string      `json:"image_url" binding:"omitempty,url"`
+}
+
+type PatchProductRequest struct {
+	Name        *string      `json:"name" binding:"omitempty,min=1,max=100"`
+	Description *string      `json:"description"`
+	Price       *money.Money `json:"price"`
+	ImageURL    *string      `json:"image_url" binding:"omitempty,url"`
+}
@@ -0,0 +1,35 @@
+package models
+
+import "time"
+
+// Stock cache levels compared against the stock_events ledger.
+const (
+	DriftLevelProduct  = "product"
+	DriftLevelStore    = "store"
+	DriftLevelLocation = "location"
+)
+
+// StockDrift is a cached stock level that disagrees with the ledger. Store
+// and location drifts name the store or location the cache belongs to.
+type StockDrift struct {
+	Level      string  `json:"level"`
+	ProductID  string  `json:"product_id"`
+	StoreID    *string `json:"store_id,omitempty"`
+	LocationID *string `json:"location_id,omitempty"`
+	Cached     int     `json:"cached"`
+	Ledger     int     `json:"ledger"`
+}
+
+// Diff is how far the cache is off: positive when it shows more stock than
+// the ledger.
+func (d StockDrift) Diff() int {
+	return d.Cached - d.Ledger
+}
+
+type ReconciliationReport struct {
+	RunID     string       `json:"run_id"`
+	Trigger   string       `json:"trigger"`
+	Rebuilt   bool         `json:"rebuilt"`
+	Drifts    []StockDrift `json:"drifts"`
+	CheckedAt time.Time    `json:"checked_at"`
+}
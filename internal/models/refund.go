@@ -0,0 +1,55 @@
+package models
+
+import (
+	"time"
+
+	"pwa-backend/internal/money"
+)
+
+type Refund struct {
+	ID            string       `json:"id"`
+	TransactionID string       `json:"transaction_id"`
+	UserID        *string      `json:"user_id,omitempty"`
+	Reason        string       `json:"reason"`
+	TotalAmount   money.Money  `json:"total_amount"`
+	Items         []RefundItem `json:"items,omitempty"`
+	CreatedAt     time.Time    `json:"created_at"`
+}
+
+type RefundItem struct {
+	ID                string      `json:"id"`
+	RefundID          string      `json:"refund_id"`
+	TransactionItemID string      `json:"transaction_item_id"`
+	ProductID         string      `json:"product_id"`
+	Quantity          int         `json:"quantity"`
+	Amount            money.Money `json:"amount"`
+	Restock           bool        `json:"restock"`
+	CreatedAt         time.Time   `json:"created_at"`
+}
+
+type CreateRefundRequest struct {
+	Reason string             `json:"reason"`
+	Items  []CreateRefundItem `json:"items" binding:"required,min=1,dive"`
+}
+
+type CreateRefundItem struct {
+	TransactionItemID string `json:"transaction_item_id" binding:"required"`
+	Quantity          int    `json:"quantity" binding:"required,min=1"`
+	// Restock puts the returned units back on the shelf with a 'return'
+	// stock event. Leave it false for damaged goods.
+	Restock bool `json:"restock"`
+}
+
+// RefundLimitExceeded describes a line that would be refunded beyond what was
+// sold.
+type RefundLimitExceeded struct {
+	TransactionItemID string `json:"transaction_item_id"`
+	Sold              int    `json:"sold"`
+	AlreadyRefunded   int    `json:"already_refunded"`
+	Requested         int    `json:"requested"`
+}
+
+type RefundConflictResponse struct {
+	Error string                `json:"error"`
+	Items []RefundLimitExceeded `json:"items"`
+}
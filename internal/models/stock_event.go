@@ -4,11 +4,15 @@ import "time"
 
 type StockEvent struct {
 	ID            string    `json:"id"`
+	StoreID       string    `json:"store_id"`
+	LocationID    string    `json:"location_id"`
 	ProductID     string    `json:"product_id"`
 	Qty           int       `json:"qty"` // Positive or negative
-	Type          string    `json:"type"` // sale, restock, reject, adjustment, opening_stock
+	Type          string    `json:"type"` // sale, restock, reject, adjustment, opening_stock, cancellation, return, transfer_out, transfer_in
 	Source        string    `json:"source"` // pos, dashboard, online
 	TransactionID *string   `json:"transaction_id,omitempty"`
+	TransferID    *string   `json:"transfer_id,omitempty"`
+	StocktakeID   *string   `json:"stocktake_id,omitempty"`
 	UserID        *string   `json:"user_id,omitempty"`
 	DeviceID      *string   `json:"device_id,omitempty"`
 	Note          string    `json:"note"`
@@ -16,10 +20,28 @@ type StockEvent struct {
 }
 
 type CreateStockEventRequest struct {
-	ProductID string  `json:"product_id" binding:"required"`
-	Qty       int     `json:"qty" binding:"required"`
-	Type      string  `json:"type" binding:"required,oneof=sale restock reject adjustment opening_stock"`
-	Source    string  `json:"source" binding:"required,oneof=pos dashboard online"`
-	DeviceID  *string `json:"device_id"`
-	Note      string  `json:"note"`
-}
\ No newline at end of file
+	ProductID string `json:"product_id" binding:"required"`
+	Qty       int    `json:"qty" binding:"required"`
+	Type      string `json:"type" binding:"required,oneof=sale restock reject adjustment opening_stock"`
+	Source    string `json:"source" binding:"required,oneof=pos dashboard online"`
+	Note      string `json:"note"`
+	// StoreID defaults to the caller's store. Only callers without one, such
+	// as admins, may pick a store.
+	StoreID string `json:"store_id" binding:"omitempty,max=36"`
+	// LocationID defaults to the store's default location.
+	LocationID string `json:"location_id" binding:"omitempty,max=36"`
+}
+
+// StockShortage describes a product that does not have enough stock for the
+// requested quantity.
+type StockShortage struct {
+	ProductID   string `json:"product_id"`
+	ProductName string `json:"product_name"`
+	Requested   int    `json:"requested"`
+	Available   int    `json:"available"`
+}
+
+type StockConflictResponse struct {
+	Error string          `json:"error"`
+	Items []StockShortage `json:"items"`
+}
@@ -0,0 +1,40 @@
+package models
+
+import "time"
+
+// StockAsOf is the stock of a product at a point in time, summed from the
+// stock_events ledger.
+type StockAsOf struct {
+	ProductID   string `json:"product_id"`
+	ProductName string `json:"product_name"`
+	Stock       int    `json:"stock"`
+}
+
+type StockAsOfResponse struct {
+	At         time.Time   `json:"at"`
+	StoreID    *string     `json:"store_id,omitempty"`
+	LocationID *string     `json:"location_id,omitempty"`
+	Items      []StockAsOf `json:"items"`
+}
+
+// StockMovementSummary gives the stock of a product at the start and end of a
+// period and what moved in between. Inflow and Outflow are keyed by stock
+// event type; outflow is reported as positive quantities.
+type StockMovementSummary struct {
+	ProductID   string         `json:"product_id"`
+	ProductName string         `json:"product_name"`
+	Opening     int            `json:"opening"`
+	Inflow      map[string]int `json:"inflow"`
+	Outflow     map[string]int `json:"outflow"`
+	TotalIn     int            `json:"total_in"`
+	TotalOut    int            `json:"total_out"`
+	Closing     int            `json:"closing"`
+}
+
+type StockMovementResponse struct {
+	From       time.Time              `json:"from"`
+	To         time.Time              `json:"to"`
+	StoreID    *string                `json:"store_id,omitempty"`
+	LocationID *string                `json:"location_id,omitempty"`
+	Items      []StockMovementSummary `json:"items"`
+}
@@ -0,0 +1,50 @@
+package models
+
+import "time"
+
+const (
+	TransferStatusInTransit = "in_transit"
+	TransferStatusCompleted = "completed"
+	TransferStatusCancelled = "cancelled"
+)
+
+// StockTransfer moves stock between two locations. The source is booked out
+// with transfer_out stock events and the destination booked in with
+// transfer_in events. Within one store both happen at once; between stores
+// the transfer is in_transit until the receiving store confirms it.
+type StockTransfer struct {
+	ID             string              `json:"id"`
+	FromStoreID    string              `json:"from_store_id"`
+	FromLocationID string              `json:"from_location_id"`
+	ToStoreID      string              `json:"to_store_id"`
+	ToLocationID   string              `json:"to_location_id"`
+	Status         string              `json:"status"`
+	Note           string              `json:"note"`
+	CreatedBy      *string             `json:"created_by,omitempty"`
+	ReceivedBy     *string             `json:"received_by,omitempty"`
+	Items          []StockTransferItem `json:"items,omitempty"`
+	CreatedAt      time.Time           `json:"created_at"`
+	ReceivedAt     *time.Time          `json:"received_at,omitempty"`
+	CancelledAt    *time.Time          `json:"cancelled_at,omitempty"`
+}
+
+type StockTransferItem struct {
+	ID         string `json:"id"`
+	TransferID string `json:"transfer_id"`
+	ProductID  string `json:"product_id"`
+	Qty        int    `json:"qty"`
+}
+
+type CreateStockTransferRequest struct {
+	// ID is an optional client-generated transfer id.
+	ID             string                    `json:"id" binding:"omitempty,max=36"`
+	FromLocationID string                    `json:"from_location_id" binding:"required"`
+	ToLocationID   string                    `json:"to_location_id" binding:"required,nefield=FromLocationID"`
+	Note           string                    `json:"note"`
+	Items          []CreateStockTransferItem `json:"items" binding:"required,min=1,dive"`
+}
+
+type CreateStockTransferItem struct {
+	ProductID string `json:"product_id" binding:"required"`
+	Qty       int    `json:"qty" binding:"required,min=1"`
+}
@@ -0,0 +1,87 @@
+package models
+
+import "time"
+
+const (
+	StocktakeStatusOpen      = "open"
+	StocktakeStatusApproved  = "approved"
+	StocktakeStatusCancelled = "cancelled"
+)
+
+// Stocktake is a physical count of one location. Expected quantities are
+// frozen when it is opened; approving it books every difference between the
+// counted quantity and the stock the ledger held when it was counted as an
+// adjustment stock event.
+type Stocktake struct {
+	ID          string          `json:"id"`
+	StoreID     string          `json:"store_id"`
+	LocationID  string          `json:"location_id"`
+	Status      string          `json:"status"`
+	Note        string          `json:"note"`
+	CreatedBy   *string         `json:"created_by,omitempty"`
+	ApprovedBy  *string         `json:"approved_by,omitempty"`
+	Items       []StocktakeItem `json:"items,omitempty"`
+	CreatedAt   time.Time       `json:"created_at"`
+	ApprovedAt  *time.Time      `json:"approved_at,omitempty"`
+	CancelledAt *time.Time      `json:"cancelled_at,omitempty"`
+}
+
+// StocktakeItem is the expected and counted quantity of one product. Counted
+// and Variance are nil until the product has been counted; uncounted products
+// are left alone on approval. Counts is the number of counts that make up
+// Counted and CountedAt when the latest of them was made.
+type StocktakeItem struct {
+	ProductID   string     `json:"product_id"`
+	ProductName string     `json:"product_name"`
+	Expected    int        `json:"expected"`
+	Counted     *int       `json:"counted"`
+	Variance    *int       `json:"variance"`
+	Counts      int        `json:"counts"`
+	CountedAt   *time.Time `json:"counted_at,omitempty"`
+}
+
+// StocktakeCount is one submitted count of a product. Counts of a product add
+// up, except that a recount replaces everything counted before it.
+type StocktakeCount struct {
+	ID          string    `json:"id"`
+	StocktakeID string    `json:"stocktake_id"`
+	ProductID   string    `json:"product_id"`
+	Qty         int       `json:"qty"`
+	Recount     bool      `json:"recount"`
+	UserID      *string   `json:"user_id,omitempty"`
+	DeviceID    *string   `json:"device_id,omitempty"`
+	CountedAt   time.Time `json:"counted_at"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+type CreateStocktakeRequest struct {
+	// ID is an optional client-generated stocktake id.
+	ID string `json:"id" binding:"omitempty,max=36"`
+	// LocationID defaults to the default location of the caller's store.
+	LocationID string `json:"location_id" binding:"omitempty,max=36"`
+	// StoreID picks the store of the default location for admins without a
+	// store. It is ignored when LocationID is set.
+	StoreID string `json:"store_id" binding:"omitempty,max=36"`
+	Note    string `json:"note"`
+}
+
+type SubmitStocktakeCountsRequest struct {
+	Counts []SubmitStocktakeCount `json:"counts" binding:"required,min=1,dive"`
+}
+
+type SubmitStocktakeCount struct {
+	// ID is an optional client-generated count id. Counts that were already
+	// submitted under the same id are skipped, so offline clients can retry.
+	ID        string `json:"id" binding:"omitempty,max=36"`
+	ProductID string `json:"product_id" binding:"required"`
+	Qty       *int   `json:"qty" binding:"required,min=0"`
+	Recount   bool   `json:"recount"`
+	// CountedAt is when the count was made on the device. It orders counts
+	// against recounts and defaults to the time of upload.
+	CountedAt *time.Time `json:"counted_at"`
+}
+
+type SubmitStocktakeCountsResponse struct {
+	Applied int `json:"applied"`
+	Skipped int `json:"skipped"`
+}
@@ -0,0 +1,33 @@
+package models
+
+import "time"
+
+// Store is an outlet. Stock, sales and stock events are kept per store, and
+// users and devices are assigned to one.
+type Store struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Address   *string   `json:"address"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type CreateStoreRequest struct {
+	// ID is an optional client-generated store id.
+	ID      string  `json:"id" binding:"omitempty,max=36"`
+	Name    string  `json:"name" binding:"required,max=100"`
+	Address *string `json:"address"`
+}
+
+type UpdateStoreRequest struct {
+	Name    *string `json:"name" binding:"omitempty,min=1,max=100"`
+	Address *string `json:"address"`
+}
+
+// ProductStock is the stock of one product in one store.
+type ProductStock struct {
+	StoreID   string    `json:"store_id"`
+	ProductID string    `json:"product_id"`
+	Stock     int       `json:"stock"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
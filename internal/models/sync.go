@@ -0,0 +1,69 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// CrudEntry is one PowerSync CRUD operation as produced by CrudEntry.toJSON()
+// in the client SDK.
+type CrudEntry struct {
+	OpID  *int64          `json:"op_id"`
+	Op    string          `json:"op" binding:"required,oneof=PUT PATCH DELETE"`
+	Table string          `json:"type" binding:"required"`
+	ID    string          `json:"id" binding:"required"`
+	TxID  *int64          `json:"tx_id"`
+	Data  json.RawMessage `json:"data"`
+}
+
+type CrudBatchRequest struct {
+	Batch []CrudEntry `json:"batch" binding:"required,min=1,dive"`
+}
+
+type CrudBatchResponse struct {
+	Applied int `json:"applied"`
+	// Skipped counts operations that were already applied by an earlier
+	// upload of the same batch.
+	Skipped int `json:"skipped"`
+}
+
+type CrudFailure struct {
+	Index  int         `json:"index"`
+	Op     string      `json:"op"`
+	Table  string      `json:"type"`
+	ID     string      `json:"id"`
+	Status int         `json:"status"`
+	Error  string      `json:"error"`
+	Detail interface{} `json:"detail,omitempty"`
+}
+
+// CrudErrorResponse is returned when a batch is rejected. Nothing from the
+// batch is applied. Retryable failures (e.g. database unavailable) should be
+// uploaded again unchanged; permanent ones will fail the same way every time
+// and must be discarded or fixed on the client.
+type CrudErrorResponse struct {
+	Error     string      `json:"error"`
+	Retryable bool        `json:"retryable"`
+	Failure   CrudFailure `json:"failure"`
+}
+
+type SyncTransactionData struct {
+	Status string `json:"status" binding:"omitempty,oneof=pending completed cancelled"`
+	// StoreID is only read on PUT and follows the same rules as checkout.
+	StoreID string `json:"store_id" binding:"omitempty,max=36"`
+}
+
+type SyncTransactionItemData struct {
+	TransactionID string `json:"transaction_id" binding:"required"`
+	ProductID     string `json:"product_id" binding:"required"`
+	Quantity      int    `json:"quantity" binding:"required,min=1"`
+}
+
+type SyncStocktakeCountData struct {
+	StocktakeID string `json:"stocktake_id" binding:"required"`
+	ProductID   string `json:"product_id" binding:"required"`
+	Qty         *int   `json:"qty" binding:"required,min=0"`
+	// Recount is 0 or 1, as SQLite on the client has no boolean type.
+	Recount   int        `json:"recount" binding:"omitempty,oneof=0 1"`
+	CountedAt *time.Time `json:"counted_at"`
+}
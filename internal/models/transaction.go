@@ -1,39 +1,80 @@
 package models
 
-import "time"
+import (
+	"time"
+
+	"pwa-backend/internal/money"
+)
 
 type Transaction struct {
-	ID            string             `json:"id"`
-	UserID        string             `json:"user_id"`
-	TotalAmount   float64            `json:"total_amount"`
-	Status        string             `json:"status"` // pending, completed, cancelled
-	CreatedAt     time.Time          `json:"created_at"`
-	UpdatedAt     time.Time          `json:"updated_at"`
-	Items         []TransactionItem  `json:"items,omitempty"`
+	ID          string      `json:"id"`
+	UserID      string      `json:"user_id"`
+	StoreID     string      `json:"store_id"`
+	DeviceID    *string     `json:"device_id,omitempty"`
+	TotalAmount money.Money `json:"total_amount"`
+	// RefundedAmount is the sum of all refunds; NetAmount is what the
+	// customer finally paid.
+	RefundedAmount money.Money       `json:"refunded_amount"`
+	NetAmount      money.Money       `json:"net_amount"`
+	Status         string            `json:"status"` // pending, completed, cancelled, refunded
+	CreatedAt      time.Time         `json:"created_at"`
+	UpdatedAt      time.Time         `json:"updated_at"`
+	Items          []TransactionItem `json:"items,omitempty"`
 }
 
 type TransactionItem struct {
-	ID            string    `json:"id"`
-	TransactionID string    `json:"transaction_id"`
-	ProductID     string    `json:"product_id"`
-	ProductName   string    `json:"product_name"`
-	Quantity      int       `json:"quantity"`
-	Price         float64   `json:"price"`
-	Subtotal      float64   `json:"subtotal"`
-	UserID        string    `json:"user_id"`
-	CreatedAt     time.Time `json:"created_at"`
+	ID            string      `json:"id"`
+	TransactionID string      `json:"transaction_id"`
+	ProductID     string      `json:"product_id"`
+	ProductName   string      `json:"product_name"`
+	Quantity      int         `json:"quantity"`
+	Price         money.Money `json:"price"`
+	Subtotal      money.Money `json:"subtotal"`
+	UserID        string      `json:"user_id"`
+	CreatedAt     time.Time   `json:"created_at"`
 }
 
 type CheckoutRequest struct {
-	Items []CheckoutItem `json:"items" binding:"required"`
+	// ID is an optional client-generated transaction id. Sending the same id
+	// again replays the original checkout instead of creating a new sale.
+	ID    string         `json:"id" binding:"omitempty,max=36"`
+	Items []CheckoutItem `json:"items" binding:"required,min=1,dive"`
+	// StoreID defaults to the caller's store. Only callers without one, such
+	// as admins, may pick a store.
+	StoreID string `json:"store_id" binding:"omitempty,max=36"`
 }
 
 type CheckoutItem struct {
+	// ID is an optional client-generated transaction item id.
+	ID        string `json:"id" binding:"omitempty,max=36"`
 	ProductID string `json:"product_id" binding:"required"`
 	Quantity  int    `json:"quantity" binding:"required,min=1"`
 }
 
-
 type UpdateStatusRequest struct {
-	Status string `json:"status" binding:"required,oneof=pending completed cancelled"`
-}
\ No newline at end of file
+	Status string `json:"status" binding:"required,oneof=completed cancelled"`
+}
+
+const (
+	TransactionStatusPending   = "pending"
+	TransactionStatusCompleted = "completed"
+	TransactionStatusCancelled = "cancelled"
+	TransactionStatusRefunded  = "refunded"
+)
+
+// transactionTransitions lists the statuses each status may move to.
+// Cancelled and refunded are final. Refunded is only reached through the
+// refund endpoint once every unit is refunded, so it is not listed here.
+var transactionTransitions = map[string][]string{
+	TransactionStatusPending:   {TransactionStatusCompleted, TransactionStatusCancelled},
+	TransactionStatusCompleted: {TransactionStatusCancelled},
+}
+
+func CanTransitionTransaction(from, to string) bool {
+	for _, allowed := range transactionTransitions[from] {
+		if allowed == to {
+			return true
+		}
+	}
+	return false
+}
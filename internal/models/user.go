@@ -3,28 +3,114 @@ package models
 import "time"
 
 type User struct {
-	ID        string    `json:"id"`
-	Username  string    `json:"username"`
-	Password  string    `json:"-"`
-	Name      string    `json:"name"`
-	Role      string    `json:"role"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID       string  `json:"id"`
+	Username string  `json:"username"`
+	Password string  `json:"-"`
+	Name     string  `json:"name"`
+	Role     string  `json:"role"`
+	StoreID  *string `json:"store_id"`
+	// MustChangePassword is set by an admin reset; the user can do nothing
+	// but change the password until it is cleared.
+	MustChangePassword bool       `json:"must_change_password"`
+	PasswordChangedAt  *time.Time `json:"password_changed_at,omitempty"`
+	// TOTPSecret is set once enrolment starts; two-factor login is only
+	// enforced after TwoFactorEnabledAt is set.
+	TOTPSecret         *string    `json:"-"`
+	TOTPLastStep       int64      `json:"-"`
+	TwoFactorEnabledAt *time.Time `json:"two_factor_enabled_at,omitempty"`
+	PINHash            *string    `json:"-"`
+	PINSetAt           *time.Time `json:"pin_set_at,omitempty"`
+	// DisabledAt blocks login and ends every session of the user.
+	DisabledAt *time.Time `json:"disabled_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+}
+
+func (u *User) TwoFactorEnabled() bool {
+	return u.TwoFactorEnabledAt != nil && u.TOTPSecret != nil
+}
+
+func (u *User) IsDisabled() bool {
+	return u.DisabledAt != nil
 }
 
 type RegisterRequest struct {
     Username string `json:"username" binding:"required,min=3,max=50"`
-    Password string `json:"password" binding:"required,min=6"`
+    Password string `json:"password" binding:"required"`
     Name     string `json:"name" binding:"required"`
-    Role     string `json:"role"`
+    Role     string `json:"role" binding:"omitempty,oneof=admin manager cashier staff"`
+    // InviteToken is required when registration is invite-only. The invite
+    // decides the role and store of the new account.
+    InviteToken string `json:"invite_token"`
+    // DeviceID binds the new session to a registered device, as on login.
+    // Required for cashiers.
+    DeviceID string `json:"device_id" binding:"omitempty,max=100"`
 }
 
 type LoginRequest struct {
 	Username string `json:"username" binding:"required"`
 	Password string `json:"password" binding:"required"`
+	// DeviceID binds the session to a registered device. Required for
+	// cashiers.
+	DeviceID string `json:"device_id" binding:"omitempty,max=100"`
 }
 
 type LoginResponse struct {
 	Token string `json:"token"`
 	User  User   `json:"user"`
 }
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password" binding:"required"`
+	NewPassword     string `json:"new_password" binding:"required"`
+}
+
+type ResetPasswordRequest struct {
+	NewPassword string `json:"new_password" binding:"required"`
+}
+
+// AccessTokenResponse returns a fresh access token for the current session,
+// e.g. after a password change cleared the forced-change flag.
+type AccessTokenResponse struct {
+	AccessToken string `json:"access_token"`
+	User        User   `json:"user"`
+}
+
+type SetPINRequest struct {
+	Password string `json:"password" binding:"required"`
+	PIN      string `json:"pin" binding:"required,numeric,min=4,max=8"`
+}
+
+type PINLoginRequest struct {
+	DeviceID string `json:"device_id" binding:"required,max=100"`
+	Username string `json:"username" binding:"required"`
+	PIN      string `json:"pin" binding:"required"`
+}
+
+// UserFilter narrows the admin user list. Search matches username or name.
+type UserFilter struct {
+	Search          string
+	Role            string
+	IncludeDisabled bool
+	Limit           int
+	Offset          int
+}
+
+// CreateUserRequest is the admin way to add a user with any role. The
+// password is temporary and must be changed at first login.
+type CreateUserRequest struct {
+	Username string `json:"username" binding:"required,min=3,max=50"`
+	Password string `json:"password" binding:"required"`
+	Name     string `json:"name" binding:"required,max=100"`
+	Role     string `json:"role" binding:"required,oneof=admin manager cashier staff"`
+	StoreID  string `json:"store_id" binding:"omitempty,max=36"`
+}
+
+// UpdateUserRequest changes only the fields that are present. An empty
+// store_id unassigns the user from their store.
+type UpdateUserRequest struct {
+	Name     *string `json:"name" binding:"omitempty,min=1,max=100"`
+	Role     *string `json:"role" binding:"omitempty,oneof=admin manager cashier staff"`
+	StoreID  *string `json:"store_id" binding:"omitempty,max=36"`
+	Disabled *bool   `json:"disabled"`
+}
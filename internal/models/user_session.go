@@ -5,20 +5,46 @@ import "time"
 type UserSession struct {
 	ID           string     `json:"id"`
 	UserID       string     `json:"user_id"`
+	FamilyID     string     `json:"family_id"`
 	RefreshToken string     `json:"-"`
 	DeviceID     *string    `json:"device_id"`
 	UserAgent    *string    `json:"user_agent"`
+	AuthMethod   string     `json:"auth_method"`
 	ExpiresAt    time.Time  `json:"expires_at"`
+	UsedAt       *time.Time `json:"used_at,omitempty"`
+	ReplacedBy   *string    `json:"replaced_by,omitempty"`
 	RevokedAt    *time.Time `json:"revoked_at"`
 	CreatedAt    time.Time  `json:"created_at"`
 }
 
+const (
+	AuthMethodPassword = "password"
+	// AuthMethodPIN sessions come from a cashier PIN on a store device. They
+	// expire after one shift and their tokens have a restricted scope.
+	AuthMethodPIN = "pin"
+)
+
+// SessionResponse is an active session as listed to its owner or an admin.
+// Current marks the session the request was made with.
+type SessionResponse struct {
+	UserSession
+	Current bool `json:"current"`
+}
+
 type TokenResponse struct {
 	AccessToken  string `json:"access_token"`
 	RefreshToken string `json:"refresh_token"`
 	User         User   `json:"user"`
 }
 
+// PowerSyncCredentials is what the PowerSync client connector's
+// fetchCredentials returns.
+type PowerSyncCredentials struct {
+	Endpoint  string    `json:"endpoint"`
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
 type RefreshTokenRequest struct {
 	RefreshToken string `json:"refresh_token" binding:"required"`
 }
@@ -0,0 +1,308 @@
+// Package money implements exact monetary amounts stored as an integer number
+// of minor units (cents, sen) together with an ISO 4217 currency code.
+//
+// All amounts use two decimal places and at most MaxMinor minor units to
+// match the numeric(10, 2) columns in the database. Arithmetic on integers is exact; the only place rounding
+// happens is when parsing input that carries more than two decimals, where
+// values are rounded half away from zero (12.345 -> 12.35, -12.345 -> -12.35).
+package money
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Scale is the number of decimal places stored for every amount.
+const Scale = 2
+
+const minorPerMajor = 100
+
+// MaxMinor is the largest magnitude a numeric(10, 2) column can hold,
+// 99999999.99. Parsing and arithmetic fail with ErrOutOfRange beyond it.
+const MaxMinor int64 = 99999999_99
+
+// DefaultCurrency is attached to amounts read from the database and to bare
+// numbers in requests. It is set once at startup from configuration.
+var DefaultCurrency = "IDR"
+
+var (
+	ErrInvalidAmount    = errors.New("invalid money amount")
+	ErrCurrencyMismatch = errors.New("currency mismatch")
+	ErrOutOfRange       = errors.New("money amount out of range")
+)
+
+type Money struct {
+	minor    int64
+	currency string
+}
+
+// New returns an amount of minor units in the given currency.
+func New(minor int64, currency string) Money {
+	return Money{minor: minor, currency: currency}
+}
+
+// Zero returns a zero amount in the default currency.
+func Zero() Money {
+	return Money{currency: DefaultCurrency}
+}
+
+// Parse reads a decimal string such as "12500", "12500.5" or "-3.14159".
+// Digits beyond Scale are rounded half away from zero. Amounts beyond
+// MaxMinor fail with ErrOutOfRange.
+func Parse(s string, currency string) (Money, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return Money{}, ErrInvalidAmount
+	}
+
+	negative := false
+	switch s[0] {
+	case '-':
+		negative = true
+		s = s[1:]
+	case '+':
+		s = s[1:]
+	}
+
+	whole, frac, _ := strings.Cut(s, ".")
+	if whole == "" && frac == "" {
+		return Money{}, ErrInvalidAmount
+	}
+	if whole == "" {
+		whole = "0"
+	}
+	if !isDigits(whole) || !isDigits(frac) {
+		return Money{}, ErrInvalidAmount
+	}
+
+	roundUp := false
+	if len(frac) > Scale {
+		roundUp = frac[Scale] >= '5'
+		frac = frac[:Scale]
+	}
+	frac += strings.Repeat("0", Scale-len(frac))
+
+	whole = strings.TrimLeft(whole, "0")
+	if len(whole) > 10 {
+		return Money{}, ErrOutOfRange
+	}
+	major, _ := strconv.ParseInt("0"+whole, 10, 64)
+	minorPart, _ := strconv.ParseInt(frac, 10, 64)
+
+	minor := major*minorPerMajor + minorPart
+	if roundUp {
+		minor++
+	}
+	if minor > MaxMinor {
+		return Money{}, ErrOutOfRange
+	}
+	if negative {
+		minor = -minor
+	}
+
+	return Money{minor: minor, currency: currency}, nil
+}
+
+// MustParse is like Parse but panics on invalid input. Only use it with
+// constant strings.
+func MustParse(s string, currency string) Money {
+	m, err := Parse(s, currency)
+	if err != nil {
+		panic(err)
+	}
+	return m
+}
+
+func isDigits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func (m Money) Minor() int64 {
+	return m.minor
+}
+
+func (m Money) Currency() string {
+	if m.currency == "" {
+		return DefaultCurrency
+	}
+	return m.currency
+}
+
+func (m Money) IsZero() bool {
+	return m.minor == 0
+}
+
+func (m Money) IsPositive() bool {
+	return m.minor > 0
+}
+
+func (m Money) IsNegative() bool {
+	return m.minor < 0
+}
+
+func (m Money) Neg() Money {
+	return Money{minor: -m.minor, currency: m.currency}
+}
+
+// Add returns m + o. Both amounts must be in the same currency.
+func (m Money) Add(o Money) (Money, error) {
+	if err := m.sameCurrency(o); err != nil {
+		return Money{}, err
+	}
+	return inRange(m.minor+o.minor, m.Currency())
+}
+
+// Sub returns m - o. Both amounts must be in the same currency.
+func (m Money) Sub(o Money) (Money, error) {
+	if err := m.sameCurrency(o); err != nil {
+		return Money{}, err
+	}
+	return inRange(m.minor-o.minor, m.Currency())
+}
+
+// Mul multiplies by an integer quantity. The result is exact or, if it does
+// not fit MaxMinor, ErrOutOfRange.
+func (m Money) Mul(qty int) (Money, error) {
+	q := int64(qty)
+	if q != 0 && abs(m.minor) > MaxMinor/abs(q) {
+		return Money{}, ErrOutOfRange
+	}
+	return Money{minor: m.minor * q, currency: m.currency}, nil
+}
+
+// inRange wraps a sum of two in-range amounts, which cannot overflow int64,
+// and rejects it if it no longer fits MaxMinor.
+func inRange(minor int64, currency string) (Money, error) {
+	if abs(minor) > MaxMinor {
+		return Money{}, ErrOutOfRange
+	}
+	return Money{minor: minor, currency: currency}, nil
+}
+
+func abs(n int64) int64 {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// Cmp returns -1, 0 or 1 when m is less than, equal to or greater than o.
+func (m Money) Cmp(o Money) (int, error) {
+	if err := m.sameCurrency(o); err != nil {
+		return 0, err
+	}
+	switch {
+	case m.minor < o.minor:
+		return -1, nil
+	case m.minor > o.minor:
+		return 1, nil
+	}
+	return 0, nil
+}
+
+func (m Money) sameCurrency(o Money) error {
+	if m.Currency() != o.Currency() {
+		return fmt.Errorf("%w: %s and %s", ErrCurrencyMismatch, m.Currency(), o.Currency())
+	}
+	return nil
+}
+
+// String formats the amount with exactly Scale decimals, e.g. "12500.00".
+func (m Money) String() string {
+	minor := m.minor
+	sign := ""
+	if minor < 0 {
+		sign = "-"
+		minor = -minor
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, minor/minorPerMajor, minor%minorPerMajor)
+}
+
+type jsonMoney struct {
+	Amount   json.RawMessage `json:"amount"`
+	Currency string          `json:"currency"`
+}
+
+// MarshalJSON encodes the amount as {"amount": "12500.00", "currency": "IDR"}.
+// The amount is a string so clients never round-trip it through a float.
+func (m Money) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Amount   string `json:"amount"`
+		Currency string `json:"currency"`
+	}{m.String(), m.Currency()})
+}
+
+// UnmarshalJSON accepts the object form produced by MarshalJSON as well as a
+// bare JSON number or string, which is read in the default currency. Numbers
+// are parsed from their text, never through float64.
+func (m *Money) UnmarshalJSON(data []byte) error {
+	data = []byte(strings.TrimSpace(string(data)))
+	if string(data) == "null" {
+		return nil
+	}
+
+	currency := DefaultCurrency
+	if len(data) > 0 && data[0] == '{' {
+		var obj jsonMoney
+		if err := json.Unmarshal(data, &obj); err != nil {
+			return err
+		}
+		if obj.Currency != "" {
+			currency = strings.ToUpper(obj.Currency)
+		}
+		data = obj.Amount
+	}
+
+	raw := strings.Trim(string(data), `"`)
+	parsed, err := Parse(raw, currency)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidAmount, raw)
+	}
+
+	*m = parsed
+	return nil
+}
+
+// Scan reads a numeric column. lib/pq returns numeric values as text, which is
+// parsed exactly.
+func (m *Money) Scan(src interface{}) error {
+	var (
+		parsed Money
+		err    error
+	)
+
+	switch v := src.(type) {
+	case []byte:
+		parsed, err = Parse(string(v), DefaultCurrency)
+	case string:
+		parsed, err = Parse(v, DefaultCurrency)
+	case int64:
+		parsed = Money{minor: v * minorPerMajor, currency: DefaultCurrency}
+	case float64:
+		parsed, err = Parse(strconv.FormatFloat(v, 'f', -1, 64), DefaultCurrency)
+	case nil:
+		parsed = Zero()
+	default:
+		return fmt.Errorf("cannot scan %T into money.Money", src)
+	}
+	if err != nil {
+		return err
+	}
+
+	*m = parsed
+	return nil
+}
+
+// Value writes the amount as a decimal string so Postgres stores it exactly.
+func (m Money) Value() (driver.Value, error) {
+	return m.String(), nil
+}
@@ -0,0 +1,167 @@
+package money
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		in    string
+		minor int64
+		err   error
+	}{
+		{"12500", 1250000, nil},
+		{"12500.5", 1250050, nil},
+		{"12500.05", 1250005, nil},
+		{".5", 50, nil},
+		{"+7", 700, nil},
+		{"-3.14159", -314, nil},
+		{"0.005", 1, nil},
+		{"0.004", 0, nil},
+		{"12.345", 1235, nil},
+		{"-12.345", -1235, nil},
+		{"0.995", 100, nil},
+		{"  42.10  ", 4210, nil},
+		{"00012", 1200, nil},
+		{"99999999.99", MaxMinor, nil},
+		{"-99999999.99", -MaxMinor, nil},
+		{"99999999.995", 0, ErrOutOfRange},
+		{"100000000", 0, ErrOutOfRange},
+		{"99999999999999999999999", 0, ErrOutOfRange},
+		{"", 0, ErrInvalidAmount},
+		{"-", 0, ErrInvalidAmount},
+		{".", 0, ErrInvalidAmount},
+		{"1.2.3", 0, ErrInvalidAmount},
+		{"1e5", 0, ErrInvalidAmount},
+		{"12,50", 0, ErrInvalidAmount},
+		{"--1", 0, ErrInvalidAmount},
+	}
+
+	for _, tt := range tests {
+		got, err := Parse(tt.in, "IDR")
+		if !errors.Is(err, tt.err) {
+			t.Errorf("Parse(%q) error = %v, want %v", tt.in, err, tt.err)
+			continue
+		}
+		if err == nil && got.Minor() != tt.minor {
+			t.Errorf("Parse(%q) = %d minor units, want %d", tt.in, got.Minor(), tt.minor)
+		}
+	}
+}
+
+func TestString(t *testing.T) {
+	tests := []struct {
+		minor int64
+		want  string
+	}{
+		{0, "0.00"},
+		{5, "0.05"},
+		{1250000, "12500.00"},
+		{-314, "-3.14"},
+		{MaxMinor, "99999999.99"},
+	}
+
+	for _, tt := range tests {
+		if got := New(tt.minor, "IDR").String(); got != tt.want {
+			t.Errorf("New(%d).String() = %q, want %q", tt.minor, got, tt.want)
+		}
+	}
+}
+
+func TestMul(t *testing.T) {
+	tests := []struct {
+		minor int64
+		qty   int
+		want  int64
+		err   error
+	}{
+		{1250, 3, 3750, nil},
+		{1250, 0, 0, nil},
+		{-1250, 2, -2500, nil},
+		{MaxMinor, 1, MaxMinor, nil},
+		{MaxMinor, 2, 0, ErrOutOfRange},
+		{1000000, 100000, 0, ErrOutOfRange},
+		{1, 1 << 62, 0, ErrOutOfRange},
+	}
+
+	for _, tt := range tests {
+		got, err := New(tt.minor, "IDR").Mul(tt.qty)
+		if !errors.Is(err, tt.err) {
+			t.Errorf("%d.Mul(%d) error = %v, want %v", tt.minor, tt.qty, err, tt.err)
+			continue
+		}
+		if err == nil && got.Minor() != tt.want {
+			t.Errorf("%d.Mul(%d) = %d, want %d", tt.minor, tt.qty, got.Minor(), tt.want)
+		}
+	}
+}
+
+func TestAddSub(t *testing.T) {
+	a := New(MaxMinor, "IDR")
+
+	if _, err := a.Add(New(1, "IDR")); !errors.Is(err, ErrOutOfRange) {
+		t.Errorf("Add past MaxMinor error = %v, want %v", err, ErrOutOfRange)
+	}
+	if _, err := a.Neg().Sub(New(1, "IDR")); !errors.Is(err, ErrOutOfRange) {
+		t.Errorf("Sub past -MaxMinor error = %v, want %v", err, ErrOutOfRange)
+	}
+	if _, err := a.Add(New(1, "USD")); !errors.Is(err, ErrCurrencyMismatch) {
+		t.Errorf("Add across currencies error = %v, want %v", err, ErrCurrencyMismatch)
+	}
+
+	got, err := New(1000, "IDR").Sub(New(1250, "IDR"))
+	if err != nil || got.Minor() != -250 {
+		t.Errorf("Sub = %v, %v, want -2.50", got, err)
+	}
+}
+
+func TestUnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		in       string
+		minor    int64
+		currency string
+		wantErr  bool
+	}{
+		{`12500`, 1250000, DefaultCurrency, false},
+		{`"12500.50"`, 1250050, DefaultCurrency, false},
+		{`0.125`, 13, DefaultCurrency, false},
+		{`{"amount": "1.99", "currency": "usd"}`, 199, "USD", false},
+		{`{"amount": 2}`, 200, DefaultCurrency, false},
+		{`"abc"`, 0, "", true},
+		{`1000000000`, 0, "", true},
+	}
+
+	for _, tt := range tests {
+		var m Money
+		err := json.Unmarshal([]byte(tt.in), &m)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("Unmarshal(%s) error = %v, want error %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if err == nil && (m.Minor() != tt.minor || m.Currency() != tt.currency) {
+			t.Errorf("Unmarshal(%s) = %d %s, want %d %s", tt.in, m.Minor(), m.Currency(), tt.minor, tt.currency)
+		}
+	}
+}
+
+func TestMarshalJSONRoundTrip(t *testing.T) {
+	in := New(-1234567, "IDR")
+
+	data, err := json.Marshal(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != `{"amount":"-12345.67","currency":"IDR"}` {
+		t.Errorf("Marshal = %s", data)
+	}
+
+	var out Money
+	if err := json.Unmarshal(data, &out); err != nil {
+		t.Fatal(err)
+	}
+	if out != in {
+		t.Errorf("round trip = %v, want %v", out, in)
+	}
+}
@@ -0,0 +1,106 @@
+// Package password holds the password policy: minimum length, a check
+// against a bundled list of common passwords, and no reuse of recent
+// passwords. It also checks cashier login PINs.
+//
+// common-passwords.txt holds every entry of at least 8 characters from the
+// password, English word and first name frequency lists of zxcvbn (MIT
+// licensed), most common passwords first. Shorter entries are left out since
+// they fail the length check anyway.
+package password
+
+import (
+	_ "embed"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// maxLength is the longest password bcrypt hashes without truncating.
+const maxLength = 72
+
+//go:embed common-passwords.txt
+var commonList string
+
+var common = func() map[string]bool {
+	words := make(map[string]bool)
+	for _, word := range strings.Fields(commonList) {
+		words[strings.ToLower(word)] = true
+	}
+	return words
+}()
+
+var (
+	ErrTooLong = fmt.Errorf("password must be at most %d bytes", maxLength)
+	ErrCommon  = errors.New("password is too common")
+	ErrReused  = errors.New("password was used recently")
+	ErrWeakPIN = errors.New("PIN is too easy to guess")
+)
+
+type Policy struct {
+	MinLength int
+	// HistorySize is how many previous passwords, including the current one,
+	// may not be reused.
+	HistorySize int
+}
+
+// Validate checks a new password against the length and common password
+// rules. username is rejected as a password too.
+func (p *Policy) Validate(password, username string) error {
+	if len([]rune(password)) < p.MinLength {
+		return fmt.Errorf("password must be at least %d characters", p.MinLength)
+	}
+
+	if len(password) > maxLength {
+		return ErrTooLong
+	}
+
+	lower := strings.ToLower(password)
+	if common[lower] || lower == strings.ToLower(username) {
+		return ErrCommon
+	}
+
+	return nil
+}
+
+// CheckReuse returns ErrReused if password matches one of the given bcrypt
+// hashes. Only the first HistorySize hashes are checked, so pass them newest
+// first.
+func (p *Policy) CheckReuse(password string, hashes []string) error {
+	for i, hash := range hashes {
+		if i >= p.HistorySize {
+			break
+		}
+		if bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil {
+			return ErrReused
+		}
+	}
+	return nil
+}
+
+func Hash(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// ValidatePIN rejects PINs made of one repeated digit or a run of consecutive
+// digits such as 1234 or 9876. Length and digits-only are checked by request
+// binding.
+func ValidatePIN(pin string) error {
+	same, up, down := true, true, true
+	for i := 1; i < len(pin); i++ {
+		diff := int(pin[i]) - int(pin[i-1])
+		same = same && diff == 0
+		up = up && diff == 1
+		down = down && diff == -1
+	}
+
+	if same || up || down {
+		return ErrWeakPIN
+	}
+	return nil
+}
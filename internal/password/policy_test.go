@@ -0,0 +1,116 @@
+package password
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestCommonList(t *testing.T) {
+	words := strings.Fields(commonList)
+	if len(words) < 10000 {
+		t.Errorf("common list has %d entries, want at least 10000", len(words))
+	}
+
+	for _, word := range words {
+		if len([]rune(word)) < 8 {
+			t.Errorf("common list entry %q is shorter than 8 characters", word)
+		}
+		if word != strings.ToLower(word) {
+			t.Errorf("common list entry %q is not lowercase", word)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	policy := &Policy{MinLength: 8}
+
+	tests := []struct {
+		password string
+		username string
+		wantErr  bool
+		err      error
+	}{
+		{"correct horse battery", "kasir1", false, nil},
+		{"Tr0ub4dor&3x", "kasir1", false, nil},
+		{"short", "kasir1", true, nil},
+		{"1234567", "kasir1", true, nil},
+		{"password", "kasir1", true, ErrCommon},
+		{"PassWord", "kasir1", true, ErrCommon},
+		{"12345678", "kasir1", true, ErrCommon},
+		{"sunshine", "kasir1", true, ErrCommon},
+		{"football", "kasir1", true, ErrCommon},
+		{"iloveyou", "kasir1", true, ErrCommon},
+		{"qwertyuiop", "kasir1", true, ErrCommon},
+		{"Kasir12345", "kasir12345", true, ErrCommon},
+		{strings.Repeat("x", 72), "kasir1", false, nil},
+		{strings.Repeat("x", 73), "kasir1", true, ErrTooLong},
+		{"ééééééé", "kasir1", true, nil},
+	}
+
+	for _, tt := range tests {
+		err := policy.Validate(tt.password, tt.username)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("Validate(%q) error = %v, want error %v", tt.password, err, tt.wantErr)
+			continue
+		}
+		if tt.err != nil && !errors.Is(err, tt.err) {
+			t.Errorf("Validate(%q) error = %v, want %v", tt.password, err, tt.err)
+		}
+	}
+}
+
+func TestCheckReuse(t *testing.T) {
+	hash := func(password string) string {
+		h, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(h)
+	}
+
+	// Newest first, as the repository returns them.
+	history := []string{hash("third-password"), hash("second-password"), hash("first-password")}
+	policy := &Policy{HistorySize: 2}
+
+	tests := []struct {
+		password string
+		err      error
+	}{
+		{"third-password", ErrReused},
+		{"second-password", ErrReused},
+		{"first-password", nil},
+		{"brand-new-password", nil},
+	}
+
+	for _, tt := range tests {
+		if err := policy.CheckReuse(tt.password, history); !errors.Is(err, tt.err) {
+			t.Errorf("CheckReuse(%q) = %v, want %v", tt.password, err, tt.err)
+		}
+	}
+}
+
+func TestValidatePIN(t *testing.T) {
+	tests := []struct {
+		pin string
+		err error
+	}{
+		{"4821", nil},
+		{"190573", nil},
+		{"1123", nil},
+		{"1111", ErrWeakPIN},
+		{"00000000", ErrWeakPIN},
+		{"1234", ErrWeakPIN},
+		{"3456789", ErrWeakPIN},
+		{"9876", ErrWeakPIN},
+		{"543210", ErrWeakPIN},
+	}
+
+	for _, tt := range tests {
+		if err := ValidatePIN(tt.pin); !errors.Is(err, tt.err) {
+			t.Errorf("ValidatePIN(%q) = %v, want %v", tt.pin, err, tt.err)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package rbac
+
+type Role string
+
+const (
+	RoleAdmin   Role = "admin"
+	RoleManager Role = "manager"
+	RoleCashier Role = "cashier"
+	RoleStaff   Role = "staff"
+)
+
+type Permission string
+
+const (
+	ProductsRead  Permission = "products:read"
+	ProductsWrite Permission = "products:write"
+
+	StockEventsRead   Permission = "stock_events:read"
+	StockEventsCreate Permission = "stock_events:create"
+	// StockEventsAdjust covers manual corrections (adjustment, opening_stock)
+	// that change stock without a physical movement behind them.
+	StockEventsAdjust Permission = "stock_events:adjust"
+	// StockTransfersCreate covers sending, receiving and cancelling stock
+	// transfers between locations.
+	StockTransfersCreate Permission = "stock_transfers:create"
+	LocationsManage      Permission = "locations:manage"
+	// StocktakesCount covers submitting counts to an open stocktake;
+	// StocktakesManage covers opening, approving and cancelling one.
+	StocktakesCount  Permission = "stocktakes:count"
+	StocktakesManage Permission = "stocktakes:manage"
+
+	TransactionsRead     Permission = "transactions:read"
+	TransactionsCreate   Permission = "transactions:create"
+	TransactionsComplete Permission = "transactions:complete"
+	TransactionsCancel   Permission = "transactions:cancel"
+	TransactionsRefund   Permission = "transactions:refund"
+
+	DevicesManage Permission = "devices:manage"
+)
+
+// matrix maps each role to the actions it may perform. Admins are granted
+// everything in Can and are not listed here.
+var matrix = map[Role][]Permission{
+	RoleManager: {
+		ProductsRead, ProductsWrite,
+		StockEventsRead, StockEventsCreate, StockEventsAdjust,
+		StockTransfersCreate, LocationsManage,
+		StocktakesCount, StocktakesManage,
+		TransactionsRead, TransactionsCreate, TransactionsComplete, TransactionsCancel, TransactionsRefund,
+		DevicesManage,
+	},
+	RoleCashier: {
+		ProductsRead,
+		StockEventsRead,
+		StocktakesCount,
+		TransactionsRead, TransactionsCreate, TransactionsComplete,
+	},
+	RoleStaff: {
+		ProductsRead,
+		StockEventsRead, StockEventsCreate,
+		StockTransfersCreate,
+		StocktakesCount,
+		TransactionsRead,
+	},
+}
+
+// ScopePIN marks tokens from a cashier PIN login. They are limited to
+// ringing up sales and looking up stock, whatever the user's role allows.
+const ScopePIN = "pin"
+
+var scopeLimits = map[string][]Permission{
+	ScopePIN: {
+		ProductsRead,
+		StockEventsRead, StockEventsCreate,
+		StocktakesCount,
+		TransactionsRead, TransactionsCreate, TransactionsComplete,
+	},
+}
+
+var grants = func() map[Role]map[Permission]bool {
+	g := make(map[Role]map[Permission]bool, len(matrix))
+	for role, perms := range matrix {
+		g[role] = make(map[Permission]bool, len(perms))
+		for _, perm := range perms {
+			g[role][perm] = true
+		}
+	}
+	return g
+}()
+
+var scopeGrants = func() map[string]map[Permission]bool {
+	g := make(map[string]map[Permission]bool, len(scopeLimits))
+	for scope, perms := range scopeLimits {
+		g[scope] = make(map[Permission]bool, len(perms))
+		for _, perm := range perms {
+			g[scope][perm] = true
+		}
+	}
+	return g
+}()
+
+func IsValidRole(role string) bool {
+	switch Role(role) {
+	case RoleAdmin, RoleManager, RoleCashier, RoleStaff:
+		return true
+	}
+	return false
+}
+
+// RequiresDevice reports whether users with role may only log in from a
+// registered device. Cashiers work at POS tills, so their sessions must be
+// bound to one.
+func RequiresDevice(role string) bool {
+	return Role(role) == RoleCashier
+}
+
+// Can reports whether role is allowed to perform perm. Unknown roles get
+// nothing.
+func Can(role string, perm Permission) bool {
+	if Role(role) == RoleAdmin {
+		return true
+	}
+	return grants[Role(role)][perm]
+}
+
+// CanInScope is Can for a token with a restricted scope. An empty scope is a
+// full login; any other scope further limits what the role may do.
+func CanInScope(role, scope string, perm Permission) bool {
+	if !Can(role, perm) {
+		return false
+	}
+	if scope == "" {
+		return true
+	}
+	return scopeGrants[scope][perm]
+}
@@ -0,0 +1,103 @@
+// Package reconcile checks the cached stock levels against the stock_events
+// ledger, which is the source of truth, and can rebuild the caches from it.
+// It runs from the `reconcile` subcommand of the API binary and on a schedule
+// inside the API.
+package reconcile
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"pwa-backend/internal/ids"
+	"pwa-backend/internal/models"
+	"pwa-backend/internal/repositories"
+)
+
+// Triggers recorded with each correction.
+const (
+	TriggerCLI      = "cli"
+	TriggerSchedule = "schedule"
+)
+
+type Reconciler struct {
+	repo *repositories.ReconciliationRepository
+}
+
+func New(repo *repositories.ReconciliationRepository) *Reconciler {
+	return &Reconciler{repo: repo}
+}
+
+// Run reports the drift between the caches and the ledger. With rebuild set
+// the drifted caches are also corrected, and each correction is recorded.
+func (r *Reconciler) Run(trigger string, rebuild bool) (*models.ReconciliationReport, error) {
+	report := &models.ReconciliationReport{
+		RunID:     ids.New(),
+		Trigger:   trigger,
+		CheckedAt: time.Now(),
+	}
+
+	var err error
+	if rebuild {
+		report.Drifts, err = r.repo.Rebuild(report.RunID, trigger)
+		report.Rebuilt = true
+	} else {
+		report.Drifts, err = r.repo.FindDrift()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return report, nil
+}
+
+// Start runs the reconciliation every interval in the background and logs
+// any drift it finds. An interval of zero disables it.
+func (r *Reconciler) Start(interval time.Duration, rebuild bool) {
+	if interval <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			report, err := r.Run(TriggerSchedule, rebuild)
+			if err != nil {
+				log.Printf("Failed to reconcile stock: %v", err)
+				continue
+			}
+			Log(report)
+		}
+	}()
+}
+
+// Log writes one line per drift and a summary.
+func Log(report *models.ReconciliationReport) {
+	for _, drift := range report.Drifts {
+		log.Printf("Stock drift: %s", Describe(drift))
+	}
+
+	switch {
+	case len(report.Drifts) == 0:
+		log.Printf("Stock reconciliation %s: no drift", report.RunID)
+	case report.Rebuilt:
+		log.Printf("Stock reconciliation %s: corrected %d cached stock levels", report.RunID, len(report.Drifts))
+	default:
+		log.Printf("Stock reconciliation %s: %d cached stock levels drifted, not corrected", report.RunID, len(report.Drifts))
+	}
+}
+
+// Describe formats a drift for logs and the command line. The level follows
+// from whether a store and location are named.
+func Describe(d models.StockDrift) string {
+	where := "product " + d.ProductID
+	if d.StoreID != nil {
+		where += " store " + *d.StoreID
+	}
+	if d.LocationID != nil {
+		where += " location " + *d.LocationID
+	}
+	return fmt.Sprintf("%s: cached %d, ledger %d (%+d)", where, d.Cached, d.Ledger, d.Diff())
+}
@@ -0,0 +1,94 @@
+package repositories
+
+import (
+	"database/sql"
+	"time"
+
+	"pwa-backend/internal/models"
+)
+
+type DeviceRepository struct {
+	db *sql.DB
+}
+
+func NewDeviceRepository(db *sql.DB) *DeviceRepository {
+	return &DeviceRepository{db: db}
+}
+
+const deviceColumns = `id, name, store_id, registered_by, last_seen_at, deactivated_at, created_at, updated_at`
+
+func scanDevice(row rowScanner, d *models.Device) error {
+	return row.Scan(&d.ID, &d.Name, &d.StoreID, &d.RegisteredBy, &d.LastSeenAt, &d.DeactivatedAt, &d.CreatedAt, &d.UpdatedAt)
+}
+
+func (r *DeviceRepository) BeginTx() (*sql.Tx, error) {
+	return r.db.Begin()
+}
+
+func (r *DeviceRepository) Create(d *models.Device) error {
+	query := `INSERT INTO devices (id, name, store_id, registered_by, created_at, updated_at)
+	          VALUES ($1, $2, $3, $4, $5, $6)`
+
+	_, err := r.db.Exec(query, d.ID, d.Name, d.StoreID, d.RegisteredBy, d.CreatedAt, d.UpdatedAt)
+	return err
+}
+
+// GetAll returns the devices of storeID, or of every store when storeID is nil.
+func (r *DeviceRepository) GetAll(storeID *string, includeInactive bool) ([]models.Device, error) {
+	query := `SELECT ` + deviceColumns + ` FROM devices WHERE ($1::varchar IS NULL OR store_id = $1)`
+	if !includeInactive {
+		query += ` AND deactivated_at IS NULL`
+	}
+	query += ` ORDER BY name`
+
+	rows, err := r.db.Query(query, storeID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var devices []models.Device
+	for rows.Next() {
+		var d models.Device
+		if err := scanDevice(rows, &d); err != nil {
+			return nil, err
+		}
+		devices = append(devices, d)
+	}
+
+	return devices, rows.Err()
+}
+
+func (r *DeviceRepository) GetByID(id string) (*models.Device, error) {
+	var d models.Device
+	query := `SELECT ` + deviceColumns + ` FROM devices WHERE id = $1`
+
+	err := scanDevice(r.db.QueryRow(query, id), &d)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &d, nil
+}
+
+func (r *DeviceRepository) Update(tx *sql.Tx, d *models.Device) error {
+	query := `UPDATE devices SET name = $1, store_id = $2, updated_at = $3 WHERE id = $4`
+	_, err := tx.Exec(query, d.Name, d.StoreID, d.UpdatedAt, d.ID)
+	return err
+}
+
+func (r *DeviceRepository) Deactivate(tx *sql.Tx, id string) error {
+	query := `UPDATE devices SET deactivated_at = NOW(), updated_at = NOW() WHERE id = $1 AND deactivated_at IS NULL`
+	_, err := tx.Exec(query, id)
+	return err
+}
+
+// Touch records that the device was just used to log in or refresh a token.
+func (r *DeviceRepository) Touch(id string, at time.Time) error {
+	_, err := r.db.Exec(`UPDATE devices SET last_seen_at = $1 WHERE id = $2`, at, id)
+	return err
+}
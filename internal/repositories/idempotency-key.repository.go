@@ -0,0 +1,100 @@
+package repositories
+
+import (
+	"database/sql"
+	"log"
+	"time"
+
+	"pwa-backend/internal/models"
+)
+
+// IdempotencyKeyRepository keeps each key for ttl. An expired key can be
+// reserved again and is eventually deleted by the pruner.
+type IdempotencyKeyRepository struct {
+	db  *sql.DB
+	ttl time.Duration
+}
+
+func NewIdempotencyKeyRepository(db *sql.DB, ttl time.Duration) *IdempotencyKeyRepository {
+	return &IdempotencyKeyRepository{db: db, ttl: ttl}
+}
+
+// Reserve claims a key inside tx. It returns false when the key was already
+// used and has not expired; if another request holding the key is still
+// running, the insert waits for it to commit or roll back first.
+func (r *IdempotencyKeyRepository) Reserve(tx *sql.Tx, key *models.IdempotencyKey) (bool, error) {
+	query := `INSERT INTO idempotency_keys (user_id, key, endpoint, request_hash, created_at, expires_at)
+	          VALUES ($1, $2, $3, $4, NOW(), NOW() + make_interval(secs => $5))
+	          ON CONFLICT (user_id, key) DO UPDATE SET
+	              endpoint = EXCLUDED.endpoint, request_hash = EXCLUDED.request_hash,
+	              response_status = NULL, response_body = NULL,
+	              created_at = EXCLUDED.created_at, expires_at = EXCLUDED.expires_at
+	          WHERE idempotency_keys.expires_at <= NOW()`
+
+	result, err := tx.Exec(query, key.UserID, key.Key, key.Endpoint, key.RequestHash, r.ttl.Seconds())
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return affected == 1, nil
+}
+
+// SaveResponse stores the response for a reserved key. It is written in the
+// same tx as the work itself, so a failed request leaves the key free to retry.
+func (r *IdempotencyKeyRepository) SaveResponse(tx *sql.Tx, userID, key string, status int, body []byte) error {
+	query := `UPDATE idempotency_keys SET response_status = $1, response_body = $2 WHERE user_id = $3 AND key = $4`
+	_, err := tx.Exec(query, status, body, userID, key)
+	return err
+}
+
+func (r *IdempotencyKeyRepository) Get(userID, key string) (*models.IdempotencyKey, error) {
+	var k models.IdempotencyKey
+	query := `SELECT user_id, key, endpoint, request_hash, response_status, response_body, created_at, expires_at
+	          FROM idempotency_keys WHERE user_id = $1 AND key = $2`
+
+	err := r.db.QueryRow(query, userID, key).Scan(
+		&k.UserID, &k.Key, &k.Endpoint, &k.RequestHash, &k.ResponseStatus, &k.ResponseBody, &k.CreatedAt, &k.ExpiresAt,
+	)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &k, nil
+}
+
+// DeleteExpired removes the keys whose replay window has passed.
+func (r *IdempotencyKeyRepository) DeleteExpired() (int64, error) {
+	result, err := r.db.Exec(`DELETE FROM idempotency_keys WHERE expires_at <= NOW()`)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// StartPruner deletes expired keys every interval in the background. An
+// interval of zero disables it.
+func (r *IdempotencyKeyRepository) StartPruner(interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			if _, err := r.DeleteExpired(); err != nil {
+				log.Printf("Failed to prune idempotency keys: %v", err)
+			}
+		}
+	}()
+}
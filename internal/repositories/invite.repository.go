@@ -0,0 +1,110 @@
+package repositories
+
+import (
+	"database/sql"
+	"time"
+
+	"pwa-backend/internal/models"
+)
+
+type InviteRepository struct {
+	db *sql.DB
+}
+
+func NewInviteRepository(db *sql.DB) *InviteRepository {
+	return &InviteRepository{db: db}
+}
+
+const inviteColumns = `id, role, store_id, created_by, expires_at, used_at, used_by, revoked_at, created_at`
+
+func scanInvite(row rowScanner, i *models.Invite) error {
+	return row.Scan(&i.ID, &i.Role, &i.StoreID, &i.CreatedBy, &i.ExpiresAt, &i.UsedAt, &i.UsedBy, &i.RevokedAt, &i.CreatedAt)
+}
+
+func (r *InviteRepository) Create(i *models.Invite, tokenHash string) error {
+	query := `INSERT INTO invites (id, token_hash, role, store_id, created_by, expires_at, created_at)
+	          VALUES ($1, $2, $3, $4, $5, $6, $7)`
+
+	_, err := r.db.Exec(query, i.ID, tokenHash, i.Role, i.StoreID, i.CreatedBy, i.ExpiresAt, i.CreatedAt)
+	return err
+}
+
+// GetAll lists invites newest first. Used, revoked and expired invites are
+// left out unless includeInactive is set.
+func (r *InviteRepository) GetAll(includeInactive bool) ([]models.Invite, error) {
+	query := `SELECT ` + inviteColumns + ` FROM invites`
+	args := []interface{}{}
+	if !includeInactive {
+		query += ` WHERE used_at IS NULL AND revoked_at IS NULL AND expires_at > $1`
+		args = append(args, time.Now())
+	}
+	query += ` ORDER BY created_at DESC`
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	invites := []models.Invite{}
+	for rows.Next() {
+		var i models.Invite
+		if err := scanInvite(rows, &i); err != nil {
+			return nil, err
+		}
+		invites = append(invites, i)
+	}
+
+	return invites, rows.Err()
+}
+
+func (r *InviteRepository) GetByID(id string) (*models.Invite, error) {
+	return r.getOne(`SELECT `+inviteColumns+` FROM invites WHERE id = $1`, id)
+}
+
+func (r *InviteRepository) GetByTokenHash(tokenHash string) (*models.Invite, error) {
+	return r.getOne(`SELECT `+inviteColumns+` FROM invites WHERE token_hash = $1`, tokenHash)
+}
+
+func (r *InviteRepository) getOne(query string, arg string) (*models.Invite, error) {
+	var i models.Invite
+	err := scanInvite(r.db.QueryRow(query, arg), &i)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &i, nil
+}
+
+// Consume marks an invite as used by userID inside tx. It returns false when
+// the invite was used, revoked or expired in the meantime.
+func (r *InviteRepository) Consume(tx *sql.Tx, id, userID string, now time.Time) (bool, error) {
+	query := `UPDATE invites SET used_at = $1, used_by = $2
+	          WHERE id = $3 AND used_at IS NULL AND revoked_at IS NULL AND expires_at > $1`
+
+	result, err := tx.Exec(query, now, userID, id)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := result.RowsAffected()
+	return n == 1, err
+}
+
+// Revoke cancels an unused invite. It returns false when the invite was
+// already used or revoked.
+func (r *InviteRepository) Revoke(id string) (bool, error) {
+	query := `UPDATE invites SET revoked_at = $1 WHERE id = $2 AND used_at IS NULL AND revoked_at IS NULL`
+
+	result, err := r.db.Exec(query, time.Now(), id)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := result.RowsAffected()
+	return n == 1, err
+}
@@ -0,0 +1,136 @@
+package repositories
+
+import (
+	"database/sql"
+	"time"
+
+	"pwa-backend/internal/models"
+)
+
+type LocationRepository struct {
+	db *sql.DB
+}
+
+func NewLocationRepository(db *sql.DB) *LocationRepository {
+	return &LocationRepository{db: db}
+}
+
+const locationColumns = `id, store_id, name, is_default, created_at, updated_at`
+
+func scanLocation(row rowScanner, l *models.Location) error {
+	return row.Scan(&l.ID, &l.StoreID, &l.Name, &l.IsDefault, &l.CreatedAt, &l.UpdatedAt)
+}
+
+func (r *LocationRepository) Create(l *models.Location) error {
+	return createLocation(r.db, l)
+}
+
+func createLocation(exec execer, l *models.Location) error {
+	query := `INSERT INTO locations (id, store_id, name, is_default, created_at, updated_at) VALUES ($1, $2, $3, $4, $5, $6)`
+	_, err := exec.Exec(query, l.ID, l.StoreID, l.Name, l.IsDefault, l.CreatedAt, l.UpdatedAt)
+	return err
+}
+
+// GetAll lists locations, default first. A nil storeID includes every store.
+func (r *LocationRepository) GetAll(storeID *string) ([]models.Location, error) {
+	query := `SELECT ` + locationColumns + ` FROM locations
+	          WHERE $1::varchar IS NULL OR store_id = $1
+	          ORDER BY store_id, is_default DESC, name`
+
+	rows, err := r.db.Query(query, storeID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	locations := []models.Location{}
+	for rows.Next() {
+		var l models.Location
+		if err := scanLocation(rows, &l); err != nil {
+			return nil, err
+		}
+		locations = append(locations, l)
+	}
+
+	return locations, rows.Err()
+}
+
+func (r *LocationRepository) GetByID(id string) (*models.Location, error) {
+	return r.get(`SELECT `+locationColumns+` FROM locations WHERE id = $1`, id)
+}
+
+// GetDefault returns the default location of a store.
+func (r *LocationRepository) GetDefault(storeID string) (*models.Location, error) {
+	return r.get(`SELECT `+locationColumns+` FROM locations WHERE store_id = $1 AND is_default`, storeID)
+}
+
+func (r *LocationRepository) get(query string, args ...interface{}) (*models.Location, error) {
+	var l models.Location
+	err := scanLocation(r.db.QueryRow(query, args...), &l)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &l, nil
+}
+
+func (r *LocationRepository) Update(l *models.Location) error {
+	query := `UPDATE locations SET name = $1, updated_at = $2 WHERE id = $3`
+	_, err := r.db.Exec(query, l.Name, l.UpdatedAt, l.ID)
+	return err
+}
+
+// SetDefault makes l the default location of its store in place of the
+// current one.
+func (r *LocationRepository) SetDefault(l *models.Location) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	query := `UPDATE locations SET is_default = false, updated_at = $1 WHERE store_id = $2 AND is_default`
+	if _, err := tx.Exec(query, now, l.StoreID); err != nil {
+		return err
+	}
+
+	query = `UPDATE locations SET is_default = true, updated_at = $1 WHERE id = $2`
+	if _, err := tx.Exec(query, now, l.ID); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	l.IsDefault = true
+	l.UpdatedAt = now
+	return nil
+}
+
+// GetStocks lists the stock of every product ever held at a location.
+func (r *LocationRepository) GetStocks(locationID string) ([]models.LocationStock, error) {
+	query := `SELECT location_id, product_id, stock, updated_at FROM location_stocks WHERE location_id = $1 ORDER BY product_id`
+
+	rows, err := r.db.Query(query, locationID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	stocks := []models.LocationStock{}
+	for rows.Next() {
+		var s models.LocationStock
+		if err := rows.Scan(&s.LocationID, &s.ProductID, &s.Stock, &s.UpdatedAt); err != nil {
+			return nil, err
+		}
+		stocks = append(stocks, s)
+	}
+
+	return stocks, rows.Err()
+}
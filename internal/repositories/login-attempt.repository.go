@@ -0,0 +1,165 @@
+package repositories
+
+import (
+	"database/sql"
+	"log"
+	"time"
+
+	"pwa-backend/internal/loginlimit"
+)
+
+// LoginAttemptRepository is the Postgres loginlimit.Store, used when several
+// API instances must share failed login counters.
+type LoginAttemptRepository struct {
+	db *sql.DB
+}
+
+func NewLoginAttemptRepository(db *sql.DB) *LoginAttemptRepository {
+	return &LoginAttemptRepository{db: db}
+}
+
+var _ loginlimit.Store = (*LoginAttemptRepository)(nil)
+
+const loginAttemptColumns = `kind, value, failures, last_failure_at, COALESCE(blocked_until, 'epoch'::timestamp), locked`
+
+func scanLoginAttempt(row rowScanner, e *loginlimit.Entry) error {
+	return row.Scan(&e.Kind, &e.Value, &e.Failures, &e.LastFailureAt, &e.BlockedUntil, &e.Locked)
+}
+
+func (r *LoginAttemptRepository) Get(key loginlimit.Key) (*loginlimit.Entry, error) {
+	var e loginlimit.Entry
+	query := `SELECT ` + loginAttemptColumns + ` FROM login_attempts WHERE kind = $1 AND value = $2`
+
+	err := scanLoginAttempt(r.db.QueryRow(query, key.Kind, key.Value), &e)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &e, nil
+}
+
+// Reserve counts the attempt in a single upsert. The row lock taken by the
+// upsert makes the blocked check and the increment atomic, so concurrent
+// attempts on several instances cannot all get past a block.
+func (r *LoginAttemptRepository) Reserve(key loginlimit.Key, now, since time.Time, maxFailures int, lockUntil time.Time) (int, time.Time, error) {
+	query := `
+		WITH attempt AS (
+			INSERT INTO login_attempts (kind, value, failures, last_failure_at, blocked_until, locked)
+			VALUES ($1, $2, 1, $3, CASE WHEN $5 > 0 AND 1 >= $5 THEN $6::timestamp END, $5 > 0 AND 1 >= $5)
+			ON CONFLICT (kind, value) DO UPDATE SET
+				failures = CASE WHEN login_attempts.last_failure_at < $4 THEN 1 ELSE login_attempts.failures + 1 END,
+				last_failure_at = EXCLUDED.last_failure_at,
+				blocked_until = CASE
+					WHEN $5 > 0 AND (CASE WHEN login_attempts.last_failure_at < $4 THEN 1 ELSE login_attempts.failures + 1 END) >= $5 THEN $6::timestamp
+					ELSE login_attempts.blocked_until
+				END,
+				locked = CASE
+					WHEN $5 > 0 AND (CASE WHEN login_attempts.last_failure_at < $4 THEN 1 ELSE login_attempts.failures + 1 END) >= $5 THEN TRUE
+					ELSE login_attempts.locked
+				END
+			WHERE login_attempts.blocked_until IS NULL OR login_attempts.blocked_until <= $3
+			RETURNING failures
+		)
+		SELECT failures, NULL::timestamp FROM attempt
+		UNION ALL
+		SELECT 0, blocked_until FROM login_attempts
+		WHERE kind = $1 AND value = $2 AND NOT EXISTS (SELECT 1 FROM attempt)
+	`
+
+	var (
+		failures     int
+		blockedUntil sql.NullTime
+	)
+	err := r.db.QueryRow(query, key.Kind, key.Value, now, since, maxFailures, lockUntil).Scan(&failures, &blockedUntil)
+	return failures, blockedUntil.Time, err
+}
+
+// Release takes back one reserved attempt, lifting the lock it set unless a
+// later attempt has replaced it.
+func (r *LoginAttemptRepository) Release(key loginlimit.Key, lockedUntil time.Time) error {
+	query := `
+		UPDATE login_attempts SET
+			failures = GREATEST(failures - 1, 0),
+			blocked_until = CASE WHEN locked AND blocked_until = $3 THEN NULL ELSE blocked_until END,
+			locked = locked AND blocked_until IS DISTINCT FROM $3
+		WHERE kind = $1 AND value = $2
+	`
+
+	var lock sql.NullTime
+	if !lockedUntil.IsZero() {
+		lock = sql.NullTime{Time: lockedUntil, Valid: true}
+	}
+
+	_, err := r.db.Exec(query, key.Kind, key.Value, lock)
+	return err
+}
+
+func (r *LoginAttemptRepository) Block(key loginlimit.Key, until time.Time, locked bool) error {
+	query := `UPDATE login_attempts SET blocked_until = $1, locked = $2
+	          WHERE kind = $3 AND value = $4 AND (blocked_until IS NULL OR blocked_until <= $1)`
+	_, err := r.db.Exec(query, until, locked, key.Kind, key.Value)
+	return err
+}
+
+func (r *LoginAttemptRepository) Reset(key loginlimit.Key) error {
+	_, err := r.db.Exec(`DELETE FROM login_attempts WHERE kind = $1 AND value = $2`, key.Kind, key.Value)
+	return err
+}
+
+func (r *LoginAttemptRepository) Locked(now time.Time) ([]loginlimit.Entry, error) {
+	query := `SELECT ` + loginAttemptColumns + ` FROM login_attempts
+	          WHERE locked AND blocked_until > $1 ORDER BY blocked_until`
+
+	rows, err := r.db.Query(query, now)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []loginlimit.Entry
+	for rows.Next() {
+		var e loginlimit.Entry
+		if err := scanLoginAttempt(rows, &e); err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+
+	return entries, rows.Err()
+}
+
+// DeleteStale removes counters whose failures are older than since and that
+// no longer block anything.
+func (r *LoginAttemptRepository) DeleteStale(now, since time.Time) (int64, error) {
+	query := `DELETE FROM login_attempts
+	          WHERE last_failure_at < $1 AND (blocked_until IS NULL OR blocked_until <= $2)`
+
+	result, err := r.db.Exec(query, since, now)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// StartPruner deletes stale counters once per failure window in the
+// background, like MemoryStore does on write.
+func (r *LoginAttemptRepository) StartPruner(window time.Duration) {
+	if window <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(window)
+		defer ticker.Stop()
+
+		for now := range ticker.C {
+			if _, err := r.DeleteStale(now, now.Add(-window)); err != nil {
+				log.Printf("Failed to prune login attempts: %v", err)
+			}
+		}
+	}()
+}
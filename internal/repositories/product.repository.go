@@ -3,6 +3,8 @@ package repositories
 import (
 	"database/sql"
 	"pwa-backend/internal/models"
+
+	"github.com/lib/pq"
 )
 
 type ProductRepository struct {
@@ -13,11 +15,26 @@ func NewProductRepository(db *sql.DB) *ProductRepository {
 	return &ProductRepository{db: db}
 }
 
-func (r *ProductRepository) GetAll() ([]models.Product, error) {
-	query := `SELECT id, name, description, price, stock, image_url, created_at, updated_at 
-	          FROM products ORDER BY name`
+// productColumns and productFrom read products with the stock of the store
+// passed as $1, or the total over all stores when $1 is NULL.
+const productColumns = `p.id, p.name, COALESCE(p.description, ''), p.price,
+	CASE WHEN $1::varchar IS NULL THEN p.stock ELSE COALESCE(ps.stock, 0) END,
+	COALESCE(p.image_url, ''), p.archived_at, p.created_at, p.updated_at, $1::varchar`
+
+const productFrom = ` FROM products p LEFT JOIN product_stocks ps ON ps.product_id = p.id AND ps.store_id = $1`
+
+func scanProduct(row rowScanner, p *models.Product) error {
+	return row.Scan(&p.ID, &p.Name, &p.Description, &p.Price, &p.Stock, &p.ImageURL, &p.ArchivedAt, &p.CreatedAt, &p.UpdatedAt, &p.StoreID)
+}
+
+func (r *ProductRepository) GetAll(includeArchived bool, storeID *string) ([]models.Product, error) {
+	query := `SELECT ` + productColumns + productFrom
+	if !includeArchived {
+		query += ` WHERE p.archived_at IS NULL`
+	}
+	query += ` ORDER BY p.name`
 	
-	rows, err := r.db.Query(query)
+	rows, err := r.db.Query(query, storeID)
 	if err != nil {
 		return nil, err
 	}
@@ -26,7 +43,7 @@ func (r *ProductRepository) GetAll() ([]models.Product, error) {
 	var products []models.Product
 	for rows.Next() {
 		var p models.Product
-		if err := rows.Scan(&p.ID, &p.Name, &p.Description, &p.Price, &p.Stock, &p.ImageURL, &p.CreatedAt, &p.UpdatedAt); err != nil {
+		if err := scanProduct(rows, &p); err != nil {
 			return nil, err
 		}
 		products = append(products, p)
@@ -35,14 +52,11 @@ func (r *ProductRepository) GetAll() ([]models.Product, error) {
 	return products, nil
 }
 
-func (r *ProductRepository) GetByID(id string) (*models.Product, error) {
+func (r *ProductRepository) GetByID(id string, storeID *string) (*models.Product, error) {
 	var p models.Product
-	query := `SELECT id, name, description, price, stock, image_url, created_at, updated_at 
-	          FROM products WHERE id = $1`
+	query := `SELECT ` + productColumns + productFrom + ` WHERE p.id = $2`
 	
-	err := r.db.QueryRow(query, id).Scan(
-		&p.ID, &p.Name, &p.Description, &p.Price, &p.Stock, &p.ImageURL, &p.CreatedAt, &p.UpdatedAt,
-	)
+	err := scanProduct(r.db.QueryRow(query, storeID, id), &p)
 	
 	if err == sql.ErrNoRows {
 		return nil, nil
@@ -54,8 +68,141 @@ func (r *ProductRepository) GetByID(id string) (*models.Product, error) {
 	return &p, nil
 }
 
-func (r *ProductRepository) UpdateStockByQty(tx *sql.Tx, productID string, qty int) error {
+// GetByIDForUpdate reads a product with its stock in storeID and locks the
+// product row until tx ends, so stock checks and the following stock update
+// cannot race with other writers.
+func (r *ProductRepository) GetByIDForUpdate(tx *sql.Tx, storeID, id string) (*models.Product, error) {
+	var p models.Product
+	query := `SELECT ` + productColumns + productFrom + ` WHERE p.id = $2 FOR UPDATE OF p`
+
+	err := scanProduct(tx.QueryRow(query, storeID, id), &p)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &p, nil
+}
+
+// GetByIDsForUpdate locks several product rows at once. Rows are locked in id
+// order so concurrent checkouts touching the same products cannot deadlock.
+// Missing ids are simply absent from the result.
+func (r *ProductRepository) GetByIDsForUpdate(tx *sql.Tx, storeID string, ids []string) (map[string]*models.Product, error) {
+	query := `SELECT ` + productColumns + productFrom + ` WHERE p.id = ANY($2) ORDER BY p.id FOR UPDATE OF p`
+
+	rows, err := tx.Query(query, storeID, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	products := make(map[string]*models.Product, len(ids))
+	for rows.Next() {
+		var p models.Product
+		if err := scanProduct(rows, &p); err != nil {
+			return nil, err
+		}
+		products[p.ID] = &p
+	}
+
+	return products, rows.Err()
+}
+
+func (r *ProductRepository) BeginTx() (*sql.Tx, error) {
+	return r.db.Begin()
+}
+
+// Create inserts a product with zero stock. Initial stock must be added with an
+// opening_stock stock event so the ledger stays complete.
+func (r *ProductRepository) Create(tx *sql.Tx, p *models.Product) error {
+	query := `INSERT INTO products (id, name, description, price, stock, image_url, created_at, updated_at)
+	          VALUES ($1, $2, $3, $4, 0, $5, $6, $7)`
+
+	_, err := tx.Exec(query, p.ID, p.Name, p.Description, p.Price, p.ImageURL, p.CreatedAt, p.UpdatedAt)
+	return err
+}
+
+// Update writes the catalog fields of a product. Stock is deliberately not
+// touched here; it only changes through stock events.
+func (r *ProductRepository) Update(p *models.Product) error {
+	query := `UPDATE products SET name = $1, description = $2, price = $3, image_url = $4, updated_at = $5
+	          WHERE id = $6`
+
+	_, err := r.db.Exec(query, p.Name, p.Description, p.Price, p.ImageURL, p.UpdatedAt, p.ID)
+	return err
+}
+
+func (r *ProductRepository) Archive(id string) error {
+	query := `UPDATE products SET archived_at = NOW(), updated_at = NOW() WHERE id = $1 AND archived_at IS NULL`
+	_, err := r.db.Exec(query, id)
+	return err
+}
+
+// UpdateStockByQty changes the total on the product, the store total and the
+// stock at one location of the store by qty. The product row is written
+// first: a stock rebuild locks the products table, and touching it before the
+// other caches keeps writers from deadlocking with it.
+func (r *ProductRepository) UpdateStockByQty(tx *sql.Tx, storeID, locationID, productID string, qty int) error {
 	query := `UPDATE products SET stock = stock + $1, updated_at = NOW() WHERE id = $2`
-	_, err := tx.Exec(query, qty, productID)
+	if _, err := tx.Exec(query, qty, productID); err != nil {
+		return err
+	}
+
+	query = `INSERT INTO product_stocks (store_id, product_id, stock, updated_at) VALUES ($1, $2, $3, NOW())
+	          ON CONFLICT (store_id, product_id) DO UPDATE SET stock = product_stocks.stock + EXCLUDED.stock, updated_at = NOW()`
+	if _, err := tx.Exec(query, storeID, productID, qty); err != nil {
+		return err
+	}
+
+	query = `INSERT INTO location_stocks (location_id, product_id, stock, updated_at) VALUES ($1, $2, $3, NOW())
+	          ON CONFLICT (location_id, product_id) DO UPDATE SET stock = location_stocks.stock + EXCLUDED.stock, updated_at = NOW()`
+	_, err := tx.Exec(query, locationID, productID, qty)
 	return err
-}
\ No newline at end of file
+}
+
+// GetLocationStock reads the stock of a product at a location. Hold the
+// product row lock so it cannot change before the following update.
+func (r *ProductRepository) GetLocationStock(tx *sql.Tx, locationID, productID string) (int, error) {
+	var stock int
+	query := `SELECT COALESCE((SELECT stock FROM location_stocks WHERE location_id = $1 AND product_id = $2), 0)`
+	err := tx.QueryRow(query, locationID, productID).Scan(&stock)
+	return stock, err
+}
+
+// GetDefaultLocationStock returns the stock of a product at the default
+// location of a store, where sales are taken from.
+func (r *ProductRepository) GetDefaultLocationStock(tx *sql.Tx, storeID, productID string) (int, error) {
+	var stock int
+	query := `SELECT COALESCE((
+	              SELECT ls.stock FROM location_stocks ls
+	              JOIN locations l ON l.id = ls.location_id
+	              WHERE l.store_id = $1 AND l.is_default AND ls.product_id = $2
+	          ), 0)`
+	err := tx.QueryRow(query, storeID, productID).Scan(&stock)
+	return stock, err
+}
+
+// GetStocks lists the stock of a product in every store that ever held it.
+func (r *ProductRepository) GetStocks(productID string) ([]models.ProductStock, error) {
+	query := `SELECT store_id, product_id, stock, updated_at FROM product_stocks WHERE product_id = $1 ORDER BY store_id`
+
+	rows, err := r.db.Query(query, productID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	stocks := []models.ProductStock{}
+	for rows.Next() {
+		var s models.ProductStock
+		if err := rows.Scan(&s.StoreID, &s.ProductID, &s.Stock, &s.UpdatedAt); err != nil {
+			return nil, err
+		}
+		stocks = append(stocks, s)
+	}
+
+	return stocks, rows.Err()
+}
@@ -0,0 +1,179 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"pwa-backend/internal/ids"
+	"pwa-backend/internal/models"
+)
+
+// ReconciliationRepository compares the cached stock levels (products.stock,
+// product_stocks and location_stocks) with the stock_events ledger and
+// rebuilds them from it.
+type ReconciliationRepository struct {
+	db *sql.DB
+}
+
+func NewReconciliationRepository(db *sql.DB) *ReconciliationRepository {
+	return &ReconciliationRepository{db: db}
+}
+
+const productDriftQuery = `
+	SELECT p.id, p.stock, COALESCE(e.qty, 0)
+	FROM products p
+	LEFT JOIN (SELECT product_id, SUM(qty) AS qty FROM stock_events GROUP BY product_id) e ON e.product_id = p.id
+	WHERE p.stock <> COALESCE(e.qty, 0)
+	ORDER BY p.id
+`
+
+const storeDriftQuery = `
+	SELECT COALESCE(s.store_id, e.store_id), COALESCE(s.product_id, e.product_id), COALESCE(s.stock, 0), COALESCE(e.qty, 0)
+	FROM product_stocks s
+	FULL JOIN (SELECT store_id, product_id, SUM(qty) AS qty FROM stock_events GROUP BY store_id, product_id) e
+		ON e.store_id = s.store_id AND e.product_id = s.product_id
+	WHERE COALESCE(s.stock, 0) <> COALESCE(e.qty, 0)
+	ORDER BY 1, 2
+`
+
+const locationDriftQuery = `
+	SELECT l.store_id, l.id, d.product_id, d.cached, d.ledger
+	FROM (
+		SELECT COALESCE(s.location_id, e.location_id) AS location_id, COALESCE(s.product_id, e.product_id) AS product_id,
+		       COALESCE(s.stock, 0) AS cached, COALESCE(e.qty, 0) AS ledger
+		FROM location_stocks s
+		FULL JOIN (SELECT location_id, product_id, SUM(qty) AS qty FROM stock_events GROUP BY location_id, product_id) e
+			ON e.location_id = s.location_id AND e.product_id = s.product_id
+		WHERE COALESCE(s.stock, 0) <> COALESCE(e.qty, 0)
+	) d
+	JOIN locations l ON l.id = d.location_id
+	ORDER BY l.store_id, l.id, d.product_id
+`
+
+// FindDrift lists every cached stock level that disagrees with the ledger.
+// All levels are read from one snapshot, so writes committing meanwhile do
+// not show up as drift.
+func (r *ReconciliationRepository) FindDrift() ([]models.StockDrift, error) {
+	tx, err := r.db.BeginTx(context.Background(), &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	return findDrift(tx)
+}
+
+// Rebuild overwrites every drifted cache with the ledger value and records
+// each correction in stock_corrections under runID. It locks the products
+// table for the duration, which holds back all stock writers: they lock or
+// update a product row before any other stock cache.
+func (r *ReconciliationRepository) Rebuild(runID, trigger string) ([]models.StockDrift, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`LOCK TABLE products IN EXCLUSIVE MODE`); err != nil {
+		return nil, err
+	}
+
+	drifts, err := findDrift(tx)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	for _, drift := range drifts {
+		if err := correct(tx, drift); err != nil {
+			return nil, err
+		}
+
+		query := `INSERT INTO stock_corrections
+		          (id, run_id, trigger, level, product_id, store_id, location_id, cached_stock, ledger_stock, created_at)
+		          VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`
+		_, err := tx.Exec(query, ids.New(), runID, trigger, drift.Level, drift.ProductID, drift.StoreID, drift.LocationID,
+			drift.Cached, drift.Ledger, now)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return drifts, nil
+}
+
+func correct(tx *sql.Tx, drift models.StockDrift) error {
+	var err error
+	switch drift.Level {
+	case models.DriftLevelProduct:
+		_, err = tx.Exec(`UPDATE products SET stock = $1, updated_at = NOW() WHERE id = $2`, drift.Ledger, drift.ProductID)
+	case models.DriftLevelStore:
+		query := `INSERT INTO product_stocks (store_id, product_id, stock, updated_at) VALUES ($1, $2, $3, NOW())
+		          ON CONFLICT (store_id, product_id) DO UPDATE SET stock = EXCLUDED.stock, updated_at = NOW()`
+		_, err = tx.Exec(query, drift.StoreID, drift.ProductID, drift.Ledger)
+	case models.DriftLevelLocation:
+		query := `INSERT INTO location_stocks (location_id, product_id, stock, updated_at) VALUES ($1, $2, $3, NOW())
+		          ON CONFLICT (location_id, product_id) DO UPDATE SET stock = EXCLUDED.stock, updated_at = NOW()`
+		_, err = tx.Exec(query, drift.LocationID, drift.ProductID, drift.Ledger)
+	}
+	return err
+}
+
+func findDrift(q queryer) ([]models.StockDrift, error) {
+	drifts := []models.StockDrift{}
+
+	rows, err := q.Query(productDriftQuery)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		d := models.StockDrift{Level: models.DriftLevelProduct}
+		if err := rows.Scan(&d.ProductID, &d.Cached, &d.Ledger); err != nil {
+			return nil, err
+		}
+		drifts = append(drifts, d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	storeRows, err := q.Query(storeDriftQuery)
+	if err != nil {
+		return nil, err
+	}
+	defer storeRows.Close()
+
+	for storeRows.Next() {
+		d := models.StockDrift{Level: models.DriftLevelStore}
+		if err := storeRows.Scan(&d.StoreID, &d.ProductID, &d.Cached, &d.Ledger); err != nil {
+			return nil, err
+		}
+		drifts = append(drifts, d)
+	}
+	if err := storeRows.Err(); err != nil {
+		return nil, err
+	}
+
+	locationRows, err := q.Query(locationDriftQuery)
+	if err != nil {
+		return nil, err
+	}
+	defer locationRows.Close()
+
+	for locationRows.Next() {
+		d := models.StockDrift{Level: models.DriftLevelLocation}
+		if err := locationRows.Scan(&d.StoreID, &d.LocationID, &d.ProductID, &d.Cached, &d.Ledger); err != nil {
+			return nil, err
+		}
+		drifts = append(drifts, d)
+	}
+
+	return drifts, locationRows.Err()
+}
@@ -0,0 +1,126 @@
+package repositories
+
+import (
+	"database/sql"
+
+	"pwa-backend/internal/models"
+)
+
+type RefundRepository struct {
+	db *sql.DB
+}
+
+func NewRefundRepository(db *sql.DB) *RefundRepository {
+	return &RefundRepository{db: db}
+}
+
+func (r *RefundRepository) BeginTx() (*sql.Tx, error) {
+	return r.db.Begin()
+}
+
+func (r *RefundRepository) Create(tx *sql.Tx, refund *models.Refund) error {
+	query := `INSERT INTO refunds (id, transaction_id, user_id, reason, total_amount, created_at)
+	          VALUES ($1, $2, $3, $4, $5, $6)`
+
+	_, err := tx.Exec(query, refund.ID, refund.TransactionID, refund.UserID, refund.Reason, refund.TotalAmount, refund.CreatedAt)
+	return err
+}
+
+func (r *RefundRepository) CreateItems(tx *sql.Tx, items []models.RefundItem) error {
+	query := `INSERT INTO refund_items (id, refund_id, transaction_item_id, product_id, quantity, amount, restock, created_at)
+	          VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`
+
+	for _, item := range items {
+		_, err := tx.Exec(query, item.ID, item.RefundID, item.TransactionItemID, item.ProductID, item.Quantity, item.Amount, item.Restock, item.CreatedAt)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// RefundedQtyByItem returns how many units of each transaction item have
+// already been refunded. Call it while holding the transaction row lock so the
+// numbers cannot change before the new refund is written.
+func (r *RefundRepository) RefundedQtyByItem(tx *sql.Tx, transactionID string) (map[string]int, error) {
+	query := `
+		SELECT ri.transaction_item_id, SUM(ri.quantity)
+		FROM refund_items ri
+		JOIN refunds rf ON rf.id = ri.refund_id
+		WHERE rf.transaction_id = $1
+		GROUP BY ri.transaction_item_id
+	`
+
+	rows, err := tx.Query(query, transactionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	refunded := make(map[string]int)
+	for rows.Next() {
+		var itemID string
+		var qty int
+		if err := rows.Scan(&itemID, &qty); err != nil {
+			return nil, err
+		}
+		refunded[itemID] = qty
+	}
+
+	return refunded, rows.Err()
+}
+
+func (r *RefundRepository) GetByTransaction(transactionID string) ([]models.Refund, error) {
+	query := `SELECT id, transaction_id, user_id, COALESCE(reason, ''), total_amount, created_at
+	          FROM refunds WHERE transaction_id = $1 ORDER BY created_at`
+
+	rows, err := r.db.Query(query, transactionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var refunds []models.Refund
+	index := make(map[string]int)
+	for rows.Next() {
+		var rf models.Refund
+		if err := rows.Scan(&rf.ID, &rf.TransactionID, &rf.UserID, &rf.Reason, &rf.TotalAmount, &rf.CreatedAt); err != nil {
+			return nil, err
+		}
+		index[rf.ID] = len(refunds)
+		refunds = append(refunds, rf)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	itemsQuery := `
+		SELECT ri.id, ri.refund_id, ri.transaction_item_id, ri.product_id, ri.quantity, ri.amount, ri.restock, ri.created_at
+		FROM refund_items ri
+		JOIN refunds rf ON rf.id = ri.refund_id
+		WHERE rf.transaction_id = $1
+		ORDER BY ri.created_at
+	`
+
+	itemRows, err := r.db.Query(itemsQuery, transactionID)
+	if err != nil {
+		return nil, err
+	}
+	defer itemRows.Close()
+
+	for itemRows.Next() {
+		var item models.RefundItem
+		err := itemRows.Scan(
+			&item.ID, &item.RefundID, &item.TransactionItemID, &item.ProductID,
+			&item.Quantity, &item.Amount, &item.Restock, &item.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		i := index[item.RefundID]
+		refunds[i].Items = append(refunds[i].Items, item)
+	}
+
+	return refunds, itemRows.Err()
+}
@@ -1,8 +1,10 @@
 package repositories
 
 import (
+	"context"
 	"database/sql"
 	"pwa-backend/internal/models"
+	"time"
 )
 
 type StockEventRepository struct {
@@ -13,39 +15,76 @@ func NewStockEventRepository(db *sql.DB) *StockEventRepository {
 	return &StockEventRepository{db: db}
 }
 
+const stockEventColumns = `id, store_id, location_id, product_id, qty, type, source, transaction_id, transfer_id, stocktake_id, user_id, device_id, note, created_at`
+
+func scanStockEvent(row rowScanner, e *models.StockEvent) error {
+	return row.Scan(
+		&e.ID, &e.StoreID, &e.LocationID, &e.ProductID, &e.Qty, &e.Type, &e.Source,
+		&e.TransactionID, &e.TransferID, &e.StocktakeID, &e.UserID, &e.DeviceID, &e.Note, &e.CreatedAt,
+	)
+}
+
+// Create inserts a stock event. An event without a location is booked at the
+// default location of its store, and event.LocationID is filled in. For an
+// unknown store the lookup falls back to '' so the insert fails with a
+// foreign key violation like any other bad reference.
 func (r *StockEventRepository) Create(tx *sql.Tx, event *models.StockEvent) error {
 	query := `
 		INSERT INTO stock_events 
-		(id, product_id, qty, type, source, transaction_id, user_id, device_id, note, created_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		(id, store_id, location_id, product_id, qty, type, source, transaction_id, transfer_id, stocktake_id, user_id, device_id, note, created_at)
+		VALUES ($1, $2, COALESCE(NULLIF($3, ''), (SELECT id FROM locations WHERE store_id = $2 AND is_default), ''),
+		        $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
+		RETURNING location_id
 	`
 	
-	_, err := tx.Exec(query,
+	return tx.QueryRow(query,
 		event.ID,
+		event.StoreID,
+		event.LocationID,
 		event.ProductID,
 		event.Qty,
 		event.Type,
 		event.Source,
 		event.TransactionID,
+		event.TransferID,
+		event.StocktakeID,
 		event.UserID,
 		event.DeviceID,
 		event.Note,
 		event.CreatedAt,
-	)
+	).Scan(&event.LocationID)
+}
+
+// GetByProduct lists the latest stock events of a product. A nil storeID
+// includes every store.
+func (r *StockEventRepository) GetByProduct(productID string, storeID *string, limit int) ([]models.StockEvent, error) {
+	query := `
+		SELECT ` + stockEventColumns + `
+		FROM stock_events
+		WHERE product_id = $1 AND ($2::varchar IS NULL OR store_id = $2)
+		ORDER BY created_at DESC
+		LIMIT $3
+	`
 	
-	return err
+	return r.query(query, productID, storeID, limit)
 }
 
-func (r *StockEventRepository) GetByProduct(productID string, limit int) ([]models.StockEvent, error) {
+// GetAll lists stock events newest first. A nil storeID includes every
+// store.
+func (r *StockEventRepository) GetAll(storeID *string, limit, offset int) ([]models.StockEvent, error) {
 	query := `
-		SELECT id, product_id, qty, type, source, transaction_id, user_id, device_id, note, created_at
+		SELECT ` + stockEventColumns + `
 		FROM stock_events
-		WHERE product_id = $1
+		WHERE $1::varchar IS NULL OR store_id = $1
 		ORDER BY created_at DESC
-		LIMIT $2
+		LIMIT $2 OFFSET $3
 	`
 	
-	rows, err := r.db.Query(query, productID, limit)
+	return r.query(query, storeID, limit, offset)
+}
+
+func (r *StockEventRepository) query(query string, args ...interface{}) ([]models.StockEvent, error) {
+	rows, err := r.db.Query(query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -54,49 +93,181 @@ func (r *StockEventRepository) GetByProduct(productID string, limit int) ([]mode
 	var events []models.StockEvent
 	for rows.Next() {
 		var e models.StockEvent
-		err := rows.Scan(
-			&e.ID, &e.ProductID, &e.Qty, &e.Type, &e.Source,
-			&e.TransactionID, &e.UserID, &e.DeviceID, &e.Note, &e.CreatedAt,
-		)
-		if err != nil {
+		if err := scanStockEvent(rows, &e); err != nil {
 			return nil, err
 		}
 		events = append(events, e)
 	}
 
-	return events, nil
+	return events, rows.Err()
 }
 
-func (r *StockEventRepository) GetAll(limit, offset int) ([]models.StockEvent, error) {
+// NetQtyByTransaction sums the stock movements linked to a transaction per
+// product, e.g. -3 for a sale of three units that has not been reversed.
+func (r *StockEventRepository) NetQtyByTransaction(tx *sql.Tx, transactionID string) (map[string]int, error) {
 	query := `
-		SELECT id, product_id, qty, type, source, transaction_id, user_id, device_id, note, created_at
+		SELECT product_id, SUM(qty)
 		FROM stock_events
-		ORDER BY created_at DESC
-		LIMIT $1 OFFSET $2
+		WHERE transaction_id = $1
+		GROUP BY product_id
 	`
-	
-	rows, err := r.db.Query(query, limit, offset)
+
+	rows, err := tx.Query(query, transactionID)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var events []models.StockEvent
+	net := make(map[string]int)
 	for rows.Next() {
-		var e models.StockEvent
-		err := rows.Scan(
-			&e.ID, &e.ProductID, &e.Qty, &e.Type, &e.Source,
-			&e.TransactionID, &e.UserID, &e.DeviceID, &e.Note, &e.CreatedAt,
-		)
-		if err != nil {
+		var productID string
+		var qty int
+		if err := rows.Scan(&productID, &qty); err != nil {
 			return nil, err
 		}
-		events = append(events, e)
+		net[productID] = qty
 	}
 
-	return events, nil
+	return net, rows.Err()
+}
+
+// LocationMovementSince sums the stock events of a product at a location
+// created after since.
+func (r *StockEventRepository) LocationMovementSince(tx *sql.Tx, locationID, productID string, since time.Time) (int, error) {
+	query := `SELECT COALESCE(SUM(qty), 0) FROM stock_events
+	          WHERE location_id = $1 AND product_id = $2 AND created_at > $3`
+
+	var qty int
+	err := tx.QueryRow(query, locationID, productID, since).Scan(&qty)
+	return qty, err
+}
+
+func (r *StockEventRepository) ExistsTx(tx *sql.Tx, id string) (bool, error) {
+	var exists bool
+	err := tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM stock_events WHERE id = $1)`, id).Scan(&exists)
+	return exists, err
 }
 
 func (r *StockEventRepository) BeginTx() (*sql.Tx, error) {
 	return r.db.Begin()
-}
\ No newline at end of file
+}
+// StockAsOf sums the ledger per product over the events created at or before
+// at. Products created later are left out. Nil filters include everything.
+func (r *StockEventRepository) StockAsOf(at time.Time, storeID, locationID, productID *string) ([]models.StockAsOf, error) {
+	return stockAsOf(r.db, at, storeID, locationID, productID)
+}
+
+func stockAsOf(q queryer, at time.Time, storeID, locationID, productID *string) ([]models.StockAsOf, error) {
+	query := `
+		SELECT p.id, p.name, COALESCE(SUM(e.qty), 0)
+		FROM products p
+		LEFT JOIN stock_events e ON e.product_id = p.id AND e.created_at <= $1
+			AND ($2::varchar IS NULL OR e.store_id = $2)
+			AND ($3::varchar IS NULL OR e.location_id = $3)
+		WHERE p.created_at <= $1 AND ($4::varchar IS NULL OR p.id = $4)
+		GROUP BY p.id, p.name
+		ORDER BY p.name, p.id
+	`
+
+	rows, err := q.Query(query, at, storeID, locationID, productID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []models.StockAsOf{}
+	for rows.Next() {
+		var item models.StockAsOf
+		if err := rows.Scan(&item.ProductID, &item.ProductName, &item.Stock); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+
+	return items, rows.Err()
+}
+
+// Movements summarises the ledger per product for the events created from
+// from to to, both inclusive: the opening balance just before from, inflow
+// and outflow per event type, and the closing balance at to. All three are
+// read from one snapshot, so events committing meanwhile cannot make opening
+// plus flows disagree with closing.
+func (r *StockEventRepository) Movements(from, to time.Time, storeID, locationID, productID *string) ([]models.StockMovementSummary, error) {
+	tx, err := r.db.BeginTx(context.Background(), &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// Timestamps have microsecond precision, so this is the last instant
+	// before from.
+	opening, err := stockAsOf(tx, from.Add(-time.Microsecond), storeID, locationID, productID)
+	if err != nil {
+		return nil, err
+	}
+
+	closing, err := stockAsOf(tx, to, storeID, locationID, productID)
+	if err != nil {
+		return nil, err
+	}
+
+	openingByProduct := make(map[string]int, len(opening))
+	for _, item := range opening {
+		openingByProduct[item.ProductID] = item.Stock
+	}
+
+	summaries := make([]models.StockMovementSummary, len(closing))
+	index := make(map[string]int, len(closing))
+	for i, item := range closing {
+		summaries[i] = models.StockMovementSummary{
+			ProductID:   item.ProductID,
+			ProductName: item.ProductName,
+			Opening:     openingByProduct[item.ProductID],
+			Inflow:      map[string]int{},
+			Outflow:     map[string]int{},
+			Closing:     item.Stock,
+		}
+		index[item.ProductID] = i
+	}
+
+	query := `
+		SELECT product_id, type,
+		       COALESCE(SUM(qty) FILTER (WHERE qty > 0), 0),
+		       COALESCE(-SUM(qty) FILTER (WHERE qty < 0), 0)
+		FROM stock_events
+		WHERE created_at >= $1 AND created_at <= $2
+			AND ($3::varchar IS NULL OR store_id = $3)
+			AND ($4::varchar IS NULL OR location_id = $4)
+			AND ($5::varchar IS NULL OR product_id = $5)
+		GROUP BY product_id, type
+	`
+
+	rows, err := tx.Query(query, from, to, storeID, locationID, productID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var productID, eventType string
+		var in, out int
+		if err := rows.Scan(&productID, &eventType, &in, &out); err != nil {
+			return nil, err
+		}
+
+		i, ok := index[productID]
+		if !ok {
+			continue
+		}
+		if in > 0 {
+			summaries[i].Inflow[eventType] = in
+			summaries[i].TotalIn += in
+		}
+		if out > 0 {
+			summaries[i].Outflow[eventType] = out
+			summaries[i].TotalOut += out
+		}
+	}
+
+	return summaries, rows.Err()
+}
@@ -0,0 +1,151 @@
+package repositories
+
+import (
+	"database/sql"
+
+	"pwa-backend/internal/models"
+)
+
+type StockTransferRepository struct {
+	db *sql.DB
+}
+
+func NewStockTransferRepository(db *sql.DB) *StockTransferRepository {
+	return &StockTransferRepository{db: db}
+}
+
+const stockTransferColumns = `id, from_store_id, from_location_id, to_store_id, to_location_id, status, COALESCE(note, ''),
+	created_by, received_by, created_at, received_at, cancelled_at`
+
+func scanStockTransfer(row rowScanner, t *models.StockTransfer) error {
+	return row.Scan(
+		&t.ID, &t.FromStoreID, &t.FromLocationID, &t.ToStoreID, &t.ToLocationID, &t.Status, &t.Note,
+		&t.CreatedBy, &t.ReceivedBy, &t.CreatedAt, &t.ReceivedAt, &t.CancelledAt,
+	)
+}
+
+func (r *StockTransferRepository) BeginTx() (*sql.Tx, error) {
+	return r.db.Begin()
+}
+
+func (r *StockTransferRepository) Create(tx *sql.Tx, t *models.StockTransfer) error {
+	query := `INSERT INTO stock_transfers
+	          (id, from_store_id, from_location_id, to_store_id, to_location_id, status, note, created_by, received_by, created_at, received_at)
+	          VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`
+
+	_, err := tx.Exec(query, t.ID, t.FromStoreID, t.FromLocationID, t.ToStoreID, t.ToLocationID, t.Status, t.Note,
+		t.CreatedBy, t.ReceivedBy, t.CreatedAt, t.ReceivedAt)
+	return err
+}
+
+func (r *StockTransferRepository) CreateItems(tx *sql.Tx, items []models.StockTransferItem) error {
+	query := `INSERT INTO stock_transfer_items (id, transfer_id, product_id, qty) VALUES ($1, $2, $3, $4)`
+
+	for _, item := range items {
+		if _, err := tx.Exec(query, item.ID, item.TransferID, item.ProductID, item.Qty); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// UpdateStatus writes the status of a transfer and when and by whom it was
+// received or cancelled.
+func (r *StockTransferRepository) UpdateStatus(tx *sql.Tx, t *models.StockTransfer) error {
+	query := `UPDATE stock_transfers SET status = $1, received_by = $2, received_at = $3, cancelled_at = $4 WHERE id = $5`
+	_, err := tx.Exec(query, t.Status, t.ReceivedBy, t.ReceivedAt, t.CancelledAt, t.ID)
+	return err
+}
+
+// GetByIDForUpdate reads a transfer with its items and locks it until tx
+// ends, so it cannot be received and cancelled at the same time.
+func (r *StockTransferRepository) GetByIDForUpdate(tx *sql.Tx, id string) (*models.StockTransfer, error) {
+	var t models.StockTransfer
+	query := `SELECT ` + stockTransferColumns + ` FROM stock_transfers WHERE id = $1 FOR UPDATE`
+
+	err := scanStockTransfer(tx.QueryRow(query, id), &t)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	t.Items, err = getStockTransferItems(tx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return &t, nil
+}
+
+func (r *StockTransferRepository) GetByID(id string) (*models.StockTransfer, error) {
+	var t models.StockTransfer
+	query := `SELECT ` + stockTransferColumns + ` FROM stock_transfers WHERE id = $1`
+
+	err := scanStockTransfer(r.db.QueryRow(query, id), &t)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	t.Items, err = getStockTransferItems(r.db, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return &t, nil
+}
+
+// GetAll lists transfers newest first, without their items. A nil storeID
+// includes every store; otherwise transfers out of and into the store are
+// listed. An empty status includes every status.
+func (r *StockTransferRepository) GetAll(storeID *string, status string, limit, offset int) ([]models.StockTransfer, error) {
+	query := `SELECT ` + stockTransferColumns + ` FROM stock_transfers
+	          WHERE ($1::varchar IS NULL OR from_store_id = $1 OR to_store_id = $1) AND ($2 = '' OR status = $2)
+	          ORDER BY created_at DESC
+	          LIMIT $3 OFFSET $4`
+
+	rows, err := r.db.Query(query, storeID, status, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	transfers := []models.StockTransfer{}
+	for rows.Next() {
+		var t models.StockTransfer
+		if err := scanStockTransfer(rows, &t); err != nil {
+			return nil, err
+		}
+		transfers = append(transfers, t)
+	}
+
+	return transfers, rows.Err()
+}
+
+// getStockTransferItems lists the items of a transfer in product id order,
+// the order product rows are locked in.
+func getStockTransferItems(q queryer, transferID string) ([]models.StockTransferItem, error) {
+	query := `SELECT id, transfer_id, product_id, qty FROM stock_transfer_items WHERE transfer_id = $1 ORDER BY product_id`
+
+	rows, err := q.Query(query, transferID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []models.StockTransferItem
+	for rows.Next() {
+		var item models.StockTransferItem
+		if err := rows.Scan(&item.ID, &item.TransferID, &item.ProductID, &item.Qty); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+
+	return items, rows.Err()
+}
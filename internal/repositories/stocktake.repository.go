@@ -0,0 +1,218 @@
+package repositories
+
+import (
+	"database/sql"
+
+	"pwa-backend/internal/models"
+)
+
+type StocktakeRepository struct {
+	db *sql.DB
+}
+
+func NewStocktakeRepository(db *sql.DB) *StocktakeRepository {
+	return &StocktakeRepository{db: db}
+}
+
+const stocktakeColumns = `id, store_id, location_id, status, COALESCE(note, ''), created_by, approved_by, created_at, approved_at, cancelled_at`
+
+func scanStocktake(row rowScanner, s *models.Stocktake) error {
+	return row.Scan(
+		&s.ID, &s.StoreID, &s.LocationID, &s.Status, &s.Note,
+		&s.CreatedBy, &s.ApprovedBy, &s.CreatedAt, &s.ApprovedAt, &s.CancelledAt,
+	)
+}
+
+func (r *StocktakeRepository) BeginTx() (*sql.Tx, error) {
+	return r.db.Begin()
+}
+
+// Create inserts an open stocktake and freezes the current stock of its
+// location as the expected quantities. Every active product is included, and
+// archived products only if they still have stock there.
+func (r *StocktakeRepository) Create(tx *sql.Tx, s *models.Stocktake) error {
+	query := `INSERT INTO stocktakes (id, store_id, location_id, status, note, created_by, created_at)
+	          VALUES ($1, $2, $3, $4, $5, $6, $7)`
+
+	_, err := tx.Exec(query, s.ID, s.StoreID, s.LocationID, s.Status, s.Note, s.CreatedBy, s.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	query = `INSERT INTO stocktake_items (stocktake_id, store_id, product_id, expected_qty)
+	         SELECT $1, $2, p.id, COALESCE(ls.stock, 0)
+	         FROM products p
+	         LEFT JOIN location_stocks ls ON ls.product_id = p.id AND ls.location_id = $3
+	         WHERE p.archived_at IS NULL OR COALESCE(ls.stock, 0) <> 0`
+
+	_, err = tx.Exec(query, s.ID, s.StoreID, s.LocationID)
+	return err
+}
+
+// UpdateStatus writes the status of a stocktake and when and by whom it was
+// approved or cancelled. The status is copied onto its items for sync.
+func (r *StocktakeRepository) UpdateStatus(tx *sql.Tx, s *models.Stocktake) error {
+	query := `UPDATE stocktakes SET status = $1, approved_by = $2, approved_at = $3, cancelled_at = $4 WHERE id = $5`
+	if _, err := tx.Exec(query, s.Status, s.ApprovedBy, s.ApprovedAt, s.CancelledAt, s.ID); err != nil {
+		return err
+	}
+
+	_, err := tx.Exec(`UPDATE stocktake_items SET status = $1 WHERE stocktake_id = $2`, s.Status, s.ID)
+	return err
+}
+
+// GetByIDForUpdate reads a stocktake with its items and locks it until tx
+// ends, so no count can slip in while it is being approved.
+func (r *StocktakeRepository) GetByIDForUpdate(tx *sql.Tx, id string) (*models.Stocktake, error) {
+	var s models.Stocktake
+	query := `SELECT ` + stocktakeColumns + ` FROM stocktakes WHERE id = $1 FOR UPDATE`
+
+	err := scanStocktake(tx.QueryRow(query, id), &s)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	s.Items, err = getStocktakeItems(tx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return &s, nil
+}
+
+// GetByIDForShare reads a stocktake without its items and locks it against
+// approval until tx ends. Counts take this lock so they can be submitted
+// concurrently.
+func (r *StocktakeRepository) GetByIDForShare(tx *sql.Tx, id string) (*models.Stocktake, error) {
+	var s models.Stocktake
+	query := `SELECT ` + stocktakeColumns + ` FROM stocktakes WHERE id = $1 FOR SHARE`
+
+	err := scanStocktake(tx.QueryRow(query, id), &s)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &s, nil
+}
+
+// GetOpenIDByLocation returns the id of the open stocktake of a location, or
+// "" if there is none.
+func (r *StocktakeRepository) GetOpenIDByLocation(tx *sql.Tx, locationID string) (string, error) {
+	var id string
+	err := tx.QueryRow(`SELECT id FROM stocktakes WHERE location_id = $1 AND status = $2`, locationID, models.StocktakeStatusOpen).Scan(&id)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return id, err
+}
+
+func (r *StocktakeRepository) GetByID(id string) (*models.Stocktake, error) {
+	var s models.Stocktake
+	query := `SELECT ` + stocktakeColumns + ` FROM stocktakes WHERE id = $1`
+
+	err := scanStocktake(r.db.QueryRow(query, id), &s)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	s.Items, err = getStocktakeItems(r.db, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return &s, nil
+}
+
+// GetAll lists stocktakes newest first, without their items. A nil storeID
+// includes every store and an empty status every status.
+func (r *StocktakeRepository) GetAll(storeID *string, status string, limit, offset int) ([]models.Stocktake, error) {
+	query := `SELECT ` + stocktakeColumns + ` FROM stocktakes
+	          WHERE ($1::varchar IS NULL OR store_id = $1) AND ($2 = '' OR status = $2)
+	          ORDER BY created_at DESC
+	          LIMIT $3 OFFSET $4`
+
+	rows, err := r.db.Query(query, storeID, status, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	stocktakes := []models.Stocktake{}
+	for rows.Next() {
+		var s models.Stocktake
+		if err := scanStocktake(rows, &s); err != nil {
+			return nil, err
+		}
+		stocktakes = append(stocktakes, s)
+	}
+
+	return stocktakes, rows.Err()
+}
+
+func (r *StocktakeRepository) CountExistsTx(tx *sql.Tx, id string) (bool, error) {
+	var exists bool
+	err := tx.QueryRow(`SELECT EXISTS(SELECT 1 FROM stocktake_counts WHERE id = $1)`, id).Scan(&exists)
+	return exists, err
+}
+
+func (r *StocktakeRepository) CreateCount(tx *sql.Tx, count *models.StocktakeCount) error {
+	query := `INSERT INTO stocktake_counts (id, stocktake_id, product_id, qty, recount, user_id, device_id, counted_at, created_at)
+	          VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`
+
+	_, err := tx.Exec(query, count.ID, count.StocktakeID, count.ProductID, count.Qty, count.Recount,
+		count.UserID, count.DeviceID, count.CountedAt, count.CreatedAt)
+	return err
+}
+
+// getStocktakeItems lists the items of a stocktake in product id order, the
+// order product rows are locked in. The counted quantity of a product is the
+// sum of its counts from its latest recount on.
+func getStocktakeItems(q queryer, stocktakeID string) ([]models.StocktakeItem, error) {
+	query := `
+		SELECT i.product_id, p.name, i.expected_qty, c.qty, COALESCE(c.counts, 0), c.counted_at
+		FROM stocktake_items i
+		JOIN products p ON p.id = i.product_id
+		LEFT JOIN (
+			SELECT c.product_id, SUM(c.qty) AS qty, COUNT(*) AS counts, MAX(c.counted_at) AS counted_at
+			FROM stocktake_counts c
+			WHERE c.stocktake_id = $1 AND NOT EXISTS (
+				SELECT 1 FROM stocktake_counts r
+				WHERE r.stocktake_id = c.stocktake_id AND r.product_id = c.product_id
+					AND r.recount AND r.counted_at > c.counted_at
+			)
+			GROUP BY c.product_id
+		) c ON c.product_id = i.product_id
+		WHERE i.stocktake_id = $1
+		ORDER BY i.product_id
+	`
+
+	rows, err := q.Query(query, stocktakeID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []models.StocktakeItem
+	for rows.Next() {
+		var item models.StocktakeItem
+		if err := rows.Scan(&item.ProductID, &item.ProductName, &item.Expected, &item.Counted, &item.Counts, &item.CountedAt); err != nil {
+			return nil, err
+		}
+		if item.Counted != nil {
+			variance := *item.Counted - item.Expected
+			item.Variance = &variance
+		}
+		items = append(items, item)
+	}
+
+	return items, rows.Err()
+}
@@ -0,0 +1,98 @@
+package repositories
+
+import (
+	"database/sql"
+
+	"pwa-backend/internal/ids"
+	"pwa-backend/internal/models"
+)
+
+type StoreRepository struct {
+	db *sql.DB
+}
+
+func NewStoreRepository(db *sql.DB) *StoreRepository {
+	return &StoreRepository{db: db}
+}
+
+const storeColumns = `id, name, address, created_at, updated_at`
+
+func scanStore(row rowScanner, s *models.Store) error {
+	return row.Scan(&s.ID, &s.Name, &s.Address, &s.CreatedAt, &s.UpdatedAt)
+}
+
+// Create inserts a store together with its default location.
+func (r *StoreRepository) Create(s *models.Store) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `INSERT INTO stores (id, name, address, created_at, updated_at) VALUES ($1, $2, $3, $4, $5)`
+	if _, err := tx.Exec(query, s.ID, s.Name, s.Address, s.CreatedAt, s.UpdatedAt); err != nil {
+		return err
+	}
+
+	location := &models.Location{
+		ID:        ids.New(),
+		StoreID:   s.ID,
+		Name:      models.DefaultLocationName,
+		IsDefault: true,
+		CreatedAt: s.CreatedAt,
+		UpdatedAt: s.UpdatedAt,
+	}
+	if err := createLocation(tx, location); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *StoreRepository) GetAll() ([]models.Store, error) {
+	rows, err := r.db.Query(`SELECT ` + storeColumns + ` FROM stores ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	stores := []models.Store{}
+	for rows.Next() {
+		var s models.Store
+		if err := scanStore(rows, &s); err != nil {
+			return nil, err
+		}
+		stores = append(stores, s)
+	}
+
+	return stores, rows.Err()
+}
+
+func (r *StoreRepository) GetByID(id string) (*models.Store, error) {
+	var s models.Store
+	query := `SELECT ` + storeColumns + ` FROM stores WHERE id = $1`
+
+	err := scanStore(r.db.QueryRow(query, id), &s)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &s, nil
+}
+
+func (r *StoreRepository) Update(s *models.Store) error {
+	query := `UPDATE stores SET name = $1, address = $2, updated_at = $3 WHERE id = $4`
+	_, err := r.db.Exec(query, s.Name, s.Address, s.UpdatedAt, s.ID)
+	return err
+}
+
+// Delete removes a store. It fails with a foreign key violation once the
+// store has stock, transactions or stock events.
+func (r *StoreRepository) Delete(id string) error {
+	_, err := r.db.Exec(`DELETE FROM stores WHERE id = $1`, id)
+	return err
+}
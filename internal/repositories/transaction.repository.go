@@ -3,6 +3,7 @@ package repositories
 import (
 	"database/sql"
 	"pwa-backend/internal/models"
+	"pwa-backend/internal/money"
 )
 
 type TransactionRepository struct {
@@ -13,11 +14,25 @@ func NewTransactionRepository(db *sql.DB) *TransactionRepository {
 	return &TransactionRepository{db: db}
 }
 
+const transactionColumns = `id, user_id, store_id, device_id, total_amount, refunded_amount, status, created_at, updated_at`
+
+const transactionItemColumns = `id, transaction_id, product_id, product_name, quantity, price, subtotal, COALESCE(user_id, ''), created_at`
+
+func scanTransaction(row rowScanner, t *models.Transaction) error {
+	err := row.Scan(&t.ID, &t.UserID, &t.StoreID, &t.DeviceID, &t.TotalAmount, &t.RefundedAmount, &t.Status, &t.CreatedAt, &t.UpdatedAt)
+	if err != nil {
+		return err
+	}
+
+	t.NetAmount, err = t.TotalAmount.Sub(t.RefundedAmount)
+	return err
+}
+
 func (r *TransactionRepository) Create(tx *sql.Tx, transaction *models.Transaction) error {
-	query := `INSERT INTO transactions (id, user_id, total_amount, status, created_at, updated_at)
-	          VALUES ($1, $2, $3, $4, NOW(), NOW())`
+	query := `INSERT INTO transactions (id, user_id, store_id, device_id, total_amount, status, created_at, updated_at)
+	          VALUES ($1, $2, $3, $4, $5, $6, NOW(), NOW())`
 
-	_, err := tx.Exec(query, transaction.ID, transaction.UserID, transaction.TotalAmount, transaction.Status)
+	_, err := tx.Exec(query, transaction.ID, transaction.UserID, transaction.StoreID, transaction.DeviceID, transaction.TotalAmount, transaction.Status)
 	return err
 }
 
@@ -39,20 +54,27 @@ func (r *TransactionRepository) BeginTx() (*sql.Tx, error) {
 	return r.db.Begin()
 }
 
-func (r *TransactionRepository) UpdateStatus(id, status string) error {
+func (r *TransactionRepository) UpdateStatus(tx *sql.Tx, id, status string) error {
 	query := `UPDATE transactions SET status = $1, updated_at = NOW() WHERE id = $2`
-	_, err := r.db.Exec(query, status, id)
+	_, err := tx.Exec(query, status, id)
 	return err
 }
 
-func (r *TransactionRepository) GetByID(id string) (*models.Transaction, error) {
+// AddRefundedAmount records money paid back on a transaction. The net amount
+// reported to clients is total_amount - refunded_amount.
+func (r *TransactionRepository) AddRefundedAmount(tx *sql.Tx, id string, amount money.Money) error {
+	query := `UPDATE transactions SET refunded_amount = refunded_amount + $1, updated_at = NOW() WHERE id = $2`
+	_, err := tx.Exec(query, amount, id)
+	return err
+}
+
+// GetByIDForUpdate reads a transaction and locks its row until tx ends, so two
+// status changes cannot both start from the same status.
+func (r *TransactionRepository) GetByIDForUpdate(tx *sql.Tx, id string) (*models.Transaction, error) {
 	var t models.Transaction
-	query := `SELECT id, user_id, total_amount, status, created_at, updated_at 
-	          FROM transactions WHERE id = $1`
+	query := `SELECT ` + transactionColumns + ` FROM transactions WHERE id = $1 FOR UPDATE`
 
-	err := r.db.QueryRow(query, id).Scan(
-		&t.ID, &t.UserID, &t.TotalAmount, &t.Status, &t.CreatedAt, &t.UpdatedAt,
-	)
+	err := scanTransaction(tx.QueryRow(query, id), &t)
 
 	if err == sql.ErrNoRows {
 		return nil, nil
@@ -64,14 +86,23 @@ func (r *TransactionRepository) GetByID(id string) (*models.Transaction, error)
 	return &t, nil
 }
 
-func (r *TransactionRepository) GetByIDWithItems(id string) (*models.Transaction, error) {
+func (r *TransactionRepository) ExistsTx(tx *sql.Tx, id string) (bool, error) {
+	var exists bool
+	err := tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM transactions WHERE id = $1)`, id).Scan(&exists)
+	return exists, err
+}
+
+func (r *TransactionRepository) ItemExistsTx(tx *sql.Tx, id string) (bool, error) {
+	var exists bool
+	err := tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM transaction_items WHERE id = $1)`, id).Scan(&exists)
+	return exists, err
+}
+
+func (r *TransactionRepository) GetByID(id string) (*models.Transaction, error) {
 	var t models.Transaction
-	query := `SELECT id, user_id, total_amount, status, created_at, updated_at 
-	          FROM transactions WHERE id = $1`
+	query := `SELECT ` + transactionColumns + ` FROM transactions WHERE id = $1`
 
-	err := r.db.QueryRow(query, id).Scan(
-		&t.ID, &t.UserID, &t.TotalAmount, &t.Status, &t.CreatedAt, &t.UpdatedAt,
-	)
+	err := scanTransaction(r.db.QueryRow(query, id), &t)
 
 	if err == sql.ErrNoRows {
 		return nil, nil
@@ -80,15 +111,34 @@ func (r *TransactionRepository) GetByIDWithItems(id string) (*models.Transaction
 		return nil, err
 	}
 
-	itemsQuery := `SELECT id, transaction_id, product_id, product_name, quantity, price, subtotal, user_id, created_at 
+	return &t, nil
+}
+
+func (r *TransactionRepository) GetByIDWithItems(id string) (*models.Transaction, error) {
+	t, err := r.GetByID(id)
+	if err != nil || t == nil {
+		return t, err
+	}
+
+	t.Items, err = r.GetItems(id)
+	if err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
+
+func (r *TransactionRepository) GetItems(transactionID string) ([]models.TransactionItem, error) {
+	itemsQuery := `SELECT ` + transactionItemColumns + `
 	              FROM transaction_items WHERE transaction_id = $1 ORDER BY created_at DESC`
 	
-	rows, err := r.db.Query(itemsQuery, id)
+	rows, err := r.db.Query(itemsQuery, transactionID)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
+	var items []models.TransactionItem
 	for rows.Next() {
 		var item models.TransactionItem
 		err := rows.Scan(
@@ -98,8 +148,8 @@ func (r *TransactionRepository) GetByIDWithItems(id string) (*models.Transaction
 		if err != nil {
 			return nil, err
 		}
-		t.Items = append(t.Items, item)
+		items = append(items, item)
 	}
 
-	return &t, nil
-}
\ No newline at end of file
+	return items, rows.Err()
+}
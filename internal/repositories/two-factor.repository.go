@@ -0,0 +1,190 @@
+package repositories
+
+import (
+	"database/sql"
+	"time"
+
+	"pwa-backend/internal/ids"
+	"pwa-backend/internal/models"
+)
+
+// TwoFactorRepository stores TOTP enrolment, recovery codes, pending login
+// challenges and the per-role two-factor policy.
+type TwoFactorRepository struct {
+	db *sql.DB
+}
+
+func NewTwoFactorRepository(db *sql.DB) *TwoFactorRepository {
+	return &TwoFactorRepository{db: db}
+}
+
+func (r *TwoFactorRepository) BeginTx() (*sql.Tx, error) {
+	return r.db.Begin()
+}
+
+// SetPendingSecret starts enrolment. It does not touch an enabled secret.
+func (r *TwoFactorRepository) SetPendingSecret(userID, secret string) error {
+	query := `UPDATE users SET totp_secret = $1, totp_last_step = 0, updated_at = NOW()
+	          WHERE id = $2 AND totp_enabled_at IS NULL`
+	_, err := r.db.Exec(query, secret, userID)
+	return err
+}
+
+func (r *TwoFactorRepository) Enable(tx *sql.Tx, userID string, step int64) error {
+	query := `UPDATE users SET totp_enabled_at = NOW(), totp_last_step = $1, updated_at = NOW() WHERE id = $2`
+	_, err := tx.Exec(query, step, userID)
+	return err
+}
+
+// Disable removes the secret and every recovery code of a user.
+func (r *TwoFactorRepository) Disable(tx *sql.Tx, userID string) error {
+	query := `UPDATE users SET totp_secret = NULL, totp_enabled_at = NULL, totp_last_step = 0, updated_at = NOW()
+	          WHERE id = $1`
+	if _, err := tx.Exec(query, userID); err != nil {
+		return err
+	}
+
+	_, err := tx.Exec(`DELETE FROM recovery_codes WHERE user_id = $1`, userID)
+	return err
+}
+
+// ClaimStep records that the code for step was used. It returns false if that
+// step or a later one was already used, i.e. the code is being replayed.
+func (r *TwoFactorRepository) ClaimStep(userID string, step int64) (bool, error) {
+	query := `UPDATE users SET totp_last_step = $1 WHERE id = $2 AND totp_last_step < $1`
+	result, err := r.db.Exec(query, step, userID)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return affected == 1, nil
+}
+
+// ReplaceRecoveryCodes drops the old recovery codes of a user and stores the
+// hashes of new ones.
+func (r *TwoFactorRepository) ReplaceRecoveryCodes(tx *sql.Tx, userID string, hashes []string) error {
+	if _, err := tx.Exec(`DELETE FROM recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return err
+	}
+
+	query := `INSERT INTO recovery_codes (id, user_id, code_hash, created_at) VALUES ($1, $2, $3, NOW())`
+	for _, hash := range hashes {
+		if _, err := tx.Exec(query, ids.New(), userID, hash); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// UseRecoveryCode marks a recovery code as used. It returns false if the code
+// does not exist or was used before.
+func (r *TwoFactorRepository) UseRecoveryCode(userID, hash string) (bool, error) {
+	query := `UPDATE recovery_codes SET used_at = NOW() WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL`
+	result, err := r.db.Exec(query, userID, hash)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return affected == 1, nil
+}
+
+func (r *TwoFactorRepository) CreateChallenge(challenge *models.LoginChallenge) error {
+	query := `INSERT INTO login_challenges (id, user_id, device_id, user_agent, expires_at, created_at)
+	          VALUES ($1, $2, $3, $4, $5, $6)`
+
+	_, err := r.db.Exec(query, challenge.ID, challenge.UserID, challenge.DeviceID, challenge.UserAgent,
+		challenge.ExpiresAt, challenge.CreatedAt)
+	return err
+}
+
+// GetChallenge returns an unexpired challenge and counts an attempt against
+// it, or nil if there is none.
+func (r *TwoFactorRepository) GetChallenge(id string) (*models.LoginChallenge, error) {
+	var ch models.LoginChallenge
+	query := `UPDATE login_challenges SET attempts = attempts + 1
+	          WHERE id = $1 AND expires_at > $2
+	          RETURNING id, user_id, device_id, user_agent, attempts, expires_at, created_at`
+
+	err := r.db.QueryRow(query, id, time.Now()).Scan(
+		&ch.ID, &ch.UserID, &ch.DeviceID, &ch.UserAgent, &ch.Attempts, &ch.ExpiresAt, &ch.CreatedAt,
+	)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &ch, nil
+}
+
+// DeleteChallenge consumes a challenge. It returns false if it was already
+// consumed by a concurrent request.
+func (r *TwoFactorRepository) DeleteChallenge(id string) (bool, error) {
+	result, err := r.db.Exec(`DELETE FROM login_challenges WHERE id = $1`, id)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return affected == 1, nil
+}
+
+// DeleteExpiredChallenges removes challenges that can no longer be used.
+func (r *TwoFactorRepository) DeleteExpiredChallenges() error {
+	_, err := r.db.Exec(`DELETE FROM login_challenges WHERE expires_at <= $1`, time.Now())
+	return err
+}
+
+func (r *TwoFactorRepository) GetPolicies() ([]models.TwoFactorPolicy, error) {
+	rows, err := r.db.Query(`SELECT role, required, updated_at FROM two_factor_policies ORDER BY role`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var policies []models.TwoFactorPolicy
+	for rows.Next() {
+		var p models.TwoFactorPolicy
+		if err := rows.Scan(&p.Role, &p.Required, &p.UpdatedAt); err != nil {
+			return nil, err
+		}
+		policies = append(policies, p)
+	}
+
+	return policies, rows.Err()
+}
+
+func (r *TwoFactorRepository) SetPolicy(policy *models.TwoFactorPolicy) error {
+	query := `INSERT INTO two_factor_policies (role, required, updated_at) VALUES ($1, $2, $3)
+	          ON CONFLICT (role) DO UPDATE SET required = EXCLUDED.required, updated_at = EXCLUDED.updated_at`
+	_, err := r.db.Exec(query, policy.Role, policy.Required, policy.UpdatedAt)
+	return err
+}
+
+// IsRequired reports whether users with role must use two-factor
+// authentication.
+func (r *TwoFactorRepository) IsRequired(role string) (bool, error) {
+	var required bool
+	err := r.db.QueryRow(`SELECT required FROM two_factor_policies WHERE role = $1`, role).Scan(&required)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return required, err
+}
@@ -0,0 +1,69 @@
+package repositories
+
+import (
+	"testing"
+	"time"
+
+	"pwa-backend/internal/ids"
+	"pwa-backend/internal/testdb"
+	"pwa-backend/internal/totp"
+)
+
+// TestClaimStepRejectsReplay checks the totp_last_step guard: a code can be
+// used once, and neither it nor a code of an earlier step is accepted again.
+func TestClaimStepRejectsReplay(t *testing.T) {
+	db := testdb.Open(t)
+	repo := NewTwoFactorRepository(db)
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	userID := ids.New()
+	if _, err := db.Exec(`INSERT INTO users (id, username, password, name, role, totp_secret, totp_enabled_at) VALUES ($1, 'admin', 'x', 'Admin', 'admin', $2, NOW())`, userID, secret); err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	code, err := totp.Code(secret, totp.Step(now))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	claim := func(code string) bool {
+		t.Helper()
+		step, ok := totp.Validate(secret, code, now)
+		if !ok {
+			t.Fatalf("code %s is not valid", code)
+		}
+		claimed, err := repo.ClaimStep(userID, step)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return claimed
+	}
+
+	if !claim(code) {
+		t.Fatal("first use of the code was rejected")
+	}
+	if claim(code) {
+		t.Error("the same code was accepted twice")
+	}
+
+	earlier, err := totp.Code(secret, totp.Step(now)-1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claim(earlier) {
+		t.Error("a code of an earlier step was accepted after a later one")
+	}
+
+	later, err := totp.Code(secret, totp.Step(now)+1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !claim(later) {
+		t.Error("a code of a later step was rejected")
+	}
+}
@@ -15,20 +15,60 @@ func NewUserSessionRepository(db *sql.DB) *UserSessionRepository {
 	return &UserSessionRepository{db: db}
 }
 
+const userSessionColumns = `id, user_id, family_id, refresh_token, device_id, user_agent, auth_method, expires_at, used_at, replaced_by, revoked_at, created_at`
+
+func scanUserSession(row rowScanner, session *models.UserSession) error {
+	return row.Scan(
+		&session.ID,
+		&session.UserID,
+		&session.FamilyID,
+		&session.RefreshToken,
+		&session.DeviceID,
+		&session.UserAgent,
+		&session.AuthMethod,
+		&session.ExpiresAt,
+		&session.UsedAt,
+		&session.ReplacedBy,
+		&session.RevokedAt,
+		&session.CreatedAt,
+	)
+}
+
+func (r *UserSessionRepository) BeginTx() (*sql.Tx, error) {
+	return r.db.Begin()
+}
+
 func (r *UserSessionRepository) Create(session *models.UserSession) error {
+	return r.create(r.db, session)
+}
+
+func (r *UserSessionRepository) CreateTx(tx *sql.Tx, session *models.UserSession) error {
+	return r.create(tx, session)
+}
+
+func (r *UserSessionRepository) create(exec execer, session *models.UserSession) error {
+	if session.FamilyID == "" {
+		session.FamilyID = session.ID
+	}
+	if session.AuthMethod == "" {
+		session.AuthMethod = models.AuthMethodPassword
+	}
+
 	query := `
 		INSERT INTO user_sessions 
-		(id, user_id, refresh_token, device_id, user_agent, expires_at, created_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		(id, user_id, family_id, refresh_token, device_id, user_agent, auth_method, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
 	`
 	
-	_, err := r.db.Exec(
+	_, err := exec.Exec(
 		query,
 		session.ID,
 		session.UserID,
+		session.FamilyID,
 		session.RefreshToken,
 		session.DeviceID,
 		session.UserAgent,
+		session.AuthMethod,
 		session.ExpiresAt,
 		session.CreatedAt,
 	)
@@ -36,24 +76,13 @@ func (r *UserSessionRepository) Create(session *models.UserSession) error {
 	return err
 }
 
+// GetByRefreshToken returns the session for a refresh token, including used
+// and revoked ones, so callers can detect refresh token reuse.
 func (r *UserSessionRepository) GetByRefreshToken(refreshToken string) (*models.UserSession, error) {
 	var session models.UserSession
-	query := `
-		SELECT id, user_id, refresh_token, device_id, user_agent, expires_at, revoked_at, created_at
-		FROM user_sessions
-		WHERE refresh_token = $1 AND revoked_at IS NULL
-	`
+	query := `SELECT ` + userSessionColumns + ` FROM user_sessions WHERE refresh_token = $1`
 	
-	err := r.db.QueryRow(query, refreshToken).Scan(
-		&session.ID,
-		&session.UserID,
-		&session.RefreshToken,
-		&session.DeviceID,
-		&session.UserAgent,
-		&session.ExpiresAt,
-		&session.RevokedAt,
-		&session.CreatedAt,
-	)
+	err := scanUserSession(r.db.QueryRow(query, refreshToken), &session)
 	
 	if err == sql.ErrNoRows {
 		return nil, nil
@@ -65,14 +94,14 @@ func (r *UserSessionRepository) GetByRefreshToken(refreshToken string) (*models.
 	return &session, nil
 }
 
+// GetByUserID lists the active sessions of a user: the latest, unexpired row
+// of every session family that has not been revoked.
 func (r *UserSessionRepository) GetByUserID(userID string) ([]*models.UserSession, error) {
-	query := `
-		SELECT id, user_id, refresh_token, device_id, user_agent, expires_at, revoked_at, created_at
-		FROM user_sessions
-		WHERE user_id = $1 AND revoked_at IS NULL ORDER BY created_at DESC
-	`
+	query := `SELECT ` + userSessionColumns + ` FROM user_sessions
+		WHERE user_id = $1 AND revoked_at IS NULL AND used_at IS NULL AND expires_at > $2
+		ORDER BY created_at DESC`
 	
-	rows, err := r.db.Query(query, userID)
+	rows, err := r.db.Query(query, userID, time.Now())
 	if err != nil {
 		return nil, err
 	}
@@ -81,17 +110,7 @@ func (r *UserSessionRepository) GetByUserID(userID string) ([]*models.UserSessio
 	var sessions []*models.UserSession
 	for rows.Next() {
 		var session models.UserSession
-		err := rows.Scan(
-			&session.ID,
-			&session.UserID,
-			&session.RefreshToken,
-			&session.DeviceID,
-			&session.UserAgent,
-			&session.ExpiresAt,
-			&session.RevokedAt,
-			&session.CreatedAt,
-		)
-		if err != nil {
+		if err := scanUserSession(rows, &session); err != nil {
 			return nil, err
 		}
 		sessions = append(sessions, &session)
@@ -100,36 +119,110 @@ func (r *UserSessionRepository) GetByUserID(userID string) ([]*models.UserSessio
 	return sessions, rows.Err()
 }
 
+func (r *UserSessionRepository) GetByID(id string) (*models.UserSession, error) {
+	var session models.UserSession
+	query := `SELECT ` + userSessionColumns + ` FROM user_sessions WHERE id = $1`
+
+	err := scanUserSession(r.db.QueryRow(query, id), &session)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &session, nil
+}
+
+// MarkUsed flags a session's refresh token as consumed by a rotation. It
+// returns false when the session was already used or revoked, which means a
+// concurrent request rotated it first.
+func (r *UserSessionRepository) MarkUsed(tx *sql.Tx, sessionID, replacedBy string) (bool, error) {
+	query := `UPDATE user_sessions SET used_at = $1, replaced_by = $2
+	          WHERE id = $3 AND used_at IS NULL AND revoked_at IS NULL`
+	result, err := tx.Exec(query, time.Now(), replacedBy, sessionID)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return affected == 1, nil
+}
+
 func (r *UserSessionRepository) RevokeByID(sessionID string) error {
 	query := `UPDATE user_sessions SET revoked_at = $1 WHERE id = $2`
 	_, err := r.db.Exec(query, time.Now(), sessionID)
 	return err
 }
 
+// RevokeFamily revokes every session created from the same login.
+func (r *UserSessionRepository) RevokeFamily(familyID string) error {
+	query := `UPDATE user_sessions SET revoked_at = $1 WHERE family_id = $2 AND revoked_at IS NULL`
+	_, err := r.db.Exec(query, time.Now(), familyID)
+	return err
+}
+
 func (r *UserSessionRepository) RevokeAllByUserID(userID string) error {
 	query := `UPDATE user_sessions SET revoked_at = $1 WHERE user_id = $2 AND revoked_at IS NULL`
 	_, err := r.db.Exec(query, time.Now(), userID)
 	return err
 }
 
-func (r *UserSessionRepository) IsSessionValid(sessionID string) (bool, error) {
-	var expiresAt time.Time
-	var revokedAt *time.Time
-	
-	query := `
-		SELECT expires_at, revoked_at FROM user_sessions WHERE id = $1
-	`
-	
-	err := r.db.QueryRow(query, sessionID).Scan(&expiresAt, &revokedAt)
-	
+// RevokeOthersByUserID revokes every session of a user except the ones in
+// keepFamilyID, i.e. "log out everywhere else".
+func (r *UserSessionRepository) RevokeOthersByUserID(userID, keepFamilyID string) error {
+	return r.revokeOthers(r.db, userID, keepFamilyID)
+}
+
+func (r *UserSessionRepository) RevokeOthersByUserIDTx(tx *sql.Tx, userID, keepFamilyID string) error {
+	return r.revokeOthers(tx, userID, keepFamilyID)
+}
+
+func (r *UserSessionRepository) revokeOthers(exec execer, userID, keepFamilyID string) error {
+	query := `UPDATE user_sessions SET revoked_at = $1
+	          WHERE user_id = $2 AND family_id <> $3 AND revoked_at IS NULL`
+	_, err := exec.Exec(query, time.Now(), userID, keepFamilyID)
+	return err
+}
+
+// RevokeAllByDeviceID revokes every session bound to a device, e.g. when the
+// device is deactivated.
+func (r *UserSessionRepository) RevokeAllByDeviceID(tx *sql.Tx, deviceID string) error {
+	query := `UPDATE user_sessions SET revoked_at = $1 WHERE device_id = $2 AND revoked_at IS NULL`
+	_, err := tx.Exec(query, time.Now(), deviceID)
+	return err
+}
+
+// GetActive returns a session that is neither revoked nor expired and whose
+// user is not disabled, or nil.
+func (r *UserSessionRepository) GetActive(sessionID string) (*models.UserSession, error) {
+	var session models.UserSession
+	query := `SELECT ` + userSessionColumns + ` FROM user_sessions
+		WHERE id = $1 AND revoked_at IS NULL AND expires_at > $2
+		AND NOT EXISTS (SELECT 1 FROM users WHERE users.id = user_sessions.user_id AND users.disabled_at IS NOT NULL)`
+
+	err := scanUserSession(r.db.QueryRow(query, sessionID, time.Now()), &session)
+
 	if err == sql.ErrNoRows {
-		return false, nil
+		return nil, nil
 	}
 	if err != nil {
-		return false, err
+		return nil, err
 	}
-	
-	// Session is valid if it's not revoked and not expired
-	isValid := revokedAt == nil && time.Now().Before(expiresAt)
-	return isValid, nil
+
+	return &session, nil
+}
+
+// RevokeByAuthMethod revokes a user's sessions that were started with the
+// given auth method, e.g. every PIN session once the PIN is removed.
+func (r *UserSessionRepository) RevokeByAuthMethod(userID, authMethod string) error {
+	query := `UPDATE user_sessions SET revoked_at = $1
+	          WHERE user_id = $2 AND auth_method = $3 AND revoked_at IS NULL`
+	_, err := r.db.Exec(query, time.Now(), userID, authMethod)
+	return err
 }
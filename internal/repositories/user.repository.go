@@ -2,6 +2,11 @@ package repositories
 
 import (
 	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"pwa-backend/internal/ids"
 	"pwa-backend/internal/models"
 )
 
@@ -13,15 +18,24 @@ func NewUserRepository(db *sql.DB) *UserRepository {
 	return &UserRepository{db: db}
 }
 
+const userColumns = `id, username, password, name, role, store_id, must_change_password, password_changed_at,
+	totp_secret, totp_last_step, totp_enabled_at, pin_hash, pin_set_at, disabled_at, created_at, updated_at`
+
+func scanUser(row rowScanner, user *models.User) error {
+	return row.Scan(
+		&user.ID, &user.Username, &user.Password, &user.Name, &user.Role, &user.StoreID,
+		&user.MustChangePassword, &user.PasswordChangedAt,
+		&user.TOTPSecret, &user.TOTPLastStep, &user.TwoFactorEnabledAt,
+		&user.PINHash, &user.PINSetAt, &user.DisabledAt,
+		&user.CreatedAt, &user.UpdatedAt,
+	)
+}
+
 func (r *UserRepository) GetByUsername(username string) (*models.User, error) {
 	var user models.User
-	query := `SELECT id, username, password, name, role, created_at, updated_at 
-	          FROM users WHERE username = $1`
+	query := `SELECT ` + userColumns + ` FROM users WHERE username = $1`
 	
-	err := r.db.QueryRow(query, username).Scan(
-		&user.ID, &user.Username, &user.Password, &user.Name, 
-		&user.Role, &user.CreatedAt, &user.UpdatedAt,
-	)
+	err := scanUser(r.db.QueryRow(query, username), &user)
 	
 	if err == sql.ErrNoRows {
 		return nil, nil
@@ -35,13 +49,9 @@ func (r *UserRepository) GetByUsername(username string) (*models.User, error) {
 
 func (r *UserRepository) GetByID(id string) (*models.User, error) {
 	var user models.User
-	query := `SELECT id, username, password, name, role, created_at, updated_at 
-	          FROM users WHERE id = $1`
+	query := `SELECT ` + userColumns + ` FROM users WHERE id = $1`
 	
-	err := r.db.QueryRow(query, id).Scan(
-		&user.ID, &user.Username, &user.Password, &user.Name, 
-		&user.Role, &user.CreatedAt, &user.UpdatedAt,
-	)
+	err := scanUser(r.db.QueryRow(query, id), &user)
 	
 	if err == sql.ErrNoRows {
 		return nil, nil
@@ -54,12 +64,149 @@ func (r *UserRepository) GetByID(id string) (*models.User, error) {
 }
 
 func (r *UserRepository) Create(user *models.User) error {
-	query := `INSERT INTO users (id, username, password, name, role, created_at, updated_at) 
-	          VALUES ($1, $2, $3, $4, $5, $6, $7)`
+	return r.create(r.db, user)
+}
+
+func (r *UserRepository) CreateTx(tx *sql.Tx, user *models.User) error {
+	return r.create(tx, user)
+}
+
+func (r *UserRepository) create(exec execer, user *models.User) error {
+	query := `INSERT INTO users (id, username, password, name, role, store_id, must_change_password, created_at, updated_at) 
+	          VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`
 	
-	_, err := r.db.Exec(query, user.ID, user.Username, user.Password, user.Name, 
-		user.Role, user.CreatedAt, user.UpdatedAt)
+	_, err := exec.Exec(query, user.ID, user.Username, user.Password, user.Name, 
+		user.Role, user.StoreID, user.MustChangePassword, user.CreatedAt, user.UpdatedAt)
 	
 	return err
 }
 
+// List returns the users matching filter ordered by username, and the total
+// number of matches ignoring Limit and Offset.
+func (r *UserRepository) List(filter models.UserFilter) ([]models.User, int, error) {
+	var conditions []string
+	var args []interface{}
+
+	if filter.Search != "" {
+		args = append(args, "%"+escapeLike(filter.Search)+"%")
+		conditions = append(conditions, fmt.Sprintf("(username ILIKE $%d OR name ILIKE $%d)", len(args), len(args)))
+	}
+	if filter.Role != "" {
+		args = append(args, filter.Role)
+		conditions = append(conditions, fmt.Sprintf("role = $%d", len(args)))
+	}
+	if !filter.IncludeDisabled {
+		conditions = append(conditions, "disabled_at IS NULL")
+	}
+
+	where := ""
+	if len(conditions) > 0 {
+		where = ` WHERE ` + strings.Join(conditions, " AND ")
+	}
+
+	var total int
+	if err := r.db.QueryRow(`SELECT COUNT(*) FROM users`+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	args = append(args, filter.Limit, filter.Offset)
+	query := `SELECT ` + userColumns + ` FROM users` + where +
+		fmt.Sprintf(` ORDER BY username LIMIT $%d OFFSET $%d`, len(args)-1, len(args))
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	users := []models.User{}
+	for rows.Next() {
+		var user models.User
+		if err := scanUser(rows, &user); err != nil {
+			return nil, 0, err
+		}
+		users = append(users, user)
+	}
+
+	return users, total, rows.Err()
+}
+
+// Update saves a user's name, role and disabled state.
+func (r *UserRepository) Update(user *models.User) error {
+	query := `UPDATE users SET name = $1, role = $2, store_id = $3, disabled_at = $4, updated_at = $5 WHERE id = $6`
+
+	user.UpdatedAt = time.Now()
+	_, err := r.db.Exec(query, user.Name, user.Role, user.StoreID, user.DisabledAt, user.UpdatedAt, user.ID)
+	return err
+}
+
+// Delete removes a user. It fails with a foreign key violation while the user
+// still has transactions.
+func (r *UserRepository) Delete(id string) error {
+	_, err := r.db.Exec(`DELETE FROM users WHERE id = $1`, id)
+	return err
+}
+
+// escapeLike escapes the LIKE wildcards in s so it matches literally.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+func (r *UserRepository) BeginTx() (*sql.Tx, error) {
+	return r.db.Begin()
+}
+
+// UpdatePassword replaces a user's password hash and moves the old one into
+// password_history. mustChange forces another change at next login.
+func (r *UserRepository) UpdatePassword(tx *sql.Tx, user *models.User, newHash string, mustChange bool) error {
+	now := time.Now()
+
+	query := `INSERT INTO password_history (id, user_id, password_hash, created_at) VALUES ($1, $2, $3, $4)`
+	if _, err := tx.Exec(query, ids.New(), user.ID, user.Password, now); err != nil {
+		return err
+	}
+
+	query = `UPDATE users SET password = $1, must_change_password = $2, password_changed_at = $3, updated_at = $3
+	         WHERE id = $4`
+	if _, err := tx.Exec(query, newHash, mustChange, now, user.ID); err != nil {
+		return err
+	}
+
+	user.Password = newHash
+	user.MustChangePassword = mustChange
+	user.PasswordChangedAt = &now
+	user.UpdatedAt = now
+	return nil
+}
+
+// GetPasswordHistory returns up to limit previous password hashes of a user,
+// newest first.
+func (r *UserRepository) GetPasswordHistory(userID string, limit int) ([]string, error) {
+	query := `SELECT password_hash FROM password_history WHERE user_id = $1 ORDER BY created_at DESC LIMIT $2`
+
+	rows, err := r.db.Query(query, userID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var hashes []string
+	for rows.Next() {
+		var hash string
+		if err := rows.Scan(&hash); err != nil {
+			return nil, err
+		}
+		hashes = append(hashes, hash)
+	}
+
+	return hashes, rows.Err()
+}
+
+// SetPIN stores the bcrypt hash of a user's login PIN, or removes the PIN
+// when hash is nil.
+func (r *UserRepository) SetPIN(userID string, hash *string) error {
+	query := `UPDATE users SET pin_hash = $1, pin_set_at = CASE WHEN $1::varchar IS NULL THEN NULL ELSE NOW() END,
+	          updated_at = NOW() WHERE id = $2`
+	_, err := r.db.Exec(query, hash, userID)
+	return err
+}
@@ -0,0 +1,197 @@
+// Package syncrules is the single definition of what PowerSync replicates to
+// each device. The same definition renders the sync rules YAML deployed to
+// PowerSync and decides which parameter claims go into access tokens, so a
+// bucket and the claims it depends on cannot drift apart.
+package syncrules
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ClaimSubject is the JWT claim PowerSync exposes as request.user_id().
+const ClaimSubject = "sub"
+
+type Parameter struct {
+	// Name is how data queries refer to the value, as bucket.<Name>.
+	Name string
+	// Claim is the access token claim the value is read from.
+	Claim string
+}
+
+type Bucket struct {
+	Name       string
+	Parameters []Parameter
+	Data       []string
+}
+
+type Rules struct {
+	Buckets []Bucket
+}
+
+// Subject is what the backend knows about the caller when it issues a token.
+type Subject struct {
+	UserID   string
+	DeviceID string
+	StoreID  string
+}
+
+// claimValue maps a claim name to the subject field that fills it. Every claim
+// used by a bucket parameter must be handled here.
+func (s Subject) claimValue(claim string) (string, bool) {
+	switch claim {
+	case ClaimSubject:
+		return s.UserID, true
+	case "device_id":
+		return s.DeviceID, true
+	case "store_id":
+		return s.StoreID, true
+	}
+	return "", false
+}
+
+// Default is the sync configuration for the POS clients.
+var Default = Rules{
+	Buckets: []Bucket{
+		{
+			Name: "global_products",
+			Data: []string{
+				"SELECT * FROM products WHERE archived_at IS NULL",
+			},
+		},
+		{
+			Name:       "user_transactions",
+			Parameters: []Parameter{{Name: "user_id", Claim: ClaimSubject}},
+			Data: []string{
+				"SELECT * FROM transactions WHERE user_id = bucket.user_id",
+				"SELECT * FROM transaction_items WHERE user_id = bucket.user_id",
+			},
+		},
+		{
+			Name:       "device_stock_events",
+			Parameters: []Parameter{{Name: "device_id", Claim: "device_id"}},
+			Data: []string{
+				"SELECT * FROM stock_events WHERE device_id = bucket.device_id",
+				"SELECT * FROM stocktake_counts WHERE device_id = bucket.device_id",
+			},
+		},
+		{
+			Name:       "store_stock",
+			Parameters: []Parameter{{Name: "store_id", Claim: "store_id"}},
+			Data: []string{
+				"SELECT store_id || ':' || product_id AS id, * FROM product_stocks WHERE store_id = bucket.store_id",
+				"SELECT * FROM locations WHERE store_id = bucket.store_id",
+			},
+		},
+		{
+			Name:       "store_stocktakes",
+			Parameters: []Parameter{{Name: "store_id", Claim: "store_id"}},
+			Data: []string{
+				"SELECT * FROM stocktakes WHERE store_id = bucket.store_id AND status = 'open'",
+				"SELECT stocktake_id || ':' || product_id AS id, * FROM stocktake_items WHERE store_id = bucket.store_id AND status = 'open'",
+			},
+		},
+	},
+}
+
+// Validate checks that every parameter claim can be filled from a Subject and
+// every bucket parameter is actually used by a query.
+func (r Rules) Validate() error {
+	seen := make(map[string]bool)
+	for _, bucket := range r.Buckets {
+		if bucket.Name == "" || seen[bucket.Name] {
+			return fmt.Errorf("bucket name %q is empty or duplicated", bucket.Name)
+		}
+		seen[bucket.Name] = true
+
+		if len(bucket.Data) == 0 {
+			return fmt.Errorf("bucket %s has no data queries", bucket.Name)
+		}
+
+		for _, param := range bucket.Parameters {
+			if _, ok := (Subject{}).claimValue(param.Claim); !ok {
+				return fmt.Errorf("bucket %s: claim %q has no source in Subject", bucket.Name, param.Claim)
+			}
+
+			used := false
+			for _, query := range bucket.Data {
+				if strings.Contains(query, "bucket."+param.Name) {
+					used = true
+				}
+			}
+			if !used {
+				return fmt.Errorf("bucket %s: parameter %s is not used by any query", bucket.Name, param.Name)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Claims returns the custom claims PowerSync needs to resolve the bucket
+// parameters for subject. The sub claim is set by the token issuer itself.
+func (r Rules) Claims(subject Subject) map[string]interface{} {
+	claims := make(map[string]interface{})
+	for _, bucket := range r.Buckets {
+		for _, param := range bucket.Parameters {
+			if param.Claim == ClaimSubject {
+				continue
+			}
+
+			value, _ := subject.claimValue(param.Claim)
+			if value == "" {
+				claims[param.Claim] = nil
+			} else {
+				claims[param.Claim] = value
+			}
+		}
+	}
+	return claims
+}
+
+// YAML renders the rules in the format PowerSync expects for sync-rules.yaml.
+func (r Rules) YAML() string {
+	var b strings.Builder
+	b.WriteString("# Generated from internal/syncrules. Do not edit by hand.\n")
+	if names := r.ClaimNames(); len(names) > 0 {
+		fmt.Fprintf(&b, "# Access token claims used by parameters: %s\n", strings.Join(names, ", "))
+	}
+	b.WriteString("bucket_definitions:\n")
+
+	for _, bucket := range r.Buckets {
+		fmt.Fprintf(&b, "  %s:\n", bucket.Name)
+
+		if len(bucket.Parameters) > 0 {
+			columns := make([]string, 0, len(bucket.Parameters))
+			for _, param := range bucket.Parameters {
+				columns = append(columns, parameterExpression(param)+" AS "+param.Name)
+			}
+			fmt.Fprintf(&b, "    parameters: SELECT %s\n", strings.Join(columns, ", "))
+		}
+
+		b.WriteString("    data:\n")
+		for _, query := range bucket.Data {
+			fmt.Fprintf(&b, "      - %s\n", query)
+		}
+	}
+
+	return b.String()
+}
+
+// ClaimNames lists the custom claims the rules put into tokens, sorted.
+func (r Rules) ClaimNames() []string {
+	var names []string
+	for name := range r.Claims(Subject{}) {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func parameterExpression(param Parameter) string {
+	if param.Claim == ClaimSubject {
+		return "request.user_id()"
+	}
+	return fmt.Sprintf("request.jwt() ->> '%s'", param.Claim)
+}
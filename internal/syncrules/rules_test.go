@@ -0,0 +1,89 @@
+package syncrules
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDefaultIsValid(t *testing.T) {
+	if err := Default.Validate(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		buckets []Bucket
+	}{
+		{"empty name", []Bucket{{Data: []string{"SELECT * FROM products"}}}},
+		{"duplicate name", []Bucket{
+			{Name: "products", Data: []string{"SELECT * FROM products"}},
+			{Name: "products", Data: []string{"SELECT * FROM products"}},
+		}},
+		{"no data", []Bucket{{Name: "products"}}},
+		{"unknown claim", []Bucket{{
+			Name:       "by_role",
+			Parameters: []Parameter{{Name: "role", Claim: "role"}},
+			Data:       []string{"SELECT * FROM users WHERE role = bucket.role"},
+		}}},
+		{"unused parameter", []Bucket{{
+			Name:       "by_store",
+			Parameters: []Parameter{{Name: "store_id", Claim: "store_id"}},
+			Data:       []string{"SELECT * FROM locations"},
+		}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := (Rules{Buckets: tt.buckets}).Validate(); err == nil {
+				t.Error("Validate accepted an invalid definition")
+			}
+		})
+	}
+}
+
+func TestClaims(t *testing.T) {
+	got := Default.Claims(Subject{UserID: "user-1", StoreID: "store-1"})
+	want := map[string]interface{}{
+		"device_id": nil,
+		"store_id":  "store-1",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Claims = %v, want %v", got, want)
+	}
+
+	if names := Default.ClaimNames(); !reflect.DeepEqual(names, []string{"device_id", "store_id"}) {
+		t.Errorf("ClaimNames = %v", names)
+	}
+}
+
+func TestYAML(t *testing.T) {
+	yaml := Default.YAML()
+
+	for _, want := range []string{
+		"# Access token claims used by parameters: device_id, store_id\n",
+		"bucket_definitions:\n",
+		"  global_products:\n    data:\n      - SELECT * FROM products WHERE archived_at IS NULL\n",
+		"  user_transactions:\n    parameters: SELECT request.user_id() AS user_id\n",
+		"  device_stock_events:\n    parameters: SELECT request.jwt() ->> 'device_id' AS device_id\n",
+		"  store_stock:\n    parameters: SELECT request.jwt() ->> 'store_id' AS store_id\n",
+		"      - SELECT * FROM stocktakes WHERE store_id = bucket.store_id AND status = 'open'\n",
+		"      - SELECT stocktake_id || ':' || product_id AS id, * FROM stocktake_items WHERE store_id = bucket.store_id AND status = 'open'\n",
+	} {
+		if !strings.Contains(yaml, want) {
+			t.Errorf("YAML is missing %q", want)
+		}
+	}
+
+	buckets := 0
+	for _, line := range strings.Split(yaml, "\n") {
+		if strings.HasPrefix(line, "  ") && !strings.HasPrefix(line, "   ") {
+			buckets++
+		}
+	}
+	if buckets != len(Default.Buckets) {
+		t.Errorf("YAML has %d buckets, want %d:\n%s", buckets, len(Default.Buckets), yaml)
+	}
+}
@@ -0,0 +1,91 @@
+// Package testdb gives tests a throwaway PostgreSQL database with every
+// migration applied.
+package testdb
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"testing"
+
+	_ "github.com/lib/pq"
+)
+
+// lockKey is the advisory lock that keeps test binaries of different
+// packages, which go test runs in parallel, from resetting the schema under
+// each other.
+const lockKey = 1
+
+// Open connects to TEST_DATABASE_URL and applies every migration to a fresh
+// public schema, holding the database for the rest of the test. The public
+// schema is dropped first, so Open refuses any database whose name does not
+// contain "test". The test is skipped when the variable is unset.
+func Open(t *testing.T) *sql.DB {
+	t.Helper()
+
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+
+	db, err := sql.Open("postgres", url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	var name string
+	if err := db.QueryRow(`SELECT current_database()`).Scan(&name); err != nil {
+		t.Fatalf("connect to test database: %v", err)
+	}
+	if !strings.Contains(strings.ToLower(name), "test") {
+		t.Fatalf("refusing to reset database %q: TEST_DATABASE_URL must name a throwaway database with \"test\" in its name", name)
+	}
+
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, lockKey); err != nil {
+		t.Fatalf("lock test database: %v", err)
+	}
+	// Registered after db.Close, so it runs first.
+	t.Cleanup(func() {
+		conn.ExecContext(ctx, `SELECT pg_advisory_unlock($1)`, lockKey)
+		conn.Close()
+	})
+
+	if _, err := db.Exec(`DROP SCHEMA IF EXISTS public CASCADE`); err != nil {
+		t.Fatalf("reset schema: %v", err)
+	}
+
+	files, err := filepath.Glob(filepath.Join(migrationsDir(), "*.sql"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(files)
+
+	for _, file := range files {
+		migration, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := db.Exec(string(migration)); err != nil {
+			t.Fatalf("apply %s: %v", filepath.Base(file), err)
+		}
+	}
+
+	return db
+}
+
+// migrationsDir finds the migrations next to this package, wherever the test
+// binary runs from.
+func migrationsDir() string {
+	_, file, _, _ := runtime.Caller(0)
+	return filepath.Join(filepath.Dir(file), "..", "..", "migrations")
+}
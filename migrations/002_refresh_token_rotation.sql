@@ -0,0 +1,14 @@
+-- Refresh token rotation: every refresh creates a new session row in the same
+-- family and marks the previous one as used. Presenting a used token again
+-- revokes the whole family.
+ALTER TABLE "user_sessions" ADD COLUMN "family_id" varchar(36);
+ALTER TABLE "user_sessions" ADD COLUMN "used_at" timestamp;
+ALTER TABLE "user_sessions" ADD COLUMN "replaced_by" varchar(36);
+
+UPDATE "user_sessions" SET "family_id" = "id" WHERE "family_id" IS NULL;
+
+ALTER TABLE "user_sessions" ALTER COLUMN "family_id" SET NOT NULL;
+
+DROP INDEX IF EXISTS "idx_user_sessions_refresh_token";
+CREATE UNIQUE INDEX "idx_user_sessions_refresh_token" ON "user_sessions" ("refresh_token");
+CREATE INDEX "idx_user_sessions_family_id" ON "user_sessions" ("family_id");
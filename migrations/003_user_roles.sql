@@ -0,0 +1,6 @@
+-- Roles are enforced by the RBAC permission matrix (admin, manager, cashier,
+-- staff). Accounts created with the old 'user' default become staff.
+UPDATE "users" SET "role" = 'staff' WHERE "role" NOT IN ('admin', 'manager', 'cashier', 'staff');
+
+ALTER TABLE "users" ALTER COLUMN "role" SET DEFAULT 'staff';
+ALTER TABLE "users" ADD CONSTRAINT "users_role_check" CHECK (role IN ('admin', 'manager', 'cashier', 'staff'));
@@ -0,0 +1,6 @@
+-- Products are never hard-deleted because transaction_items references them
+-- with ON DELETE RESTRICT. Archived products are hidden from the catalog and
+-- cannot be sold.
+ALTER TABLE "products" ADD COLUMN "archived_at" timestamp;
+
+CREATE INDEX "idx_products_archived_at" ON "products" ("archived_at");
@@ -0,0 +1,18 @@
+-- Stores the first successful response for each Idempotency-Key so retried
+-- uploads from offline clients replay it instead of creating duplicates.
+-- A key is kept until expires_at (OFFLINE_WINDOW after its first use); after
+-- that it can be reused and the row is pruned by the API.
+CREATE TABLE "idempotency_keys" (
+	"user_id" varchar(36) NOT NULL,
+	"key" varchar(255) NOT NULL,
+	"endpoint" varchar(100) NOT NULL,
+	"request_hash" char(64) NOT NULL,
+	"response_status" integer,
+	"response_body" jsonb,
+	"created_at" timestamp DEFAULT now() NOT NULL,
+	"expires_at" timestamp NOT NULL,
+	CONSTRAINT "idempotency_keys_pkey" PRIMARY KEY ("user_id", "key"),
+	CONSTRAINT "fk_idempotency_keys_user" FOREIGN KEY ("user_id") REFERENCES "users"("id") ON DELETE CASCADE
+);
+
+CREATE INDEX "idx_idempotency_keys_expires_at" ON "idempotency_keys" ("expires_at");
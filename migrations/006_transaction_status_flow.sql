@@ -0,0 +1,9 @@
+-- Transaction statuses follow a fixed state machine (see
+-- models.CanTransitionTransaction). Cancelling a sale writes compensating
+-- 'cancellation' stock events linked by transaction_id.
+ALTER TABLE "transactions" ADD CONSTRAINT "transactions_status_check"
+	CHECK (status IN ('pending', 'completed', 'cancelled', 'refunded'));
+
+ALTER TABLE "stock_events" DROP CONSTRAINT "stock_events_type_check";
+ALTER TABLE "stock_events" ADD CONSTRAINT "stock_events_type_check"
+	CHECK (type IN ('sale', 'restock', 'reject', 'adjustment', 'opening_stock', 'cancellation'));
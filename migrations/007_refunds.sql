@@ -0,0 +1,38 @@
+-- Partial refunds and product returns. A refund document references the
+-- transaction_items being returned; restocked quantities are written as
+-- 'return' stock events linked by transaction_id.
+ALTER TABLE "transactions" ADD COLUMN "refunded_amount" numeric(10, 2) DEFAULT 0 NOT NULL;
+
+CREATE TABLE "refunds" (
+	"id" varchar(36) PRIMARY KEY,
+	"transaction_id" varchar(36) NOT NULL,
+	"user_id" varchar(36),
+	"reason" text,
+	"total_amount" numeric(10, 2) NOT NULL,
+	"created_at" timestamp DEFAULT now() NOT NULL,
+	CONSTRAINT "fk_refunds_transaction" FOREIGN KEY ("transaction_id") REFERENCES "transactions"("id") ON DELETE CASCADE,
+	CONSTRAINT "fk_refunds_user" FOREIGN KEY ("user_id") REFERENCES "users"("id") ON DELETE SET NULL
+);
+
+CREATE TABLE "refund_items" (
+	"id" varchar(36) PRIMARY KEY,
+	"refund_id" varchar(36) NOT NULL,
+	"transaction_item_id" varchar(36) NOT NULL,
+	"product_id" varchar(36) NOT NULL,
+	"quantity" integer NOT NULL,
+	"amount" numeric(10, 2) NOT NULL,
+	"restock" boolean DEFAULT false NOT NULL,
+	"created_at" timestamp DEFAULT now() NOT NULL,
+	CONSTRAINT "fk_refund_items_refund" FOREIGN KEY ("refund_id") REFERENCES "refunds"("id") ON DELETE CASCADE,
+	CONSTRAINT "fk_refund_items_transaction_item" FOREIGN KEY ("transaction_item_id") REFERENCES "transaction_items"("id") ON DELETE RESTRICT,
+	CONSTRAINT "fk_refund_items_product" FOREIGN KEY ("product_id") REFERENCES "products"("id") ON DELETE RESTRICT,
+	CONSTRAINT "refund_items_quantity_check" CHECK (quantity > 0)
+);
+
+CREATE INDEX "idx_refunds_transaction_id" ON "refunds" ("transaction_id");
+CREATE INDEX "idx_refund_items_refund_id" ON "refund_items" ("refund_id");
+CREATE INDEX "idx_refund_items_transaction_item_id" ON "refund_items" ("transaction_item_id");
+
+ALTER TABLE "stock_events" DROP CONSTRAINT "stock_events_type_check";
+ALTER TABLE "stock_events" ADD CONSTRAINT "stock_events_type_check"
+	CHECK (type IN ('sale', 'restock', 'reject', 'adjustment', 'opening_stock', 'cancellation', 'return'));
@@ -0,0 +1,29 @@
+-- Registered devices (POS tills, tablets, dashboard browsers). Sessions are
+-- bound to a device, and the device of the session is stamped on the stock
+-- events and transactions it creates.
+CREATE TABLE "devices" (
+	"id" varchar(100) PRIMARY KEY,
+	"name" varchar(100) NOT NULL,
+	"store_id" varchar(36),
+	"registered_by" varchar(36),
+	"last_seen_at" timestamp,
+	"deactivated_at" timestamp,
+	"created_at" timestamp DEFAULT now() NOT NULL,
+	"updated_at" timestamp DEFAULT now() NOT NULL,
+	CONSTRAINT "fk_devices_registered_by" FOREIGN KEY ("registered_by") REFERENCES "users"("id") ON DELETE SET NULL
+);
+
+CREATE INDEX "idx_devices_store_id" ON "devices" ("store_id");
+
+-- Device ids on existing sessions were never checked, so drop any that do not
+-- belong to a registered device before adding the foreign key. Historical
+-- stock_events keep their client-supplied device_id as is.
+UPDATE "user_sessions" SET "device_id" = NULL
+	WHERE "device_id" IS NOT NULL AND "device_id" NOT IN (SELECT "id" FROM "devices");
+
+ALTER TABLE "user_sessions" ADD CONSTRAINT "fk_user_sessions_device"
+	FOREIGN KEY ("device_id") REFERENCES "devices"("id") ON DELETE SET NULL;
+CREATE INDEX "idx_user_sessions_device_id" ON "user_sessions" ("device_id");
+
+ALTER TABLE "transactions" ADD COLUMN "device_id" varchar(100);
+CREATE INDEX "idx_transactions_device_id" ON "transactions" ("device_id");
@@ -0,0 +1,13 @@
+-- Failed login counters for the Postgres login limiter store, shared by all
+-- API instances. kind is 'username' or 'ip'.
+CREATE TABLE "login_attempts" (
+	"kind" varchar(20) NOT NULL,
+	"value" varchar(100) NOT NULL,
+	"failures" integer DEFAULT 0 NOT NULL,
+	"last_failure_at" timestamp NOT NULL,
+	"blocked_until" timestamp,
+	"locked" boolean DEFAULT false NOT NULL,
+	PRIMARY KEY ("kind", "value")
+);
+
+CREATE INDEX "idx_login_attempts_locked" ON "login_attempts" ("blocked_until") WHERE "locked";
@@ -0,0 +1,14 @@
+-- Password changes: admins can force a change at next login, and previous
+-- password hashes are kept so recent passwords cannot be reused.
+ALTER TABLE "users" ADD COLUMN "must_change_password" boolean DEFAULT false NOT NULL;
+ALTER TABLE "users" ADD COLUMN "password_changed_at" timestamp;
+
+CREATE TABLE "password_history" (
+	"id" varchar(36) PRIMARY KEY,
+	"user_id" varchar(36) NOT NULL,
+	"password_hash" varchar(255) NOT NULL,
+	"created_at" timestamp DEFAULT now() NOT NULL,
+	CONSTRAINT "fk_password_history_user" FOREIGN KEY ("user_id") REFERENCES "users"("id") ON DELETE CASCADE
+);
+
+CREATE INDEX "idx_password_history_user_created" ON "password_history" ("user_id", "created_at");
@@ -0,0 +1,39 @@
+-- TOTP two-factor authentication. totp_secret is set when enrolment starts and
+-- only counts once totp_enabled_at is set by a confirmed code. totp_last_step
+-- stops a code from being used twice.
+ALTER TABLE "users" ADD COLUMN "totp_secret" varchar(64);
+ALTER TABLE "users" ADD COLUMN "totp_enabled_at" timestamp;
+ALTER TABLE "users" ADD COLUMN "totp_last_step" bigint DEFAULT 0 NOT NULL;
+
+CREATE TABLE "recovery_codes" (
+	"id" varchar(36) PRIMARY KEY,
+	"user_id" varchar(36) NOT NULL,
+	"code_hash" varchar(64) NOT NULL,
+	"used_at" timestamp,
+	"created_at" timestamp DEFAULT now() NOT NULL,
+	CONSTRAINT "fk_recovery_codes_user" FOREIGN KEY ("user_id") REFERENCES "users"("id") ON DELETE CASCADE
+);
+
+CREATE INDEX "idx_recovery_codes_user_id" ON "recovery_codes" ("user_id");
+
+-- Pending second login step: issued after the password check, exchanged for a
+-- session together with a TOTP or recovery code.
+CREATE TABLE "login_challenges" (
+	"id" varchar(64) PRIMARY KEY,
+	"user_id" varchar(36) NOT NULL,
+	"device_id" varchar(100),
+	"user_agent" text,
+	"attempts" integer DEFAULT 0 NOT NULL,
+	"expires_at" timestamp NOT NULL,
+	"created_at" timestamp DEFAULT now() NOT NULL,
+	CONSTRAINT "fk_login_challenges_user" FOREIGN KEY ("user_id") REFERENCES "users"("id") ON DELETE CASCADE
+);
+
+CREATE INDEX "idx_login_challenges_expires_at" ON "login_challenges" ("expires_at");
+
+-- Roles whose users must enrol in two-factor authentication.
+CREATE TABLE "two_factor_policies" (
+	"role" varchar(20) PRIMARY KEY,
+	"required" boolean DEFAULT false NOT NULL,
+	"updated_at" timestamp DEFAULT now() NOT NULL
+);
@@ -0,0 +1,8 @@
+-- Quick PIN login for cashiers on a device registered to a store. PIN
+-- sessions are short-lived and get a restricted token scope.
+ALTER TABLE "users" ADD COLUMN "pin_hash" varchar(255);
+ALTER TABLE "users" ADD COLUMN "pin_set_at" timestamp;
+
+ALTER TABLE "user_sessions" ADD COLUMN "auth_method" varchar(20) DEFAULT 'password' NOT NULL;
+ALTER TABLE "user_sessions" ADD CONSTRAINT "user_sessions_auth_method_check"
+	CHECK (auth_method IN ('password', 'pin'));
@@ -0,0 +1,11 @@
+-- Admin user management. Disabled users cannot login and their sessions stop
+-- working. Deleting a user must not take their sales with it, so the
+-- transactions foreign key no longer cascades; such users are disabled
+-- instead.
+ALTER TABLE "users" ADD COLUMN "disabled_at" timestamp;
+
+ALTER TABLE "transactions" DROP CONSTRAINT "fk_transactions_user";
+ALTER TABLE "transactions" ADD CONSTRAINT "fk_transactions_user"
+	FOREIGN KEY ("user_id") REFERENCES "users"("id") ON DELETE RESTRICT;
+
+CREATE INDEX "idx_users_name" ON "users" ("name");
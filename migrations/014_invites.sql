@@ -0,0 +1,20 @@
+-- Invite-only registration. Admins create single-use invites that fix the
+-- role and store of the account; only the hash of the token is stored.
+ALTER TABLE "users" ADD COLUMN "store_id" varchar(36);
+
+CREATE TABLE "invites" (
+	"id" varchar(36) PRIMARY KEY,
+	"token_hash" varchar(64) NOT NULL CONSTRAINT "invites_token_hash_key" UNIQUE,
+	"role" varchar(20) NOT NULL,
+	"store_id" varchar(36),
+	"created_by" varchar(36),
+	"expires_at" timestamp NOT NULL,
+	"used_at" timestamp,
+	"used_by" varchar(36),
+	"revoked_at" timestamp,
+	"created_at" timestamp DEFAULT now() NOT NULL,
+	CONSTRAINT "fk_invites_created_by" FOREIGN KEY ("created_by") REFERENCES "users"("id") ON DELETE SET NULL,
+	CONSTRAINT "fk_invites_used_by" FOREIGN KEY ("used_by") REFERENCES "users"("id") ON DELETE SET NULL
+);
+
+CREATE INDEX "idx_invites_expires_at" ON "invites" ("expires_at");
@@ -0,0 +1,61 @@
+-- Multi-store support. Stock is kept per store in product_stocks;
+-- products.stock stays as the total over all stores. Transactions and stock
+-- events belong to a store, and users and devices are assigned to one.
+CREATE TABLE "stores" (
+	"id" varchar(36) PRIMARY KEY,
+	"name" varchar(100) NOT NULL,
+	"address" text,
+	"created_at" timestamp DEFAULT now() NOT NULL,
+	"updated_at" timestamp DEFAULT now() NOT NULL
+);
+
+-- Existing data is moved to a default store. Store ids already set on
+-- devices, users and invites were free text until now, so each becomes a
+-- store of its own that can be renamed later.
+INSERT INTO "stores" ("id", "name") VALUES ('00000000-0000-0000-0000-000000000001', 'Main store');
+
+INSERT INTO "stores" ("id", "name")
+	SELECT DISTINCT "store_id", "store_id" FROM (
+		SELECT "store_id" FROM "devices"
+		UNION SELECT "store_id" FROM "users"
+		UNION SELECT "store_id" FROM "invites"
+	) AS "assigned"
+	WHERE "store_id" IS NOT NULL
+	ON CONFLICT ("id") DO NOTHING;
+
+ALTER TABLE "devices" ADD CONSTRAINT "fk_devices_store"
+	FOREIGN KEY ("store_id") REFERENCES "stores"("id") ON DELETE SET NULL;
+ALTER TABLE "users" ADD CONSTRAINT "fk_users_store"
+	FOREIGN KEY ("store_id") REFERENCES "stores"("id") ON DELETE SET NULL;
+ALTER TABLE "invites" ADD CONSTRAINT "fk_invites_store"
+	FOREIGN KEY ("store_id") REFERENCES "stores"("id") ON DELETE SET NULL;
+CREATE INDEX "idx_users_store_id" ON "users" ("store_id");
+
+CREATE TABLE "product_stocks" (
+	"store_id" varchar(36) NOT NULL,
+	"product_id" varchar(36) NOT NULL,
+	"stock" integer DEFAULT 0 NOT NULL,
+	"updated_at" timestamp DEFAULT now() NOT NULL,
+	PRIMARY KEY ("store_id", "product_id"),
+	CONSTRAINT "fk_product_stocks_store" FOREIGN KEY ("store_id") REFERENCES "stores"("id") ON DELETE RESTRICT,
+	CONSTRAINT "fk_product_stocks_product" FOREIGN KEY ("product_id") REFERENCES "products"("id") ON DELETE CASCADE
+);
+
+CREATE INDEX "idx_product_stocks_product_id" ON "product_stocks" ("product_id");
+
+INSERT INTO "product_stocks" ("store_id", "product_id", "stock", "updated_at")
+	SELECT '00000000-0000-0000-0000-000000000001', "id", "stock", "updated_at" FROM "products";
+
+ALTER TABLE "transactions" ADD COLUMN "store_id" varchar(36);
+UPDATE "transactions" SET "store_id" = '00000000-0000-0000-0000-000000000001';
+ALTER TABLE "transactions" ALTER COLUMN "store_id" SET NOT NULL;
+ALTER TABLE "transactions" ADD CONSTRAINT "fk_transactions_store"
+	FOREIGN KEY ("store_id") REFERENCES "stores"("id") ON DELETE RESTRICT;
+CREATE INDEX "idx_transactions_store_created" ON "transactions" ("store_id", "created_at");
+
+ALTER TABLE "stock_events" ADD COLUMN "store_id" varchar(36);
+UPDATE "stock_events" SET "store_id" = '00000000-0000-0000-0000-000000000001';
+ALTER TABLE "stock_events" ALTER COLUMN "store_id" SET NOT NULL;
+ALTER TABLE "stock_events" ADD CONSTRAINT "fk_stock_events_store"
+	FOREIGN KEY ("store_id") REFERENCES "stores"("id") ON DELETE RESTRICT;
+CREATE INDEX "idx_stock_events_store_created" ON "stock_events" ("store_id", "created_at");
@@ -0,0 +1,95 @@
+-- Stock locations inside a store (e.g. back room, shop floor) and stock
+-- transfers between them. Every store has exactly one default location that
+-- sales, cancellations and returns use. Stock is kept per location in
+-- location_stocks; product_stocks stays as the store total.
+CREATE TABLE "locations" (
+	"id" varchar(36) PRIMARY KEY,
+	"store_id" varchar(36) NOT NULL,
+	"name" varchar(100) NOT NULL,
+	"is_default" boolean DEFAULT false NOT NULL,
+	"created_at" timestamp DEFAULT now() NOT NULL,
+	"updated_at" timestamp DEFAULT now() NOT NULL,
+	CONSTRAINT "fk_locations_store" FOREIGN KEY ("store_id") REFERENCES "stores"("id") ON DELETE CASCADE,
+	CONSTRAINT "locations_store_id_id_key" UNIQUE ("store_id", "id"),
+	CONSTRAINT "locations_store_id_name_key" UNIQUE ("store_id", "name")
+);
+
+CREATE UNIQUE INDEX "idx_locations_store_default" ON "locations" ("store_id") WHERE "is_default";
+
+-- Existing stores get a default location with an id derived from the store
+-- id, holding all of their current stock.
+INSERT INTO "locations" ("id", "store_id", "name", "is_default")
+	SELECT md5("id" || ':default')::uuid::varchar, "id", 'Shop floor', true FROM "stores";
+
+CREATE TABLE "location_stocks" (
+	"location_id" varchar(36) NOT NULL,
+	"product_id" varchar(36) NOT NULL,
+	"stock" integer DEFAULT 0 NOT NULL,
+	"updated_at" timestamp DEFAULT now() NOT NULL,
+	PRIMARY KEY ("location_id", "product_id"),
+	CONSTRAINT "fk_location_stocks_location" FOREIGN KEY ("location_id") REFERENCES "locations"("id") ON DELETE CASCADE,
+	CONSTRAINT "fk_location_stocks_product" FOREIGN KEY ("product_id") REFERENCES "products"("id") ON DELETE CASCADE
+);
+
+CREATE INDEX "idx_location_stocks_product_id" ON "location_stocks" ("product_id");
+
+INSERT INTO "location_stocks" ("location_id", "product_id", "stock", "updated_at")
+	SELECT l."id", ps."product_id", ps."stock", ps."updated_at"
+	FROM "product_stocks" ps
+	JOIN "locations" l ON l."store_id" = ps."store_id" AND l."is_default";
+
+-- A transfer moves stock from one location to another. Within a store it
+-- completes at once; between stores it stays in_transit until the receiving
+-- store books it in, or it is cancelled and the stock goes back.
+CREATE TABLE "stock_transfers" (
+	"id" varchar(36) PRIMARY KEY,
+	"from_store_id" varchar(36) NOT NULL,
+	"from_location_id" varchar(36) NOT NULL,
+	"to_store_id" varchar(36) NOT NULL,
+	"to_location_id" varchar(36) NOT NULL,
+	"status" varchar(20) NOT NULL,
+	"note" text,
+	"created_by" varchar(36),
+	"received_by" varchar(36),
+	"created_at" timestamp DEFAULT now() NOT NULL,
+	"received_at" timestamp,
+	"cancelled_at" timestamp,
+	CONSTRAINT "fk_stock_transfers_from_location" FOREIGN KEY ("from_store_id", "from_location_id") REFERENCES "locations"("store_id", "id") ON DELETE RESTRICT,
+	CONSTRAINT "fk_stock_transfers_to_location" FOREIGN KEY ("to_store_id", "to_location_id") REFERENCES "locations"("store_id", "id") ON DELETE RESTRICT,
+	CONSTRAINT "fk_stock_transfers_created_by" FOREIGN KEY ("created_by") REFERENCES "users"("id") ON DELETE SET NULL,
+	CONSTRAINT "fk_stock_transfers_received_by" FOREIGN KEY ("received_by") REFERENCES "users"("id") ON DELETE SET NULL,
+	CONSTRAINT "stock_transfers_status_check" CHECK (status IN ('in_transit', 'completed', 'cancelled')),
+	CONSTRAINT "stock_transfers_locations_check" CHECK (from_location_id <> to_location_id)
+);
+
+CREATE INDEX "idx_stock_transfers_from_store" ON "stock_transfers" ("from_store_id", "created_at");
+CREATE INDEX "idx_stock_transfers_to_store" ON "stock_transfers" ("to_store_id", "created_at");
+
+CREATE TABLE "stock_transfer_items" (
+	"id" varchar(36) PRIMARY KEY,
+	"transfer_id" varchar(36) NOT NULL,
+	"product_id" varchar(36) NOT NULL,
+	"qty" integer NOT NULL,
+	CONSTRAINT "fk_stock_transfer_items_transfer" FOREIGN KEY ("transfer_id") REFERENCES "stock_transfers"("id") ON DELETE CASCADE,
+	CONSTRAINT "fk_stock_transfer_items_product" FOREIGN KEY ("product_id") REFERENCES "products"("id") ON DELETE RESTRICT,
+	CONSTRAINT "stock_transfer_items_qty_check" CHECK (qty > 0),
+	CONSTRAINT "stock_transfer_items_product_key" UNIQUE ("transfer_id", "product_id")
+);
+
+-- Stock events are booked at a location of their store. The composite key
+-- keeps an event from pointing at another store's location.
+ALTER TABLE "stock_events" ADD COLUMN "location_id" varchar(36);
+UPDATE "stock_events" e SET "location_id" = l."id"
+	FROM "locations" l WHERE l."store_id" = e."store_id" AND l."is_default";
+ALTER TABLE "stock_events" ALTER COLUMN "location_id" SET NOT NULL;
+ALTER TABLE "stock_events" ADD CONSTRAINT "fk_stock_events_location"
+	FOREIGN KEY ("store_id", "location_id") REFERENCES "locations"("store_id", "id") ON DELETE RESTRICT;
+
+ALTER TABLE "stock_events" ADD COLUMN "transfer_id" varchar(36);
+ALTER TABLE "stock_events" ADD CONSTRAINT "fk_stock_events_transfer"
+	FOREIGN KEY ("transfer_id") REFERENCES "stock_transfers"("id") ON DELETE RESTRICT;
+CREATE INDEX "idx_stock_events_transfer_id" ON "stock_events" ("transfer_id");
+
+ALTER TABLE "stock_events" DROP CONSTRAINT "stock_events_type_check";
+ALTER TABLE "stock_events" ADD CONSTRAINT "stock_events_type_check"
+	CHECK (type IN ('sale', 'restock', 'reject', 'adjustment', 'opening_stock', 'cancellation', 'return', 'transfer_out', 'transfer_in'));
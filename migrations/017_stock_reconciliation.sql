@@ -0,0 +1,21 @@
+-- Audit trail of stock reconciliation. Every cached stock level that a
+-- rebuild overwrote with the value from the stock_events ledger gets a row;
+-- rows of the same run share run_id.
+CREATE TABLE "stock_corrections" (
+	"id" varchar(36) PRIMARY KEY,
+	"run_id" varchar(36) NOT NULL,
+	"trigger" varchar(20) NOT NULL,
+	"level" varchar(20) NOT NULL,
+	"product_id" varchar(36) NOT NULL,
+	"store_id" varchar(36),
+	"location_id" varchar(36),
+	"cached_stock" integer NOT NULL,
+	"ledger_stock" integer NOT NULL,
+	"created_at" timestamp DEFAULT now() NOT NULL,
+	CONSTRAINT "fk_stock_corrections_product" FOREIGN KEY ("product_id") REFERENCES "products"("id") ON DELETE CASCADE,
+	CONSTRAINT "stock_corrections_trigger_check" CHECK (trigger IN ('cli', 'schedule')),
+	CONSTRAINT "stock_corrections_level_check" CHECK (level IN ('product', 'store', 'location'))
+);
+
+CREATE INDEX "idx_stock_corrections_run_id" ON "stock_corrections" ("run_id");
+CREATE INDEX "idx_stock_corrections_product_created" ON "stock_corrections" ("product_id", "created_at");
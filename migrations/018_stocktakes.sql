@@ -0,0 +1,65 @@
+-- Stocktakes (physical counts) of one location. Opening a stocktake freezes
+-- the expected quantity of every product at the location in stocktake_items.
+-- Devices submit counts, possibly offline and in several passes, and approval
+-- books each difference as an adjustment stock event linked to the stocktake.
+CREATE TABLE "stocktakes" (
+	"id" varchar(36) PRIMARY KEY,
+	"store_id" varchar(36) NOT NULL,
+	"location_id" varchar(36) NOT NULL,
+	"status" varchar(20) NOT NULL,
+	"note" text,
+	"created_by" varchar(36),
+	"approved_by" varchar(36),
+	"created_at" timestamp DEFAULT now() NOT NULL,
+	"approved_at" timestamp,
+	"cancelled_at" timestamp,
+	CONSTRAINT "fk_stocktakes_location" FOREIGN KEY ("store_id", "location_id") REFERENCES "locations"("store_id", "id") ON DELETE RESTRICT,
+	CONSTRAINT "fk_stocktakes_created_by" FOREIGN KEY ("created_by") REFERENCES "users"("id") ON DELETE SET NULL,
+	CONSTRAINT "fk_stocktakes_approved_by" FOREIGN KEY ("approved_by") REFERENCES "users"("id") ON DELETE SET NULL,
+	CONSTRAINT "stocktakes_status_check" CHECK (status IN ('open', 'approved', 'cancelled'))
+);
+
+CREATE INDEX "idx_stocktakes_store" ON "stocktakes" ("store_id", "created_at");
+-- Only one count at a time per location.
+CREATE UNIQUE INDEX "idx_stocktakes_open_location" ON "stocktakes" ("location_id") WHERE status = 'open';
+
+-- store_id and status are copied from the stocktake so PowerSync can sync
+-- the items of a store's open stocktakes without a join.
+CREATE TABLE "stocktake_items" (
+	"stocktake_id" varchar(36) NOT NULL,
+	"store_id" varchar(36) NOT NULL,
+	"product_id" varchar(36) NOT NULL,
+	"expected_qty" integer NOT NULL,
+	"status" varchar(20) DEFAULT 'open' NOT NULL,
+	PRIMARY KEY ("stocktake_id", "product_id"),
+	CONSTRAINT "fk_stocktake_items_stocktake" FOREIGN KEY ("stocktake_id") REFERENCES "stocktakes"("id") ON DELETE CASCADE,
+	CONSTRAINT "fk_stocktake_items_product" FOREIGN KEY ("product_id") REFERENCES "products"("id") ON DELETE RESTRICT
+);
+
+CREATE INDEX "idx_stocktake_items_store_open" ON "stocktake_items" ("store_id") WHERE "status" = 'open';
+
+-- Counts add up per product, so several people can count one product on
+-- different shelves. A recount discards the counts made before it.
+CREATE TABLE "stocktake_counts" (
+	"id" varchar(36) PRIMARY KEY,
+	"stocktake_id" varchar(36) NOT NULL,
+	"product_id" varchar(36) NOT NULL,
+	"qty" integer NOT NULL,
+	"recount" boolean DEFAULT false NOT NULL,
+	"user_id" varchar(36),
+	"device_id" varchar(36),
+	"counted_at" timestamp NOT NULL,
+	"created_at" timestamp DEFAULT now() NOT NULL,
+	CONSTRAINT "fk_stocktake_counts_item" FOREIGN KEY ("stocktake_id", "product_id") REFERENCES "stocktake_items"("stocktake_id", "product_id") ON DELETE CASCADE,
+	CONSTRAINT "fk_stocktake_counts_user" FOREIGN KEY ("user_id") REFERENCES "users"("id") ON DELETE SET NULL,
+	CONSTRAINT "fk_stocktake_counts_device" FOREIGN KEY ("device_id") REFERENCES "devices"("id") ON DELETE SET NULL,
+	CONSTRAINT "stocktake_counts_qty_check" CHECK (qty >= 0)
+);
+
+CREATE INDEX "idx_stocktake_counts_item" ON "stocktake_counts" ("stocktake_id", "product_id", "counted_at");
+CREATE INDEX "idx_stocktake_counts_device_id" ON "stocktake_counts" ("device_id");
+
+ALTER TABLE "stock_events" ADD COLUMN "stocktake_id" varchar(36);
+ALTER TABLE "stock_events" ADD CONSTRAINT "fk_stock_events_stocktake"
+	FOREIGN KEY ("stocktake_id") REFERENCES "stocktakes"("id") ON DELETE RESTRICT;
+CREATE INDEX "idx_stock_events_stocktake_id" ON "stock_events" ("stocktake_id");